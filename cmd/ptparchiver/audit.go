@@ -0,0 +1,18 @@
+package main
+
+import (
+	"time"
+
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+)
+
+// watchAudits runs the seeding audit every interval for as long as the run command is active,
+// the first one after a full interval so it doesn't compete with the initial fetch
+func watchAudits(client *archiver.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		client.Audit()
+	}
+}
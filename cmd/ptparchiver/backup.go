@@ -0,0 +1,363 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/state"
+	"github.com/s0up4200/ptparchiver-go/pkg/version"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	backupSecrets     bool
+	backupTorrentDirs []string
+	restoreForce      bool
+
+	backupCmd = &cobra.Command{
+		Use:   "backup [file]",
+		Short: "Bundle the config, state, history and saved .torrent files into a tarball",
+		Long: `Bundle the config, state and history files and the .torrent files in each container's
+oversizeDir into a .tar.gz, to move ptparchiver to a new host with restore.
+
+Plain text credentials are blanked in the bundled config unless --include-secrets is given.
+Encrypted values and secret manager references are always kept.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runBackup,
+		Example: `  # Back up to ptparchiver-backup-<date>.tar.gz in the current directory
+  ptparchiver backup
+
+  # Include the credentials and another directory of .torrent files
+  ptparchiver backup /mnt/backup/ptparchiver.tar.gz --include-secrets --torrent-dir /data/torrents`,
+	}
+
+	restoreCmd = &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Restore a backup made with the backup command",
+		Long: `Restore the config, state and history files next to the config file (--config, or the default
+location when there is none yet) and the .torrent files to the directories they were backed up from.
+
+Existing files are left alone unless --force is given.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runRestore,
+		Example: `  ptparchiver restore ptparchiver-backup-20250101-120000.tar.gz
+  ptparchiver restore backup.tar.gz --config /etc/ptparchiver/config.yaml --force`,
+	}
+)
+
+func init() {
+	backupCmd.GroupID = "setup"
+	restoreCmd.GroupID = "setup"
+	backupCmd.Flags().BoolVar(&backupSecrets, "include-secrets", false, "keep plain text credentials in the bundled config")
+	backupCmd.Flags().StringSliceVar(&backupTorrentDirs, "torrent-dir", nil, "more directories of .torrent files to include, on top of every oversizeDir")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "overwrite existing files")
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
+
+// Names of the files in a backup, the .torrent files of each directory are under torrents/<index>/
+const (
+	backupManifestName = "manifest.json"
+	backupConfigName   = "config.yaml"
+	backupTorrentsDir  = "torrents"
+)
+
+// backupManifest describes a backup and is always its first entry
+type backupManifest struct {
+	Version string    `json:"version"`
+	Created time.Time `json:"created"`
+	// Secrets is set when the config still holds its plain text credentials
+	Secrets bool `json:"secrets"`
+	// TorrentDirs holds the directory the .torrent files under torrents/<index>/ were backed up from
+	TorrentDirs []string `json:"torrentDirs,omitempty"`
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	output := fmt.Sprintf("ptparchiver-backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+	if len(args) > 0 {
+		output = args[0]
+	}
+
+	manifest := backupManifest{Version: version.Version, Created: time.Now(), Secrets: backupSecrets}
+
+	// without a config file, when running from environment variables, only the state and history are bundled
+	configData, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error().Err(err).Str("path", configPath).Msg("failed to read config file")
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if configData != nil {
+		// parsed as is, so an encrypted config can be backed up without its passphrase
+		var cfg config.Config
+		if err := yaml.Unmarshal(configData, &cfg); err != nil {
+			log.Error().Err(err).Str("path", configPath).Msg("failed to parse config file")
+			return fmt.Errorf("failed to parse config file: %w", err)
+		}
+		for _, container := range cfg.Containers {
+			backupTorrentDirs = append(backupTorrentDirs, container.OversizeDir)
+		}
+
+		if !backupSecrets {
+			redacted, n, err := config.RedactYAML(configData)
+			if err != nil {
+				return err
+			}
+			configData = redacted
+			log.Info().Int("values", n).Msg("blanked credentials in the bundled config, pass --include-secrets to keep them")
+		}
+	}
+
+	for _, dir := range backupTorrentDirs {
+		if dir == "" {
+			continue
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve torrent directory %s: %w", dir, err)
+		}
+		if !slices.Contains(manifest.TorrentDirs, abs) {
+			manifest.TorrentDirs = append(manifest.TorrentDirs, abs)
+		}
+	}
+
+	// written next to the destination first so a failed backup never leaves a truncated file behind
+	tmp := output + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	files, err := writeBackup(f, manifest, configPath, configData)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Error().Err(err).Str("path", output).Msg("failed to write backup")
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := os.Rename(tmp, output); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Backed up %d files to %s\n", files, output)
+	if backupSecrets {
+		fmt.Fprintln(cmd.OutOrStdout(), "The backup contains credentials, keep it somewhere safe")
+	}
+	return nil
+}
+
+// writeBackup writes the gzipped tarball and returns the number of files in it besides the manifest
+func writeBackup(w io.Writer, manifest backupManifest, configPath string, configData []byte) (int, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	files := 0
+
+	add := func(name string, data []byte, modTime time.Time) error {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: modTime}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	addFile := func(name, path string) error {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		files++
+		return add(name, data, info.ModTime())
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := add(backupManifestName, manifestData, manifest.Created); err != nil {
+		return 0, err
+	}
+
+	if configData != nil {
+		files++
+		if err := add(backupConfigName, configData, manifest.Created); err != nil {
+			return 0, err
+		}
+	}
+
+	statePath, err := mockPath(state.PathFor(configPath))
+	if err != nil {
+		return 0, err
+	}
+	historyPath, err := mockPath(state.HistoryPathFor(configPath))
+	if err != nil {
+		return 0, err
+	}
+	if err := addFile(state.FileName, statePath); err != nil {
+		return 0, err
+	}
+	if err := addFile(state.HistoryFileName, historyPath); err != nil {
+		return 0, err
+	}
+
+	for i, dir := range manifest.TorrentDirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Warn().Str("dir", dir).Msg("torrent directory doesn't exist, skipping it")
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".torrent") {
+				continue
+			}
+			name := path.Join(backupTorrentsDir, strconv.Itoa(i), entry.Name())
+			if err := addFile(name, filepath.Join(dir, entry.Name())); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	return files, gz.Close()
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	configPath, err := locateConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("could not determine home directory")
+		return err
+	}
+	if configPath == "" {
+		configDir, err := defaultConfigDir()
+		if err != nil {
+			log.Error().Err(err).Msg("could not determine home directory")
+			return err
+		}
+		configPath = filepath.Join(configDir, "config.yaml")
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != backupManifestName {
+		return fmt.Errorf("%s is not a ptparchiver backup", args[0])
+	}
+	var manifest backupManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+
+	restored, skipped := 0, 0
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %w", err)
+		}
+
+		target, err := restorePath(header.Name, configPath, manifest)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(target); err == nil && !restoreForce {
+			log.Warn().Str("path", target).Msg("file already exists, pass --force to overwrite it")
+			skipped++
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from backup: %w", header.Name, err)
+		}
+		// the config holds credentials and the state and history where torrents are archived, so none of
+		// them is restored readable by other users whatever the backup says
+		mode := header.FileInfo().Mode().Perm() & 0600
+		if err := os.WriteFile(target, data, mode); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", target, err)
+		}
+		// an overwritten file keeps its mode otherwise
+		if err := os.Chmod(target, mode); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", target, err)
+		}
+		log.Debug().Str("path", target).Msg("restored file")
+		restored++
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Restored %d files from the backup made %s", restored, manifest.Created.Format(time.RFC3339))
+	if skipped > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), ", skipped %d existing files", skipped)
+	}
+	fmt.Fprintln(cmd.OutOrStdout())
+	if !manifest.Secrets {
+		fmt.Fprintf(cmd.OutOrStdout(), "The credentials were blanked when backing up, fill them in to %s\n", configPath)
+	}
+	return nil
+}
+
+// restorePath returns where an entry of the backup is restored to, rejecting names that aren't part of a backup
+func restorePath(name, configPath string, manifest backupManifest) (string, error) {
+	switch name {
+	case backupConfigName:
+		return configPath, nil
+	case state.FileName:
+		return mockPath(state.PathFor(configPath))
+	case state.HistoryFileName:
+		return mockPath(state.HistoryPathFor(configPath))
+	}
+
+	// torrents/<index>/<file>.torrent, the file name can't point anywhere else
+	parts := strings.Split(name, "/")
+	if len(parts) == 3 && parts[0] == backupTorrentsDir && parts[2] == filepath.Base(parts[2]) && strings.HasSuffix(parts[2], ".torrent") {
+		if i, err := strconv.Atoi(parts[1]); err == nil && i >= 0 && i < len(manifest.TorrentDirs) {
+			return filepath.Join(manifest.TorrentDirs[i], parts[2]), nil
+		}
+	}
+	return "", fmt.Errorf("unexpected file %q in backup", name)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRestorePath(t *testing.T) {
+	configPath := filepath.Join("/etc", "ptparchiver", "config.yaml")
+	manifest := backupManifest{TorrentDirs: []string{filepath.Join("/srv", "watch")}}
+
+	tests := []struct {
+		name    string
+		entry   string
+		want    string
+		wantErr bool
+	}{
+		{name: "config", entry: "config.yaml", want: configPath},
+		{name: "state", entry: "state.json", want: filepath.Join("/etc", "ptparchiver", "state.json")},
+		{name: "history", entry: "history.json", want: filepath.Join("/etc", "ptparchiver", "history.json")},
+		{name: "torrent", entry: "torrents/0/movie.torrent", want: filepath.Join("/srv", "watch", "movie.torrent")},
+		{name: "parent directory", entry: "torrents/0/../../etc/cron.d/x.torrent", wantErr: true},
+		{name: "dot dot file", entry: "torrents/0/..", wantErr: true},
+		{name: "absolute path", entry: "/etc/passwd", wantErr: true},
+		{name: "nested config", entry: "../config.yaml", wantErr: true},
+		{name: "unknown directory index", entry: "torrents/1/movie.torrent", wantErr: true},
+		{name: "negative directory index", entry: "torrents/-1/movie.torrent", wantErr: true},
+		{name: "not a number", entry: "torrents/x/movie.torrent", wantErr: true},
+		{name: "not a torrent", entry: "torrents/0/movie.sh", wantErr: true},
+		{name: "missing file name", entry: "torrents/0", wantErr: true},
+		{name: "other file", entry: "manifest.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := restorePath(tt.entry, configPath, manifest)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("restorePath(%q) = %q, want an error", tt.entry, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("restorePath(%q) error = %v", tt.entry, err)
+			}
+			if got != tt.want {
+				t.Errorf("restorePath(%q) = %q, want %q", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
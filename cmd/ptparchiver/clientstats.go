@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/spf13/cobra"
+)
+
+var clientStatsCmd = &cobra.Command{
+	Use:               "client-stats [client...]",
+	Short:             "Summarize torrents, free space and version of each client",
+	RunE:              runClientStats,
+	ValidArgsFunction: completeClients,
+}
+
+func init() {
+	clientStatsCmd.GroupID = "operation"
+	rootCmd.AddCommand(clientStatsCmd)
+}
+
+// clientStats holds the torrent counts for a single client
+type clientStats struct {
+	total, archive, downloading, stalled, errored int
+	freeSpace                                     uint64
+}
+
+func (s *clientStats) add(other clientStats) {
+	s.total += other.total
+	s.archive += other.archive
+	s.downloading += other.downloading
+	s.stalled += other.stalled
+	s.errored += other.errored
+	s.freeSpace += other.freeSpace
+}
+
+func runClientStats(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+
+	names := clientNames(cfg)
+	if len(args) > 0 {
+		for _, name := range args {
+			if client.Type(cfg, name) == "" {
+				return fmt.Errorf("client %s not found", name)
+			}
+		}
+		names = args
+	}
+
+	var totals clientStats
+	clients := newClientCache(cfg)
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLIENT\tTYPE\tVERSION\tTORRENTS\tARCHIVE\tDOWNLOADING\tSTALLED\tERRORED\tFREE SPACE\tFULL IN")
+
+	for _, name := range names {
+		clientType := client.Type(cfg, name)
+
+		tc, err := clients.get(name)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%s\tunreachable\t-\t-\t-\t-\t-\t-\t-\n", name, clientType)
+			continue
+		}
+
+		version, err := tc.Version()
+		if err != nil {
+			log.Warn().Err(err).Str("client", name).Msg("failed to get version")
+			version = "unknown"
+		}
+
+		torrents, err := tc.ListTorrents("")
+		if err != nil {
+			log.Error().Err(err).Str("client", name).Msg("failed to list torrents")
+			fmt.Fprintf(w, "%s\t%s\t%s\terror\t-\t-\t-\t-\t-\t-\n", name, clientType, version)
+			continue
+		}
+
+		// categories used by the containers assigned to this client
+		archiveCategories := make(map[string]struct{})
+		for _, container := range cfg.Containers {
+			if container.Client == name {
+				archiveCategories[container.Category] = struct{}{}
+			}
+		}
+
+		stats := clientStats{total: len(torrents)}
+		for _, t := range torrents {
+			if _, ok := archiveCategories[t.Category]; ok {
+				stats.archive++
+			}
+			switch t.State {
+			case client.StateDownloading:
+				stats.downloading++
+			case client.StateStalled:
+				stats.stalled++
+			case client.StateError, client.StateMissing:
+				stats.errored++
+			}
+		}
+
+		freeSpace := "-"
+		// rTorrent only reports free space with freeSpaceMode local
+		if client.ReportsFreeSpace(cfg, name) {
+			if space, err := tc.GetFreeSpace(); err != nil {
+				log.Warn().Err(err).Str("client", name).Msg("failed to get free space")
+				freeSpace = "error"
+			} else {
+				stats.freeSpace = space
+				freeSpace = units.HumanSize(float64(space))
+				if err := st.RecordFreeSpace(name, space); err != nil {
+					log.Warn().Err(err).Str("client", name).Msg("failed to record free space")
+				}
+			}
+		}
+
+		fullIn := "-"
+		if eta, ok := st.TimeUntilFull(name); ok {
+			fullIn = formatETA(eta)
+		}
+
+		totals.add(stats)
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			name, clientType, version, stats.total, stats.archive, stats.downloading, stats.stalled, stats.errored, freeSpace, fullIn)
+	}
+
+	if len(names) > 1 {
+		fmt.Fprintf(w, "TOTAL\t\t\t%d\t%d\t%d\t%d\t%d\t%s\t\n",
+			totals.total, totals.archive, totals.downloading, totals.stalled, totals.errored, units.HumanSize(float64(totals.freeSpace)))
+	}
+
+	return w.Flush()
+}
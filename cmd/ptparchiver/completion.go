@@ -0,0 +1,76 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	fetchCmd.ValidArgsFunction = completeContainers
+	statusCmd.ValidArgsFunction = completeContainers
+	testCmd.ValidArgsFunction = completeClientsOrAll
+}
+
+// completionConfig loads the config without logging, since anything written to stdout
+// during completion is interpreted by the shell as a candidate
+func completionConfig() (*config.Config, bool) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+
+	cfg, err := findAndLoadPlainConfig()
+	if err != nil {
+		return nil, false
+	}
+	return cfg, true
+}
+
+// completeContainers suggests configured container names that aren't already on the command line
+func completeContainers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if cmd.Args != nil && cmd.Args(cmd, append(args, toComplete)) != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	cfg, ok := completionConfig()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return filterCompletions(slices.Sorted(maps.Keys(cfg.Containers)), args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeClients suggests configured client names that aren't already on the command line
+func completeClients(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if cmd.Args != nil && cmd.Args(cmd, append(args, toComplete)) != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	cfg, ok := completionConfig()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return filterCompletions(clientNames(cfg), args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeClientsOrAll suggests configured client names, plus "all"
+func completeClientsOrAll(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	completions, directive := completeClients(cmd, args, toComplete)
+	if len(args) == 0 && strings.HasPrefix("all", toComplete) {
+		completions = append(completions, "all")
+	}
+	return completions, directive
+}
+
+func filterCompletions(candidates, used []string, toComplete string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, toComplete) && !slices.Contains(used, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	importOutput string
+
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manage the config file",
+	}
+
+	importPythonCmd = &cobra.Command{
+		Use:   "import-python <path>",
+		Short: "Convert a config from the official Python archiver",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runImportPython,
+		Example: `  # Print the converted config
+  ptparchiver config import-python ~/.config/ptparchiver/config.ini
+
+  # Write the converted config to a file
+  ptparchiver config import-python config.ini --output ~/.config/ptparchiver-go/config.yaml`,
+	}
+)
+
+func init() {
+	configCmd.GroupID = "setup"
+	configCmd.AddCommand(importPythonCmd)
+	rootCmd.AddCommand(configCmd)
+
+	importPythonCmd.Flags().StringVarP(&importOutput, "output", "o", "", "write the converted config to this path instead of stdout")
+}
+
+func runImportPython(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		log.Error().Err(err).Str("path", args[0]).Msg("failed to open python config")
+		return fmt.Errorf("failed to open python config: %w", err)
+	}
+	defer f.Close()
+
+	cfg, err := config.ImportPython(f)
+	if err != nil {
+		log.Error().Err(err).Str("path", args[0]).Msg("failed to convert python config")
+		return fmt.Errorf("failed to convert python config: %w", err)
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if importOutput == "" {
+		_, err := cmd.OutOrStdout().Write(data)
+		return err
+	}
+
+	if _, err := os.Stat(importOutput); err == nil {
+		log.Error().Str("path", importOutput).Msg("config file already exists")
+		return fmt.Errorf("config file already exists at %s", importOutput)
+	}
+
+	if err := os.WriteFile(importOutput, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	log.Info().
+		Str("path", importOutput).
+		Int("containers", len(cfg.Containers)).
+		Int("clients", len(cfg.QBitClients)+len(cfg.RTorrClients)+len(cfg.DelugeClients)).
+		Msg("imported python config")
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	containersJSON bool
+
+	containersCmd = &cobra.Command{
+		Use:   "containers",
+		Short: "Inspect containers",
+	}
+
+	containersRemoteCmd = &cobra.Command{
+		Use:   "remote",
+		Short: "Show the container ID PTP associates with each container",
+		Long: `Show the ContainerID and status PTP returned the last time each container fetched.
+
+PTP identifies containers by name, so renaming a container locally makes PTP treat it as a new one.
+Containers that only exist in the state file are listed too, which helps spot such mismatches.`,
+		Args: cobra.NoArgs,
+		RunE: runContainersRemote,
+	}
+)
+
+func init() {
+	containersCmd.GroupID = "setup"
+	containersCmd.AddCommand(containersRemoteCmd)
+	rootCmd.AddCommand(containersCmd)
+
+	containersRemoteCmd.Flags().BoolVar(&containersJSON, "json", false, "output as JSON")
+}
+
+type remoteEntry struct {
+	Name        string     `json:"name"`
+	Configured  bool       `json:"configured"`
+	ContainerID string     `json:"containerId,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	LastSeen    *time.Time `json:"lastSeen,omitempty"`
+	// SharedWith lists other containers PTP returned the same ID for
+	SharedWith []string `json:"sharedWith,omitempty"`
+}
+
+func runContainersRemote(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadPlainConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+
+	names := slices.Collect(maps.Keys(cfg.Containers))
+	for _, name := range st.ContainerNames() {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	entries := make([]remoteEntry, 0, len(names))
+	byID := make(map[string][]string)
+	for _, name := range names {
+		_, configured := cfg.Containers[name]
+		entry := remoteEntry{Name: name, Configured: configured}
+
+		if cs, ok := st.Container(name); ok && cs.Remote != nil {
+			entry.ContainerID = cs.Remote.ID
+			entry.Status = cs.Remote.Status
+			entry.LastSeen = &cs.Remote.LastSeen
+			byID[entry.ContainerID] = append(byID[entry.ContainerID], name)
+		}
+
+		// state entries without a remote ID or config entry are leftovers of commands like pause
+		if !configured && entry.ContainerID == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	for i, e := range entries {
+		if e.ContainerID == "" {
+			continue
+		}
+		for _, other := range byID[e.ContainerID] {
+			if other != e.Name {
+				entries[i].SharedWith = append(entries[i].SharedWith, other)
+			}
+		}
+	}
+
+	if containersJSON {
+		return writeJSON(cmd.OutOrStdout(), entries)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCONTAINER ID\tLAST SEEN\tPTP STATUS\tNOTE")
+	for _, e := range entries {
+		lastSeen := "never"
+		if e.LastSeen != nil {
+			lastSeen = e.LastSeen.Format(time.RFC3339)
+		}
+
+		note := ""
+		switch {
+		case !e.Configured:
+			note = "not in config, renamed or removed?"
+		case len(e.SharedWith) > 0:
+			note = fmt.Sprintf("same ID as %v", e.SharedWith)
+		case e.ContainerID == "":
+			note = "not fetched yet"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			e.Name, valueOrDash(e.ContainerID), lastSeen, valueOrDash(e.Status), valueOrDash(note))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var triggerCmd = &cobra.Command{
+	Use:   "trigger [container...]",
+	Short: "Start a fetch on the running service right away",
+	Long: `Ask a running "ptparchiver run" to fetch for every container, or only the given ones, right away
+instead of waiting for the next scheduled fetch. Requires controlSocket in the config.
+
+Sending SIGUSR1 to the run process fetches for every container as well.`,
+	Example: `  ptparchiver trigger
+  ptparchiver trigger hetzner homelab
+  kill -USR1 $(pidof ptparchiver)`,
+	RunE: runTrigger,
+}
+
+func init() {
+	triggerCmd.GroupID = "operation"
+	rootCmd.AddCommand(triggerCmd)
+}
+
+// controlTimeout bounds how long the trigger command waits for the service to answer
+const controlTimeout = 10 * time.Second
+
+func runTrigger(cmd *cobra.Command, args []string) error {
+	cfg, err := findAndLoadPlainConfig()
+	if err != nil {
+		return err
+	}
+
+	if cfg.ControlSocket == "" {
+		return withExitCode(ExitConfig, errors.New("controlSocket isn't set in the config, send SIGUSR1 to the run process instead"))
+	}
+
+	conn, err := net.DialTimeout("unix", cfg.ControlSocket, controlTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the control socket, is ptparchiver run active? %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	if _, err := fmt.Fprintln(conn, strings.Join(append([]string{"fetch"}, args...), " ")); err != nil {
+		return fmt.Errorf("failed to send trigger: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read reply: %w", err)
+	}
+	reply = strings.TrimSpace(reply)
+	if msg, failed := strings.CutPrefix(reply, "error: "); failed {
+		return errors.New(msg)
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), reply)
+	return nil
+}
+
+// listenControl accepts "fetch [container...]" commands on a unix socket at path and sends them to triggers.
+// Unknown containers are rejected so a typo doesn't go unnoticed. Closing the returned listener removes the socket.
+func listenControl(path string, containers []string, triggers chan<- []string) (net.Listener, error) {
+	// a socket left behind by a process that didn't shut down cleanly blocks listening
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove old control socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	// anyone who can connect can trigger fetches, so only the owner may
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict control socket permissions: %w", err)
+	}
+	log.Info().Str("path", path).Msg("listening on control socket")
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Error().Err(err).Msg("failed to accept control connection")
+				}
+				return
+			}
+			go handleControl(conn, containers, triggers)
+		}
+	}()
+	return listener, nil
+}
+
+func handleControl(conn net.Conn, containers []string, triggers chan<- []string) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to read control command")
+		return
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "fetch" {
+		fmt.Fprintf(conn, "error: unknown command %q\n", strings.TrimSpace(line))
+		return
+	}
+
+	names := fields[1:]
+	for _, name := range names {
+		if !slices.Contains(containers, name) {
+			fmt.Fprintf(conn, "error: container %s not found\n", name)
+			return
+		}
+	}
+
+	log.Info().Strs("containers", names).Msg("received trigger on control socket")
+	triggers <- names
+
+	if len(names) == 0 {
+		fmt.Fprintf(conn, "triggered fetch for all %d containers\n", len(containers))
+		return
+	}
+	fmt.Fprintf(conn, "triggered fetch for %s\n", strings.Join(names, ", "))
+}
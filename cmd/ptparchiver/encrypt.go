@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	configEncryptCmd = &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt the credentials in the config file with a passphrase",
+		Long: `Encrypt apiKey, password and basicPass values in the config file with a passphrase.
+
+The passphrase is read from PTPARCHIVER_PASSPHRASE, the file named by PTPARCHIVER_PASSPHRASE_FILE,
+or prompted for. Every command then needs the same passphrase to load the config.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error { return rewriteConfigSecrets(true) },
+	}
+
+	configDecryptCmd = &cobra.Command{
+		Use:   "decrypt",
+		Short: "Decrypt the credentials in the config file back to plain text",
+		Args:  cobra.NoArgs,
+		RunE:  func(cmd *cobra.Command, args []string) error { return rewriteConfigSecrets(false) },
+	}
+)
+
+func init() {
+	configCmd.AddCommand(configEncryptCmd)
+	configCmd.AddCommand(configDecryptCmd)
+}
+
+// rewriteConfigSecrets encrypts or decrypts the credentials in the config file in place
+func rewriteConfigSecrets(encrypt bool) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		log.Error().Err(err).Str("path", configPath).Msg("failed to read config file")
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	passphrase, err := readPassphrase(encrypt)
+	if err != nil {
+		return err
+	}
+
+	rewrite, action := config.DecryptYAML, "decrypted"
+	if encrypt {
+		rewrite, action = config.EncryptYAML, "encrypted"
+	}
+
+	out, n, err := rewrite(data, passphrase)
+	if err != nil {
+		log.Error().Err(err).Str("path", configPath).Msg("failed to rewrite config file")
+		return err
+	}
+	if n == 0 {
+		log.Info().Str("path", configPath).Msgf("no values to be %s", action)
+		return nil
+	}
+
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat config file: %w", err)
+	}
+
+	// write to a temp file first so a crash never leaves a truncated config
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, out, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp, configPath); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	log.Info().Str("path", configPath).Int("values", n).Msgf("%s config values", action)
+	return nil
+}
+
+// readPassphrase returns the passphrase from the environment, prompting for it when running in a
+// terminal. A prompted passphrase must be entered twice when confirm is set.
+func readPassphrase(confirm bool) (string, error) {
+	passphrase, err := config.PassphraseFromEnv()
+	if err != nil || passphrase != "" {
+		return passphrase, err
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", config.ErrNoPassphrase
+	}
+
+	prompt := func(label string) (string, error) {
+		fmt.Fprint(os.Stderr, label)
+		// read without echoing, so the passphrase doesn't end up in the terminal's scrollback
+		line, err := term.ReadPassword(fd)
+		// the newline typed by the user isn't echoed either
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase, set %s instead: %w", config.PassphraseEnv, err)
+		}
+		return string(line), nil
+	}
+
+	passphrase, err = prompt("Config passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase must not be empty")
+	}
+
+	if confirm {
+		again, err := prompt("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return passphrase, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+)
+
+// Exit codes returned by fetch, run --once and healthcheck so wrapper scripts can tell failures apart
+const (
+	ExitOK = 0
+	// ExitError is used for any failure without a more specific code
+	ExitError = 1
+	// ExitConfig means the config file is missing or invalid
+	ExitConfig = 2
+	// ExitAuth means PTP rejected the API credentials
+	ExitAuth = 3
+	// ExitClientUnreachable means a torrent client could not be connected to
+	ExitClientUnreachable = 4
+	// ExitPartialFailure means fetching failed for at least one container
+	ExitPartialFailure = 5
+	// ExitSkipped means nothing failed but at least one fetch was skipped because of stalled torrents or free space
+	ExitSkipped = 6
+	// ExitOverdue means the healthcheck found a scheduled fetch overdue, so the run command is likely no longer active
+	ExitOverdue = 7
+)
+
+// exitStatus is the exit code of a command that completed without an error
+var exitStatus = ExitOK
+
+// exitError attaches an exit code to an error
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the exit code for the error returned by a command
+func exitCode(err error) int {
+	if err == nil {
+		return exitStatus
+	}
+
+	var exitErr *exitError
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	case errors.Is(err, archiver.ErrInvalidConfig):
+		return ExitConfig
+	case errors.Is(err, archiver.ErrUnauthorized):
+		return ExitAuth
+	case errors.Is(err, archiver.ErrClientUnavailable):
+		return ExitClientUnreachable
+	}
+	return ExitError
+}
+
+// summaryResult turns the outcome of a fetch into the command's error and exit status
+func summaryResult(summary *archiver.FetchSummary) error {
+	if err := summary.Err(); err != nil {
+		if errors.Is(err, archiver.ErrUnauthorized) {
+			return withExitCode(ExitAuth, err)
+		}
+		return withExitCode(ExitPartialFailure, err)
+	}
+
+	switch {
+	case summary.Unavailable():
+		exitStatus = ExitClientUnreachable
+	case summary.Skipped():
+		exitStatus = ExitSkipped
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+)
+
+func TestExitCode(t *testing.T) {
+	cfg := &config.Config{
+		RTorrClients: map[string]config.RTorrConfig{"seedbox": {}},
+		Containers: map[string]config.Container{
+			"archive": {Client: "seedbox", Size: "1T", StalledTag: "stalled"},
+		},
+	}
+	_, invalid := archiver.NewClient(cfg, "test", "", "")
+	if invalid == nil {
+		t.Fatal("NewClient() error = nil, want the stalledTag on rTorrent rejected")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", want: ExitOK},
+		{name: "invalid config", err: fmt.Errorf("failed to create client: %w", invalid), want: ExitConfig},
+		{name: "unauthorized", err: fmt.Errorf("failed to fetch: %w", archiver.ErrUnauthorized), want: ExitAuth},
+		{name: "client unavailable", err: fmt.Errorf("%w seedbox", archiver.ErrClientUnavailable), want: ExitClientUnreachable},
+		{name: "explicit code", err: withExitCode(ExitOverdue, errors.New("scheduled fetch is overdue")), want: ExitOverdue},
+		{name: "other error", err: errors.New("failed"), want: ExitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
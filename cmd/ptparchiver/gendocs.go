@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+var (
+	docsDir string
+
+	genDocsCmd = &cobra.Command{
+		Use:    "gen-docs",
+		Short:  "Generate man pages and markdown docs for all commands",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE:   runGenDocs,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(genDocsCmd)
+
+	genDocsCmd.Flags().StringVar(&docsDir, "dir", "docs", "output directory, man pages go to <dir>/man and markdown to <dir>/markdown")
+}
+
+func runGenDocs(cmd *cobra.Command, args []string) error {
+	manDir := filepath.Join(docsDir, "man")
+	markdownDir := filepath.Join(docsDir, "markdown")
+
+	for _, dir := range []string{manDir, markdownDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("could not create docs directory: %w", err)
+		}
+	}
+
+	// the generated docs shouldn't differ between machines or runs
+	rootCmd.DisableAutoGenTag = true
+
+	header := &doc.GenManHeader{
+		Title:   "PTPARCHIVER",
+		Section: "1",
+		Source:  "ptparchiver",
+		Manual:  "PTP Archiver Manual",
+	}
+	if err := doc.GenManTree(rootCmd, header, manDir); err != nil {
+		return fmt.Errorf("failed to generate man pages: %w", err)
+	}
+
+	if err := doc.GenMarkdownTree(rootCmd, markdownDir); err != nil {
+		return fmt.Errorf("failed to generate markdown: %w", err)
+	}
+
+	log.Info().
+		Str("man", manDir).
+		Str("markdown", markdownDir).
+		Msg("generated documentation")
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// healthcheckGrace is how long a scheduled run may be overdue before the service is reported unhealthy
+const healthcheckGrace = 10 * time.Minute
+
+var healthcheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "Check the config, clients and schedule, exiting non-zero when unhealthy",
+	Args:  cobra.NoArgs,
+	RunE:  runHealthcheck,
+	Example: `  # Docker HEALTHCHECK
+  ptparchiver healthcheck || exit 1`,
+}
+
+func init() {
+	healthcheckCmd.GroupID = "operation"
+	rootCmd.AddCommand(healthcheckCmd)
+}
+
+func runHealthcheck(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+
+	clients := newClientCache(cfg)
+	for _, name := range slices.Sorted(maps.Keys(cfg.Containers)) {
+		container := cfg.Containers[name]
+
+		switch {
+		case container.UsesWatchDir():
+			for _, dir := range container.WatchDirectories() {
+				if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+					return withExitCode(ExitClientUnreachable, fmt.Errorf("container %s: watch directory %s is not available", name, dir))
+				}
+			}
+		case container.Client != "":
+			if _, err := clients.get(container.Client); err != nil {
+				return withExitCode(ExitClientUnreachable, fmt.Errorf("container %s: client %s is unreachable: %w", name, container.Client, err))
+			}
+		default:
+			return withExitCode(ExitConfig, fmt.Errorf("container %s must specify either watchDir or client", name))
+		}
+	}
+
+	// only a running service schedules fetches, so there's nothing to check without one
+	if nextRun := st.NextRun(); !nextRun.IsZero() {
+		if overdue := time.Since(nextRun); overdue > healthcheckGrace {
+			return withExitCode(ExitOverdue, fmt.Errorf("scheduled fetch is overdue by %s, is the run command still active?", formatDuration(overdue)))
+		}
+	}
+
+	log.Info().Int("containers", len(cfg.Containers)).Msg("healthy")
+	return nil
+}
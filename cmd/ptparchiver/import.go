@@ -0,0 +1,123 @@
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importDryRun bool
+
+	importCmd = &cobra.Command{
+		Use:   "import [container...]",
+		Short: "Add torrents already in the containers' categories to the history",
+		Long: `Add torrents already in the containers' categories to the history.
+
+Scans each container's category on its client and records every torrent the history
+doesn't know about yet, so archives started before using ptparchiver-go, or with the
+Python script, are included in stats and duplicate checks.`,
+		RunE:              runImport,
+		ValidArgsFunction: completeContainers,
+	}
+)
+
+func init() {
+	importCmd.GroupID = "setup"
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "show what would be imported without changing the history")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	names, err := selectContainers(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+
+	clients := newClientCache(cfg)
+	var entries []state.HistoryEntry
+	seen := make(map[string]struct{})
+
+	for _, name := range names {
+		container := cfg.Containers[name]
+		if container.Client == "" {
+			log.Debug().Str("container", name).Msg("skipping watch directory container, torrents can't be inspected")
+			continue
+		}
+		if container.Category == "" {
+			// listing without a category returns every torrent on the client
+			log.Warn().Str("container", name).Msg("skipping container without a category, its torrents can't be told apart from the rest of the client")
+			continue
+		}
+
+		tc, err := clients.get(container.Client)
+		if err != nil {
+			continue
+		}
+
+		torrents, err := tc.ListTorrents(container.Category)
+		if err != nil {
+			log.Error().Err(err).Str("container", name).Msg("failed to list torrents")
+			continue
+		}
+
+		imported := 0
+		for _, t := range torrents {
+			if _, ok := history.Get(t.Hash); ok {
+				continue
+			}
+			// containers sharing a client and category would otherwise import the same torrent twice
+			if _, ok := seen[t.Hash]; ok {
+				continue
+			}
+			seen[t.Hash] = struct{}{}
+
+			entries = append(entries, state.HistoryEntry{
+				Hash:      t.Hash,
+				Name:      t.Name,
+				Size:      t.Size,
+				Container: name,
+				Client:    container.Client,
+				AddedAt:   t.AddedOn,
+			})
+			imported++
+
+			log.Debug().Str("container", name).Str("torrent", t.Name).Msg("importing torrent")
+		}
+
+		log.Info().
+			Str("container", name).
+			Int("found", len(torrents)).
+			Int("new", imported).
+			Msg("scanned container category")
+	}
+
+	if importDryRun || len(entries) == 0 {
+		log.Info().Int("torrents", len(entries)).Bool("dryRun", importDryRun).Msg("nothing written to history")
+		return nil
+	}
+
+	if err := history.Add(entries...); err != nil {
+		log.Error().Err(err).Msg("failed to update history")
+		return err
+	}
+
+	log.Info().Int("torrents", len(entries)).Msg("imported torrents into history")
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	injectContainer string
+	injectTorrentID string
+
+	injectCmd = &cobra.Command{
+		Use:   "inject <file.torrent>",
+		Short: "Add a local torrent file to a container as if it was fetched",
+		Long: `Add a local torrent file to a container as if it was fetched from PTP, so archive assignments made
+outside of ptparchiver go through the same free space check, category and tags and are recorded in the
+history.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runInject,
+	}
+)
+
+func init() {
+	injectCmd.GroupID = "operation"
+	rootCmd.AddCommand(injectCmd)
+
+	injectCmd.Flags().StringVar(&injectContainer, "container", "", "container to add the torrent to")
+	injectCmd.Flags().StringVar(&injectTorrentID, "torrent-id", "", "PTP torrent ID to record in the history")
+	injectCmd.MarkFlagRequired("container")
+	injectCmd.RegisterFlagCompletionFunc("container", completeContainers)
+}
+
+func runInject(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := cfg.Containers[injectContainer]; !ok {
+		return withExitCode(ExitConfig, fmt.Errorf("container %s not found in config", injectContainer))
+	}
+
+	torrent, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read torrent file: %w", err)
+	}
+
+	client, err := archiver.NewClient(cfg, version.Version, version.Commit, version.Date)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create client")
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+	client.SetState(st)
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+	client.SetHistory(history)
+
+	result, err := client.Inject(injectContainer, torrent, injectTorrentID)
+	if err != nil {
+		return err
+	}
+	return manualAddResult(cmd, result, args[0], injectContainer)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const launchdLabel = "com.github.s0up4200.ptparchiver"
+
+var (
+	launchdFetch    bool
+	launchdInterval int
+	launchdOutput   string
+
+	launchdCmd = &cobra.Command{
+		Use:   "launchd",
+		Short: "Generate a macOS launchd plist for running ptparchiver",
+		Args:  cobra.NoArgs,
+		RunE:  runLaunchd,
+		Example: `  # Keep the archiver service running
+  ptparchiver launchd --output ~/Library/LaunchAgents/com.github.s0up4200.ptparchiver.plist
+  launchctl load ~/Library/LaunchAgents/com.github.s0up4200.ptparchiver.plist
+
+  # Run a single fetch every 6 hours instead
+  ptparchiver launchd --fetch --interval 360`,
+	}
+)
+
+func init() {
+	launchdCmd.GroupID = "setup"
+	rootCmd.AddCommand(launchdCmd)
+
+	launchdCmd.Flags().BoolVar(&launchdFetch, "fetch", false, "run a fetch periodically instead of keeping the service running")
+	launchdCmd.Flags().IntVar(&launchdInterval, "interval", 0, "minutes between fetches with --fetch, defaults to the config interval")
+	launchdCmd.Flags().StringVarP(&launchdOutput, "output", "o", "", "write the plist to this path instead of stdout")
+}
+
+var launchdTemplate = template.Must(template.New("plist").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Args}}
+		<string>{{xml .}}</string>
+{{- end}}
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+{{- if .StartInterval}}
+	<key>StartInterval</key>
+	<integer>{{.StartInterval}}</integer>
+{{- else}}
+	<key>KeepAlive</key>
+	<true/>
+{{- end}}
+	<key>StandardOutPath</key>
+	<string>{{xml .LogPath}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .LogPath}}</string>
+</dict>
+</plist>
+`))
+
+// xmlEscape escapes paths containing characters such as & for use in the plist
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func runLaunchd(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadPlainConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	// launchd doesn't run jobs from a working directory, so every path must be absolute
+	if configPath, err = filepath.Abs(configPath); err != nil {
+		return fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("determine home directory: %w", err)
+	}
+
+	data := struct {
+		Label         string
+		Args          []string
+		StartInterval int
+		LogPath       string
+	}{
+		Label:   launchdLabel,
+		Args:    []string{exe, "--config", configPath, "run"},
+		LogPath: filepath.Join(home, "Library", "Logs", "ptparchiver.log"),
+	}
+
+	if launchdFetch {
+		minutes := launchdInterval
+		if minutes == 0 {
+			minutes = cfg.Interval
+		}
+		if minutes < 1 {
+			return fmt.Errorf("--interval must be at least 1 minute")
+		}
+
+		data.Args = []string{exe, "--config", configPath, "fetch"}
+		data.StartInterval = minutes * 60
+	}
+
+	if launchdOutput == "" {
+		return launchdTemplate.Execute(cmd.OutOrStdout(), data)
+	}
+
+	f, err := os.Create(launchdOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create plist: %w", err)
+	}
+	defer f.Close()
+
+	if err := launchdTemplate.Execute(f, data); err != nil {
+		return fmt.Errorf("failed to write plist: %w", err)
+	}
+
+	log.Info().
+		Str("path", launchdOutput).
+		Str("load", fmt.Sprintf("launchctl load %s", launchdOutput)).
+		Msg("created launchd plist")
+	return nil
+}
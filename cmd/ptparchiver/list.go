@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listJSON bool
+
+	listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List configured containers or clients",
+	}
+
+	listContainersCmd = &cobra.Command{
+		Use:   "containers",
+		Short: "List configured containers",
+		Args:  cobra.NoArgs,
+		RunE:  runListContainers,
+	}
+
+	listClientsCmd = &cobra.Command{
+		Use:   "clients",
+		Short: "List configured torrent clients",
+		Args:  cobra.NoArgs,
+		RunE:  runListClients,
+		Example: `  # List clients as JSON
+  ptparchiver list clients --json`,
+	}
+)
+
+func init() {
+	listCmd.GroupID = "setup"
+	listCmd.AddCommand(listContainersCmd)
+	listCmd.AddCommand(listClientsCmd)
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.PersistentFlags().BoolVar(&listJSON, "json", false, "output as JSON")
+}
+
+type containerEntry struct {
+	Name        string   `json:"name"`
+	Client      string   `json:"client,omitempty"`
+	WatchDir    string   `json:"watchDir,omitempty"`
+	WatchDirs   []string `json:"watchDirs,omitempty"`
+	Size        string   `json:"size"`
+	MaxStalled  int      `json:"maxStalled"`
+	Category    string   `json:"category,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	StartPaused bool     `json:"startPaused"`
+}
+
+type clientEntry struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Address    string   `json:"address"`
+	Containers []string `json:"containers"`
+}
+
+func runListContainers(cmd *cobra.Command, args []string) error {
+	cfg, err := findAndLoadPlainConfig()
+	if err != nil {
+		return err
+	}
+
+	entries := make([]containerEntry, 0, len(cfg.Containers))
+	for _, name := range slices.Sorted(maps.Keys(cfg.Containers)) {
+		c := cfg.Containers[name]
+		entries = append(entries, containerEntry{
+			Name:        name,
+			Client:      c.Client,
+			WatchDir:    c.WatchDir,
+			WatchDirs:   c.WatchDirs,
+			Size:        c.Size,
+			MaxStalled:  c.MaxStalled,
+			Category:    c.Category,
+			Tags:        c.Tags,
+			StartPaused: c.StartPaused || c.AddPaused,
+		})
+	}
+
+	if listJSON {
+		return writeJSON(cmd.OutOrStdout(), entries)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTARGET\tSIZE\tMAX STALLED\tCATEGORY\tTAGS")
+	for _, e := range entries {
+		target := e.Client
+		if dirs := (config.Container{WatchDir: e.WatchDir, WatchDirs: e.WatchDirs}).WatchDirectories(); len(dirs) > 0 {
+			target = "watch: " + strings.Join(dirs, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			e.Name, valueOrDash(target), e.Size, e.MaxStalled, valueOrDash(e.Category), valueOrDash(strings.Join(e.Tags, ",")))
+	}
+	return w.Flush()
+}
+
+func runListClients(cmd *cobra.Command, args []string) error {
+	cfg, err := findAndLoadPlainConfig()
+	if err != nil {
+		return err
+	}
+
+	var entries []clientEntry
+	for _, name := range slices.Sorted(maps.Keys(cfg.QBitClients)) {
+		entries = append(entries, newClientEntry(cfg, name, cfg.QBitClients[name].URL))
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.RTorrClients)) {
+		entries = append(entries, newClientEntry(cfg, name, cfg.RTorrClients[name].URL))
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.DelugeClients)) {
+		d := cfg.DelugeClients[name]
+		entries = append(entries, newClientEntry(cfg, name, fmt.Sprintf("%s:%d", d.Host, d.Port)))
+	}
+
+	if listJSON {
+		if entries == nil {
+			entries = []clientEntry{}
+		}
+		return writeJSON(cmd.OutOrStdout(), entries)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tADDRESS\tCONTAINERS")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Name, e.Type, e.Address, valueOrDash(strings.Join(e.Containers, ",")))
+	}
+	return w.Flush()
+}
+
+// clientNames returns the names of all configured torrent clients, grouped by type
+func clientNames(cfg *config.Config) []string {
+	names := slices.Sorted(maps.Keys(cfg.QBitClients))
+	names = append(names, slices.Sorted(maps.Keys(cfg.RTorrClients))...)
+	names = append(names, slices.Sorted(maps.Keys(cfg.DelugeClients))...)
+	return names
+}
+
+// newClientEntry describes a client without exposing its credentials
+func newClientEntry(cfg *config.Config, name, address string) clientEntry {
+	containers := []string{}
+	for _, containerName := range slices.Sorted(maps.Keys(cfg.Containers)) {
+		if cfg.Containers[containerName].Client == name {
+			containers = append(containers, containerName)
+		}
+	}
+
+	return clientEntry{
+		Name:       name,
+		Type:       client.Type(cfg, name),
+		Address:    address,
+		Containers: containers,
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -1,60 +1,77 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"syscall"
 	"time"
+	_ "time/tzdata" // time zones for systems without a zoneinfo database, such as Windows
 
+	"github.com/mattn/go-isatty"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
 	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/state"
 	"github.com/s0up4200/ptparchiver-go/pkg/version"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
 
 func init() {
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339})
+	setupLogger()
 }
 
 func main() {
 	version.Initialize()
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
-	}
+	code := exitCode(rootCmd.Execute())
+	cleanupMock()
+	os.Exit(code)
 }
 
 var (
 	cfgFile string
 	debug   bool
+	offline bool
+	color   bool
+	noColor bool
 
 	rootCmd = &cobra.Command{
 		Use:   "ptparchiver",
 		Short: "PTP Archiver downloads and manages torrents from PTP",
-		CompletionOptions: cobra.CompletionOptions{
-			DisableDefaultCmd: true,
-		},
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			setupLogger()
 			zerolog.SetGlobalLevel(zerolog.InfoLevel)
 			if debug {
 				zerolog.SetGlobalLevel(zerolog.DebugLevel)
 			}
+			setupRecording()
 		},
 	}
 
 	fetchCmd = &cobra.Command{
-		Use:   "fetch [container]",
-		Short: "Fetch torrents for specified container or all containers",
-		Args:  cobra.MaximumNArgs(1),
+		Use:   "fetch [container...]",
+		Short: "Fetch torrents for specified containers or all containers",
 		RunE:  runFetch,
 		Example: `  # Fetch torrents for all containers
   ptparchiver fetch
 
   # Fetch torrents for a specific container
-  ptparchiver fetch hetzner`,
+  ptparchiver fetch hetzner
+
+  # Fetch torrents for several containers
+  ptparchiver fetch hetzner homelab
+
+  # Fetch up to 5 torrents for a specific container
+  ptparchiver fetch hetzner --count 5`,
 	}
 
 	initCmd = &cobra.Command{
@@ -71,21 +88,43 @@ var (
   ptparchiver run
 
   # Run with custom interval (in minutes)
-  ptparchiver run --interval 30`,
+  ptparchiver run --interval 30
+
+  # Fetch once and exit, e.g. from cron
+  ptparchiver run --once
+
+  # Wait 5 minutes after boot before the first fetch
+  ptparchiver run --startup-delay 5`,
 	}
 
-	interval int
+	interval     int
+	runOnce      bool
+	startupDelay int
+	fetchCount   int
+	versionJSON  bool
+	forceUpdate  bool
 
 	versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Show version information and check for updates",
 		RunE:  runVersion,
 	}
+
+	selfUpdateCmd = &cobra.Command{
+		Use:   "self-update",
+		Short: "Update ptparchiver to the latest release",
+		Args:  cobra.NoArgs,
+		RunE:  runSelfUpdate,
+	}
 )
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable debug logging")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "never contact GitHub to check for updates")
+	rootCmd.PersistentFlags().BoolVar(&color, "color", false, "always color log output")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "never color log output")
+	rootCmd.MarkFlagsMutuallyExclusive("color", "no-color")
 
 	setupGroup := &cobra.Group{
 		ID:    "setup",
@@ -107,11 +146,52 @@ func init() {
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(fetchCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
 
 	runCmd.Flags().IntVar(&interval, "interval", 360, "fetch interval in minutes")
+	runCmd.Flags().BoolVar(&runOnce, "once", false, "fetch for every container once and exit")
+	runCmd.Flags().IntVar(&startupDelay, "startup-delay", 0, "minutes to wait before the first fetch, defaults to the config startupDelay")
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "output version information as JSON")
+	selfUpdateCmd.Flags().BoolVar(&forceUpdate, "force", false, "reinstall the latest release even if already up to date")
+	fetchCmd.Flags().IntVar(&fetchCount, "count", 1, "number of torrents to fetch for each container")
+}
+
+// setupLogger writes human readable logs to stdout, only using colors when stdout is a terminal
+// unless overridden with --color, --no-color or the NO_COLOR environment variable
+func setupLogger() {
+	useColor := isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+	switch {
+	case noColor:
+		useColor = false
+	case color:
+		useColor = true
+	case os.Getenv("NO_COLOR") != "":
+		useColor = false
+	}
+
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339, NoColor: !useColor})
 }
 
 func findConfig() (string, error) {
+	if config.Configless() {
+		return configlessPath()
+	}
+
+	configPath, err := locateConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("could not determine home directory")
+		return "", err
+	}
+	if configPath == "" {
+		configDir, _ := defaultConfigDir()
+		log.Error().Str("config_dir", configDir).Msg("no config file found")
+		return "", withExitCode(ExitConfig, fmt.Errorf("no config file found in current directory or %s", configDir))
+	}
+	return configPath, nil
+}
+
+// locateConfig returns the config file path, or an empty string if there is none
+func locateConfig() (string, error) {
 	if cfgFile != "" {
 		return cfgFile, nil
 	}
@@ -122,41 +202,256 @@ func findConfig() (string, error) {
 	}
 
 	// Check ~/.config/ptparchiver-go/
-	home, err := os.UserHomeDir()
+	configDir, err := defaultConfigDir()
 	if err != nil {
-		log.Error().Err(err).Msg("could not determine home directory")
 		return "", err
 	}
 
-	configDir := filepath.Join(home, ".config", "ptparchiver-go")
 	configPath := filepath.Join(configDir, "config.yaml")
 	if _, err := os.Stat(configPath); err == nil {
 		return configPath, nil
 	}
 
-	log.Error().Str("config_dir", configDir).Msg("no config file found")
-	return "", fmt.Errorf("no config file found in current directory or %s", configDir)
+	return "", nil
 }
 
+// configlessPath returns where the config file would be when running from environment variables,
+// so the state and history files still have a home
+func configlessPath() (string, error) {
+	configPath := cfgFile
+	if configPath == "" {
+		configDir, err := defaultConfigDir()
+		if err != nil {
+			log.Error().Err(err).Msg("could not determine home directory")
+			return "", err
+		}
+		configPath = filepath.Join(configDir, "config.yaml")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		log.Error().Err(err).Str("dir", filepath.Dir(configPath)).Msg("could not create state directory")
+		return "", fmt.Errorf("could not create state directory: %w", err)
+	}
+
+	return configPath, nil
+}
+
+func defaultConfigDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "ptparchiver-go"), nil
+}
+
+// loadConfig loads the config, decrypting encrypted values and resolving secret references
 func loadConfig(path string) (*config.Config, error) {
+	return readConfig(path, true)
+}
+
+// loadPlainConfig loads the config leaving encrypted values and secret references as they are, for commands
+// that never use credentials, so they don't prompt for the passphrase or call a secret manager
+func loadPlainConfig(path string) (*config.Config, error) {
+	return readConfig(path, false)
+}
+
+func readConfig(path string, secrets bool) (*config.Config, error) {
+	if config.Configless() {
+		log.Debug().Msg("loading config from environment")
+
+		cfg, err := config.FromEnv()
+		if err != nil {
+			log.Error().Err(err).Msg("failed to load config from environment")
+			return nil, withExitCode(ExitConfig, fmt.Errorf("failed to load config from environment: %w", err))
+		}
+		if mockMode {
+			if err := applyMock(cfg); err != nil {
+				return nil, err
+			}
+			return cfg, applyTimezone(cfg)
+		}
+		if !secrets {
+			return cfg, applyTimezone(cfg)
+		}
+		if err := resolveSecrets(cfg); err != nil {
+			return nil, err
+		}
+		return cfg, applyTimezone(cfg)
+	}
+
 	log.Debug().Str("path", path).Msg("loading config file")
 
 	data, err := os.ReadFile(path)
 	if err != nil {
 		log.Error().Err(err).Str("path", path).Msg("failed to read config file")
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, withExitCode(ExitConfig, fmt.Errorf("failed to read config file: %w", err))
 	}
 
 	var cfg config.Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		log.Error().Err(err).Str("path", path).Msg("failed to parse config file")
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+		return nil, withExitCode(ExitConfig, fmt.Errorf("failed to parse config file: %w", err))
+	}
+
+	if mockMode {
+		if err := applyMock(&cfg); err != nil {
+			return nil, err
+		}
+		return &cfg, applyTimezone(&cfg)
+	}
+	if !secrets {
+		return &cfg, applyTimezone(&cfg)
+	}
+
+	if cfg.Encrypted() {
+		passphrase, err := readPassphrase(false)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get config passphrase")
+			return nil, withExitCode(ExitConfig, err)
+		}
+		if err := cfg.Decrypt(passphrase); err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed to decrypt config file")
+			return nil, withExitCode(ExitConfig, fmt.Errorf("failed to decrypt config file: %w", err))
+		}
+	}
+
+	if err := resolveSecrets(&cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, applyTimezone(&cfg)
+}
+
+// resolveSecrets replaces vault: and op:// references in the credentials with their values
+func resolveSecrets(cfg *config.Config) error {
+	if err := cfg.ResolveSecrets(context.Background()); err != nil {
+		log.Error().Err(err).Msg("failed to resolve secret reference")
+		return withExitCode(ExitConfig, fmt.Errorf("failed to resolve secret reference: %w", err))
+	}
+	return nil
+}
+
+// applyTimezone makes the configured time zone the local time zone, so log timestamps and
+// scheduled run times are shown in it
+func applyTimezone(cfg *config.Config) error {
+	if cfg.Timezone == "" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		log.Error().Err(err).Str("timezone", cfg.Timezone).Msg("invalid timezone")
+		return withExitCode(ExitConfig, fmt.Errorf("invalid timezone %q: %w", cfg.Timezone, err))
+	}
+
+	time.Local = loc
+	return nil
+}
+
+// findAndLoadConfig locates and loads the config file
+func findAndLoadConfig() (*config.Config, error) {
+	configPath, err := findConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return loadConfig(configPath)
+}
+
+// findAndLoadPlainConfig locates and loads the config like findAndLoadConfig, without secrets, see loadPlainConfig
+func findAndLoadPlainConfig() (*config.Config, error) {
+	configPath, err := findConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return loadPlainConfig(configPath)
+}
+
+// selectContainers validates the container names given on the command line, defaulting to all containers
+func selectContainers(cfg *config.Config, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return slices.Sorted(maps.Keys(cfg.Containers)), nil
+	}
+
+	for _, name := range args {
+		if _, ok := cfg.Containers[name]; !ok {
+			return nil, fmt.Errorf("container %s not found", name)
+		}
+	}
+	return args, nil
+}
+
+// clientCache connects to each torrent client at most once per command
+type clientCache struct {
+	cfg   *config.Config
+	conns map[string]clientConn
+}
+
+type clientConn struct {
+	client client.TorrentClient
+	err    error
+}
+
+func newClientCache(cfg *config.Config) *clientCache {
+	return &clientCache{
+		cfg:   cfg,
+		conns: make(map[string]clientConn),
+	}
+}
+
+// get returns the connected client, remembering failures so unreachable clients are only tried once
+func (c *clientCache) get(name string) (client.TorrentClient, error) {
+	conn, ok := c.conns[name]
+	if !ok {
+		conn.client, conn.err = client.New(c.cfg, name)
+		if conn.err != nil {
+			log.Error().Err(conn.err).Str("client", name).Msg("failed to connect to client")
+		}
+		c.conns[name] = conn
+	}
+	return conn.client, conn.err
+}
+
+// loadState loads the state file that belongs to the config file
+func loadState(configPath string) (*state.State, error) {
+	path, err := mockPath(state.PathFor(configPath))
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Str("path", path).Msg("loading state file")
+
+	st, err := state.Load(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed to load state file")
+		return nil, err
 	}
 
-	return &cfg, nil
+	return st, nil
+}
+
+// loadHistory loads the history file that belongs to the config file
+func loadHistory(configPath string) (*state.History, error) {
+	path, err := mockPath(state.HistoryPathFor(configPath))
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Str("path", path).Msg("loading history file")
+
+	history, err := state.LoadHistory(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed to load history file")
+		return nil, err
+	}
+
+	return history, nil
 }
 
 func runFetch(cmd *cobra.Command, args []string) error {
+	if fetchCount < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+
 	configPath, err := findConfig()
 	if err != nil {
 		return err
@@ -173,11 +468,28 @@ func runFetch(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create client: %w", err)
 	}
 
-	if len(args) == 0 {
-		return client.FetchAll()
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
 	}
+	client.SetState(st)
 
-	return client.FetchForContainer(args[0])
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+	client.SetHistory(history)
+
+	containers, err := selectContainers(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	summary, err := client.FetchContainers(containers, fetchCount)
+	if err != nil {
+		return err
+	}
+	return summaryResult(summary)
 }
 
 func runInit(cmd *cobra.Command, args []string) error {
@@ -316,43 +628,122 @@ func runService(cmd *cobra.Command, args []string) error {
 	if !cmd.Flags().Changed("interval") && cfg.Interval > 0 {
 		interval = cfg.Interval
 	}
+	// backoff stretches the interval actually in use
+	cfg.Interval = interval
 
 	log.Info().
 		Int("interval", interval).
 		Str("schedule", fmt.Sprintf("every %d minutes", interval)).
 		Msg("starting archiver service")
 
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+
+	if !runOnce {
+		// the schedule of an earlier run, which may have had other containers, no longer applies
+		if err := st.ClearSchedule(); err != nil {
+			log.Warn().Err(err).Msg("failed to clear the previous schedule")
+		}
+		defer func() {
+			if err := st.ClearSchedule(); err != nil {
+				log.Warn().Err(err).Msg("failed to clear the schedule")
+			}
+		}()
+	}
+
+	if !cmd.Flags().Changed("startup-delay") {
+		startupDelay = cfg.StartupDelay
+	}
+	// give torrent clients and network mounts time to come up after boot before connecting to them
+	if startupDelay > 0 && !runOnce {
+		delay := time.Duration(startupDelay) * time.Minute
+		for name := range cfg.Containers {
+			recordNextFetch(st, name, time.Now().Add(delay), time.Now().Add(delay))
+		}
+		log.Info().Msgf("waiting %s before the first fetch", formatDuration(delay))
+		time.Sleep(delay)
+	}
+
 	client, err := archiver.NewClient(cfg, version.Version, version.Commit, version.Date)
 	if err != nil {
 		return fmt.Errorf("failed to create client: %w", err)
 	}
+	client.SetState(st)
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+	client.SetHistory(history)
 
-	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
-	defer ticker.Stop()
+	// invalid credentials would fail every container on every run, so stop early with a clear error
+	if err := client.CheckCredentials(); errors.Is(err, archiver.ErrUnauthorized) {
+		log.Error().Err(err).Msg("invalid PTP credentials, check apiUser and apiKey")
+		return err
+	} else if err != nil {
+		log.Warn().Err(err).Msg("failed to verify PTP credentials")
+	}
 
-	nextRun := time.Now().Add(time.Duration(interval) * time.Minute)
+	if runOnce {
+		summary, err := client.FetchAll()
+		if err != nil {
+			return err
+		}
+		return summaryResult(summary)
+	}
 
-	// initial fetch
-	if err := client.FetchAll(); err != nil {
-		log.Error().Err(err).Msg("failed to fetch torrents")
+	if !offline && !cfg.DisableUpdateCheck {
+		go watchForUpdates(client)
+	}
+
+	if cfg.AuditInterval > 0 {
+		go watchAudits(client, time.Duration(cfg.AuditInterval)*time.Minute)
 	}
-	log.Info().
-		Time("nextRun", nextRun).
-		Msgf("scheduling next fetch in %s", formatDuration(time.Until(nextRun)))
 
-	for range ticker.C {
-		log.Info().Msg("performing scheduled fetch")
-		if err := client.FetchAll(); err != nil {
-			log.Error().Err(err).Msg("failed to fetch torrents")
+	containers := slices.Sorted(maps.Keys(cfg.Containers))
+	triggers := make(chan []string)
+	notifyTriggerSignal(triggers)
+	if cfg.ControlSocket != "" {
+		listener, err := listenControl(cfg.ControlSocket, containers, triggers)
+		if err != nil {
+			log.Error().Err(err).Str("path", cfg.ControlSocket).Msg("failed to start control socket")
+			return err
+		}
+		defer listener.Close()
+	}
+	if cfg.PprofAddress != "" {
+		if err := listenPprof(cfg.PprofAddress); err != nil {
+			log.Error().Err(err).Str("address", cfg.PprofAddress).Msg("failed to start pprof listener")
+			return err
 		}
-		nextRun = time.Now().Add(time.Duration(interval) * time.Minute)
-		log.Info().
-			Time("nextRun", nextRun).
-			Msgf("scheduling next fetch in %s", formatDuration(time.Until(nextRun)))
+	}
+
+	// return on shutdown so the deferred cleanup, such as removing the control socket, runs
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		scheduleContainers(client, st, containers, time.Duration(interval)*time.Minute, triggers)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case sig := <-stop:
+		log.Info().Str("signal", sig.String()).Msg("shutting down")
 	}
 	return nil
 }
 
+// recordNextFetch stores the next scheduled run and the container's next fetch so the status command can show them
+func recordNextFetch(st *state.State, name string, nextRun, nextFetch time.Time) {
+	if err := st.SetNextFetch(name, nextRun, nextFetch); err != nil {
+		log.Warn().Err(err).Str("container", name).Msg("failed to record next fetch")
+	}
+}
+
 // formatDuration converts a duration to a human-readable string
 func formatDuration(d time.Duration) string {
 	hours := int(d.Hours())
@@ -367,6 +758,77 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%d minutes", minutes)
 }
 
+// formatETA converts a long duration to days, falling back to formatDuration below a day
+func formatETA(d time.Duration) string {
+	if days := int(d.Hours() / 24); days > 0 {
+		return fmt.Sprintf("~%d days", days)
+	}
+	return formatDuration(d)
+}
+
 func runVersion(cmd *cobra.Command, args []string) error {
+	disabled := updateChecksDisabled()
+
+	if versionJSON {
+		if disabled {
+			return writeJSON(cmd.OutOrStdout(), version.Current())
+		}
+
+		info, err := version.Check("s0up4200", "ptparchiver-go")
+		if err != nil {
+			return err
+		}
+		return writeJSON(cmd.OutOrStdout(), info)
+	}
+
+	if disabled {
+		version.LogVersion("ptparchiver-go")
+		log.Info().Msg("update checks are disabled")
+		return nil
+	}
+
 	return version.CheckForUpdates("s0up4200", "ptparchiver-go")
 }
+
+// updateChecksDisabled reports whether GitHub release lookups are turned off with --offline or the config
+func updateChecksDisabled() bool {
+	if offline {
+		return true
+	}
+
+	// a missing or broken config shouldn't stop the version command from working
+	configPath, err := locateConfig()
+	if err != nil || configPath == "" {
+		return false
+	}
+
+	cfg, err := loadPlainConfig(configPath)
+	if err != nil {
+		return false
+	}
+
+	return cfg.DisableUpdateCheck
+}
+
+func runSelfUpdate(cmd *cobra.Command, args []string) error {
+	if updateChecksDisabled() {
+		return fmt.Errorf("update checks are disabled, remove --offline or disableUpdateCheck from the config to update")
+	}
+
+	tag, err := version.SelfUpdate("s0up4200", "ptparchiver-go", forceUpdate)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to update")
+		return fmt.Errorf("failed to update: %w", err)
+	}
+
+	if tag == "" {
+		log.Info().Str("version", version.Version).Msg("you are running the latest version")
+		return nil
+	}
+
+	log.Info().
+		Str("previous", version.Version).
+		Str("current", tag).
+		Msg("successfully updated ptparchiver")
+	return nil
+}
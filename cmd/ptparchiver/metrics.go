@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Print fetch and archive metrics in Prometheus text format",
+	Args:  cobra.NoArgs,
+	RunE:  runMetrics,
+	Example: `  # Inspect the current metrics
+  ptparchiver metrics
+
+  # Export for the node_exporter textfile collector
+  ptparchiver metrics > /var/lib/node_exporter/ptparchiver.prom.tmp && mv /var/lib/node_exporter/ptparchiver.prom.tmp /var/lib/node_exporter/ptparchiver.prom`,
+}
+
+func init() {
+	metricsCmd.GroupID = "operation"
+	rootCmd.AddCommand(metricsCmd)
+}
+
+// metricsWriter writes Prometheus text exposition format, emitting HELP and TYPE once per metric
+type metricsWriter struct {
+	w       io.Writer
+	written map[string]bool
+}
+
+func (m *metricsWriter) write(name, typ, help string, labels map[string]string, value float64) {
+	if !m.written[name] {
+		fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		m.written[name] = true
+	}
+
+	if len(labels) == 0 {
+		fmt.Fprintf(m.w, "%s %s\n", name, strconv.FormatFloat(value, 'f', -1, 64))
+		return
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	fmt.Fprintf(m.w, "%s{%s} %s\n", name, strings.Join(pairs, ","), strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func runMetrics(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadPlainConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+
+	m := &metricsWriter{w: cmd.OutOrStdout(), written: make(map[string]bool)}
+	names := slices.Sorted(maps.Keys(cfg.Containers))
+
+	for _, name := range names {
+		cs, _ := st.Container(name)
+		for _, result := range slices.Sorted(maps.Keys(cs.Results)) {
+			m.write("ptparchiver_fetches_total", "counter", "Fetch attempts by result.",
+				map[string]string{"container": name, "result": result}, float64(cs.Results[result]))
+		}
+	}
+
+	for _, name := range names {
+		cs, _ := st.Container(name)
+		if !cs.LastFetch.IsZero() {
+			m.write("ptparchiver_last_fetch_timestamp_seconds", "gauge", "Time of the last fetch attempt.",
+				map[string]string{"container": name}, float64(cs.LastFetch.Unix()))
+		}
+	}
+
+	for _, name := range names {
+		paused := 0.0
+		if st.IsPaused(name) {
+			paused = 1
+		}
+		m.write("ptparchiver_container_paused", "gauge", "Whether fetching is paused for the container.",
+			map[string]string{"container": name}, paused)
+	}
+
+	// midnight in the local (or configured) time zone
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	torrents := make(map[string]int)
+	archived := make(map[string]int64)
+	archivedToday := make(map[string]int64)
+	for _, e := range history.Entries() {
+		torrents[e.Container]++
+		archived[e.Container] += e.Size
+		if !e.AddedAt.Before(today) {
+			archivedToday[e.Container] += e.Size
+		}
+	}
+
+	for _, name := range names {
+		m.write("ptparchiver_archived_torrents_total", "counter", "Torrents added for the container.",
+			map[string]string{"container": name}, float64(torrents[name]))
+	}
+	for _, name := range names {
+		m.write("ptparchiver_archived_bytes_total", "counter", "Bytes of torrents added for the container.",
+			map[string]string{"container": name}, float64(archived[name]))
+	}
+	for _, name := range names {
+		m.write("ptparchiver_archived_bytes_today", "gauge", "Bytes of torrents added for the container since midnight.",
+			map[string]string{"container": name}, float64(archivedToday[name]))
+	}
+
+	for _, name := range clientNames(cfg) {
+		if eta, ok := st.TimeUntilFull(name); ok {
+			m.write("ptparchiver_client_seconds_until_full", "gauge", "Estimated time until the client runs out of space, from the last week of free space samples.",
+				map[string]string{"client": name}, eta.Seconds())
+		}
+	}
+
+	if !st.LastAudit.IsZero() {
+		m.write("ptparchiver_last_audit_timestamp_seconds", "gauge", "Time of the last seeding audit.",
+			nil, float64(st.LastAudit.Unix()))
+		m.write("ptparchiver_audit_problems", "gauge", "Archived torrents that failed the last seeding audit.",
+			nil, float64(st.AuditProblems))
+	}
+
+	if nextRun := st.NextRun(); !nextRun.IsZero() {
+		m.write("ptparchiver_next_run_timestamp_seconds", "gauge", "Time of the next scheduled fetch.",
+			nil, float64(nextRun.Unix()))
+
+		for _, name := range names {
+			if cs, _ := st.Container(name); !cs.NextFetch.IsZero() && !st.IsPaused(name) {
+				m.write("ptparchiver_container_next_fetch_timestamp_seconds", "gauge", "Time of the next fetch for the container, after any backoff.",
+					map[string]string{"container": name}, float64(cs.NextFetch.Unix()))
+			}
+		}
+	}
+
+	return nil
+}
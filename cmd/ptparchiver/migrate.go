@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// tagReplacer is implemented by clients that support tags
+type tagReplacer interface {
+	ReplaceTag(hash, from, to string) error
+}
+
+var (
+	migrateClient    string
+	migrateFrom      string
+	migrateTo        string
+	migrateTags      []string
+	migrateContainer string
+	migrateDryRun    bool
+	migrateYes       bool
+
+	migrateCategoryCmd = &cobra.Command{
+		Use:   "migrate-category",
+		Short: "Rename the category and tags of the archive torrents on a client",
+		Long: `Rename the category/label and tags of the archive torrents on a client, for when
+containers are reorganized.
+
+Every torrent in the --from category is moved to the --to category, which is created
+when missing, and each --tag is replaced (qBittorrent only). The history entries of
+the moved torrents are assigned to --container, or to the container configured with
+the new category on this client when there's exactly one.
+
+qBittorrent moves the data of torrents in Automatic Torrent Management mode to the
+save path of the new category. Update the category of the container in the config
+yourself, otherwise the next fetch keeps adding to the old one.`,
+		Args: cobra.NoArgs,
+		RunE: runMigrateCategory,
+		Example: `  # Preview renaming a category
+  ptparchiver migrate-category --client qbit --from ptp-archive --to ptp-archive-4k --dry-run
+
+  # Rename it along with a tag and assign the history to a container
+  ptparchiver migrate-category --client qbit --from ptp-archive --to ptp-archive-4k --tag archive=archive-4k --container uhd`,
+	}
+)
+
+func init() {
+	migrateCategoryCmd.GroupID = "operation"
+	rootCmd.AddCommand(migrateCategoryCmd)
+
+	migrateCategoryCmd.Flags().StringVar(&migrateClient, "client", "", "client the torrents are on")
+	migrateCategoryCmd.Flags().StringVar(&migrateFrom, "from", "", "current category/label of the torrents")
+	migrateCategoryCmd.Flags().StringVar(&migrateTo, "to", "", "new category/label of the torrents")
+	migrateCategoryCmd.Flags().StringArrayVar(&migrateTags, "tag", nil, "rename a tag, as old=new (repeatable, qBittorrent only)")
+	migrateCategoryCmd.Flags().StringVar(&migrateContainer, "container", "", "container to assign the torrents to in the history")
+	migrateCategoryCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "show what would be changed without changing anything")
+	migrateCategoryCmd.Flags().BoolVarP(&migrateYes, "yes", "y", false, "don't ask for confirmation")
+
+	for _, flag := range []string{"client", "from", "to"} {
+		migrateCategoryCmd.MarkFlagRequired(flag)
+	}
+	migrateCategoryCmd.RegisterFlagCompletionFunc("client", completeClients)
+	migrateCategoryCmd.RegisterFlagCompletionFunc("container", completeContainers)
+}
+
+// migrateTarget returns the only container using category on the client, or "" if there's none or several
+func migrateTarget(cfg *config.Config, clientName, category string) string {
+	var names []string
+	for name, container := range cfg.Containers {
+		if container.Client == clientName && container.Category == category {
+			names = append(names, name)
+		}
+	}
+	if len(names) != 1 {
+		return ""
+	}
+	return names[0]
+}
+
+func runMigrateCategory(cmd *cobra.Command, args []string) error {
+	// an empty category lists every torrent on the client
+	if migrateFrom == "" || migrateTo == "" {
+		return fmt.Errorf("--from and --to must not be empty")
+	}
+	if migrateFrom == migrateTo && len(migrateTags) == 0 {
+		return fmt.Errorf("--from and --to must be different categories")
+	}
+	tags := make(map[string]string, len(migrateTags))
+	for _, m := range migrateTags {
+		from, to, ok := strings.Cut(m, "=")
+		if !ok || from == "" || to == "" {
+			return fmt.Errorf("invalid --tag %q, expected old=new", m)
+		}
+		tags[from] = to
+	}
+
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	container := migrateContainer
+	if container != "" {
+		if _, ok := cfg.Containers[container]; !ok {
+			return fmt.Errorf("container %s not found", container)
+		}
+	} else {
+		container = migrateTarget(cfg, migrateClient, migrateTo)
+	}
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+
+	tc, err := newClientCache(cfg).get(migrateClient)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", migrateClient, err)
+	}
+	replacer, canTag := tc.(tagReplacer)
+	if len(tags) > 0 && !canTag {
+		return fmt.Errorf("client %s doesn't support tags", migrateClient)
+	}
+
+	torrents, err := tc.ListTorrents(migrateFrom)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents on %s: %w", migrateClient, err)
+	}
+	if len(torrents) == 0 {
+		log.Info().Str("client", migrateClient).Str("category", migrateFrom).Msg("no torrents to migrate")
+		return nil
+	}
+	sort.Slice(torrents, func(i, j int) bool { return torrents[i].Name < torrents[j].Name })
+
+	var totalSize int64
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TORRENT\tSIZE\tTAGS")
+	for _, t := range torrents {
+		totalSize += t.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, units.HumanSize(float64(t.Size)), strings.Join(migratedTags(t, tags), ","))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "\n%d torrents (%s) on %s from %s to %s\n",
+		len(torrents), units.HumanSize(float64(totalSize)), migrateClient, migrateFrom, migrateTo)
+	if container != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "History entries are assigned to container %s\n", container)
+	}
+
+	if migrateDryRun {
+		return nil
+	}
+
+	if !migrateYes {
+		fmt.Fprint(cmd.OutOrStdout(), "Continue? [y/N] ")
+		answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			log.Info().Msg("aborted")
+			return nil
+		}
+	}
+
+	migrated, failed := 0, 0
+	for _, t := range torrents {
+		if migrateFrom != migrateTo {
+			if err := tc.SetCategory(t.Hash, migrateTo); err != nil {
+				log.Error().Err(err).Str("torrent", t.Name).Msg("failed to set category")
+				failed++
+				continue
+			}
+		}
+
+		tagErr := false
+		for _, tag := range t.Tags {
+			to, ok := tags[tag]
+			if !ok {
+				continue
+			}
+			if err := replacer.ReplaceTag(t.Hash, tag, to); err != nil {
+				log.Error().Err(err).Str("torrent", t.Name).Str("tag", tag).Msg("failed to replace tag")
+				tagErr = true
+			}
+		}
+		if tagErr {
+			failed++
+			continue
+		}
+
+		if entry, ok := history.Get(t.Hash); ok && container != "" && entry.Container != container {
+			entry.Container = container
+			if err := history.Add(entry); err != nil {
+				log.Warn().Err(err).Str("torrent", t.Name).Msg("failed to update history")
+			}
+		}
+
+		log.Debug().Str("torrent", t.Name).Str("category", migrateTo).Msg("migrated torrent")
+		migrated++
+	}
+
+	log.Info().Int("migrated", migrated).Int("failed", failed).Msg("migrated torrents")
+
+	for name, c := range cfg.Containers {
+		if c.Client == migrateClient && c.Category == migrateFrom && migrateFrom != migrateTo {
+			log.Warn().Str("container", name).Msgf("container still uses category %s, update it in the config", migrateFrom)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d torrents failed to migrate", failed, len(torrents))
+	}
+	return nil
+}
+
+// migratedTags returns the tags of the torrent once the renames are applied
+func migratedTags(t client.Torrent, tags map[string]string) []string {
+	result := make([]string, 0, len(t.Tags))
+	for _, tag := range t.Tags {
+		if to, ok := tags[tag]; ok {
+			tag = to
+		}
+		if !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/mock"
+)
+
+// mockMode runs the command against a fake PTP and in-memory torrent clients
+var mockMode bool
+
+// mockEnv is the fake PTP and scratch directory of --mock, which the state, history and watch
+// directories are moved into so nothing real is touched
+var mockEnv struct {
+	server *mock.Server
+	dir    string
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&mockMode, "mock", false, "use a built-in fake PTP and in-memory torrent clients instead of the configured ones")
+}
+
+// startMock starts the fake PTP and creates the mock directory on first use
+func startMock() error {
+	if mockEnv.server != nil {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "ptparchiver-mock-")
+	if err != nil {
+		return fmt.Errorf("failed to create mock directory: %w", err)
+	}
+
+	mockEnv.dir = dir
+	mockEnv.server = mock.NewServer()
+	client.UseFakeClients(mock.NewClients().Get)
+
+	log.Warn().
+		Str("url", mockEnv.server.URL()).
+		Str("dir", dir).
+		Msg("running in mock mode, nothing is fetched from PTP or added to real clients")
+	return nil
+}
+
+// applyMock points the config at the fake PTP and makes every torrent client an in-memory one.
+// Credentials aren't needed, so they are replaced rather than decrypted or resolved.
+func applyMock(cfg *config.Config) error {
+	if err := startMock(); err != nil {
+		return err
+	}
+
+	cfg.BaseURL = mockEnv.server.URL()
+	cfg.ApiUser = "mock"
+	cfg.ApiKey = "mock"
+	cfg.DisableUpdateCheck = true
+	// fake fetches shouldn't end up in real dashboards
+	cfg.Influx = config.Influx{}
+	cfg.Statsd = config.Statsd{}
+	if cfg.ControlSocket != "" {
+		cfg.ControlSocket = filepath.Join(mockEnv.dir, "control.sock")
+	}
+
+	for name, container := range cfg.Containers {
+		container.ApiUser, container.ApiKey, container.BaseURL = "", "", ""
+		if container.OversizeDir != "" {
+			container.OversizeDir = filepath.Join(mockEnv.dir, "oversize", name)
+		}
+		cfg.Containers[name] = container
+		if !container.UsesWatchDir() {
+			continue
+		}
+		container.WatchDir = filepath.Join(mockEnv.dir, "watch", name)
+		container.WatchDirs = nil
+		container.Chown = ""
+		cfg.Containers[name] = container
+	}
+
+	return nil
+}
+
+// mockPath moves a file that belongs to the config into the mock directory in mock mode
+func mockPath(path string) (string, error) {
+	if !mockMode {
+		return path, nil
+	}
+	if err := startMock(); err != nil {
+		return "", err
+	}
+	return filepath.Join(mockEnv.dir, filepath.Base(path)), nil
+}
+
+// cleanupMock stops the fake PTP and removes the mock directory
+func cleanupMock() {
+	if mockEnv.server == nil {
+		return
+	}
+	mockEnv.server.Close()
+	os.RemoveAll(mockEnv.dir)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pauseCmd = &cobra.Command{
+		Use:               "pause <container...>",
+		Short:             "Stop fetching for containers until they are resumed",
+		Args:              cobra.MinimumNArgs(1),
+		RunE:              func(cmd *cobra.Command, args []string) error { return setPaused(args, true) },
+		ValidArgsFunction: completeContainers,
+		Example: `  # Skip hetzner in scheduled and manual fetches
+  ptparchiver pause hetzner`,
+	}
+
+	resumeCmd = &cobra.Command{
+		Use:               "resume <container...>",
+		Short:             "Resume fetching for paused containers",
+		Args:              cobra.MinimumNArgs(1),
+		RunE:              func(cmd *cobra.Command, args []string) error { return setPaused(args, false) },
+		ValidArgsFunction: completeContainers,
+	}
+)
+
+func init() {
+	pauseCmd.GroupID = "operation"
+	resumeCmd.GroupID = "operation"
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
+
+// setPaused persists the paused flag for the containers, which a running service picks up on its next fetch
+func setPaused(args []string, paused bool) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadPlainConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	names, err := selectContainers(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := st.SetPaused(name, paused); err != nil {
+			log.Error().Err(err).Str("container", name).Msg("failed to update state")
+			return err
+		}
+
+		if paused {
+			log.Info().Str("container", name).Msg("paused container")
+		} else {
+			log.Info().Str("container", name).Msg("resumed container")
+		}
+	}
+
+	return nil
+}
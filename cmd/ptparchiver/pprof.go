@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// listenPprof serves the pprof profiles and expvar variables on addr. A mux of its own is used so
+// nothing else registered on http.DefaultServeMux is exposed.
+func listenPprof(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on pprof address: %w", err)
+	}
+
+	log.Info().Str("address", listener.Addr().String()).Msg("serving pprof")
+	if !loopbackAddress(addr) {
+		log.Warn().Str("address", addr).Msg("pprof is reachable beyond localhost, its endpoints have no authentication")
+	}
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("pprof listener stopped")
+		}
+	}()
+	return nil
+}
+
+// loopbackAddress reports whether the host of a host:port address is localhost or a loopback IP
+func loopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
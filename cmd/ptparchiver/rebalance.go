@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rebalanceFrom      string
+	rebalanceTo        string
+	rebalanceCategory  string
+	rebalanceContainer string
+	rebalancePathMaps  []string
+	rebalanceDryRun    bool
+	rebalanceYes       bool
+
+	rebalanceCmd = &cobra.Command{
+		Use:   "rebalance",
+		Short: "Move a category's torrents from one client to another",
+		Long: `Move a category's torrents from one client to another.
+
+Each completed torrent is exported from the source client and added to the target
+client with the hash check skipped, pointing at the same data location (optionally
+rewritten with --path-map). Torrents are not removed from the source client, do that
+yourself once the target client is seeding them.`,
+		Args: cobra.NoArgs,
+		RunE: runRebalance,
+		Example: `  # Preview moving the archive from an old box to a new one
+  ptparchiver rebalance --from qbit-old --to qbit-new --category ptp-archive --dry-run
+
+  # Move it, rewriting the data location for the new mount
+  ptparchiver rebalance --from qbit-old --to qbit-new --category ptp-archive --path-map /mnt/old=/mnt/new`,
+	}
+)
+
+func init() {
+	rebalanceCmd.GroupID = "operation"
+	rootCmd.AddCommand(rebalanceCmd)
+
+	rebalanceCmd.Flags().StringVar(&rebalanceFrom, "from", "", "client to move torrents from")
+	rebalanceCmd.Flags().StringVar(&rebalanceTo, "to", "", "client to move torrents to")
+	rebalanceCmd.Flags().StringVar(&rebalanceCategory, "category", "", "category of the torrents to move")
+	rebalanceCmd.Flags().StringVar(&rebalanceContainer, "container", "", "container to assign the moved torrents to in the history")
+	rebalanceCmd.Flags().StringArrayVar(&rebalancePathMaps, "path-map", nil, "rewrite data locations, as old=new (repeatable)")
+	rebalanceCmd.Flags().BoolVar(&rebalanceDryRun, "dry-run", false, "show what would be moved without changing anything")
+	rebalanceCmd.Flags().BoolVarP(&rebalanceYes, "yes", "y", false, "don't ask for confirmation")
+
+	for _, flag := range []string{"from", "to", "category"} {
+		rebalanceCmd.MarkFlagRequired(flag)
+	}
+	rebalanceCmd.RegisterFlagCompletionFunc("from", completeClients)
+	rebalanceCmd.RegisterFlagCompletionFunc("to", completeClients)
+	rebalanceCmd.RegisterFlagCompletionFunc("container", completeContainers)
+}
+
+// mapPath rewrites the longest matching old=new prefix of path
+func mapPath(path string, pathMaps []string) string {
+	best, replacement := "", ""
+	for _, m := range pathMaps {
+		from, to, _ := strings.Cut(m, "=")
+		if (path == from || strings.HasPrefix(path, strings.TrimSuffix(from, "/")+"/")) && len(from) > len(best) {
+			best, replacement = from, to
+		}
+	}
+	if best == "" {
+		return path
+	}
+	return filepath.Join(replacement, strings.TrimPrefix(path, best))
+}
+
+func runRebalance(cmd *cobra.Command, args []string) error {
+	if rebalanceFrom == rebalanceTo {
+		return fmt.Errorf("--from and --to must be different clients")
+	}
+	for _, m := range rebalancePathMaps {
+		if from, to, ok := strings.Cut(m, "="); !ok || from == "" || to == "" {
+			return fmt.Errorf("invalid --path-map %q, expected old=new", m)
+		}
+	}
+
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	if rebalanceContainer != "" {
+		if _, ok := cfg.Containers[rebalanceContainer]; !ok {
+			return fmt.Errorf("container %s not found", rebalanceContainer)
+		}
+	}
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+
+	clients := newClientCache(cfg)
+	from, err := clients.get(rebalanceFrom)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", rebalanceFrom, err)
+	}
+	to, err := clients.get(rebalanceTo)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", rebalanceTo, err)
+	}
+
+	torrents, err := from.ListTorrents(rebalanceCategory)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents on %s: %w", rebalanceFrom, err)
+	}
+
+	// only completed torrents can be added with the hash check skipped
+	var pending []client.Torrent
+	for _, t := range torrents {
+		if !t.Complete() {
+			log.Warn().Str("torrent", t.Name).Msg("skipping incomplete torrent")
+			continue
+		}
+		pending = append(pending, t)
+	}
+
+	if len(pending) == 0 {
+		log.Info().Str("client", rebalanceFrom).Str("category", rebalanceCategory).Msg("no completed torrents to move")
+		return nil
+	}
+
+	var totalSize int64
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TORRENT\tSIZE\tFROM PATH\tTO PATH")
+	for _, t := range pending {
+		totalSize += t.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Name, units.HumanSize(float64(t.Size)), t.SavePath, mapPath(t.SavePath, rebalancePathMaps))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "\n%d torrents (%s) from %s to %s\n",
+		len(pending), units.HumanSize(float64(totalSize)), rebalanceFrom, rebalanceTo)
+
+	if rebalanceDryRun {
+		return nil
+	}
+
+	if !rebalanceYes {
+		fmt.Fprint(cmd.OutOrStdout(), "Continue? [y/N] ")
+		answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			log.Info().Msg("aborted")
+			return nil
+		}
+	}
+
+	moved, failed := 0, 0
+	for _, t := range pending {
+		data, err := from.ExportTorrent(t.Hash)
+		if err != nil {
+			log.Error().Err(err).Str("torrent", t.Name).Msg("failed to export torrent")
+			failed++
+			continue
+		}
+
+		opts := map[string]string{
+			"category":      rebalanceCategory,
+			"download_dir":  mapPath(t.SavePath, rebalancePathMaps),
+			"skip_checking": "true",
+		}
+		if err := to.AddTorrent(data, t.Name, opts); err != nil {
+			log.Error().Err(err).Str("torrent", t.Name).Msg("failed to add torrent")
+			failed++
+			continue
+		}
+
+		entry, ok := history.Get(t.Hash)
+		if !ok {
+			entry = state.HistoryEntry{
+				Hash:    t.Hash,
+				Name:    t.Name,
+				Size:    t.Size,
+				AddedAt: time.Now(),
+			}
+		}
+		entry.Client = rebalanceTo
+		if rebalanceContainer != "" {
+			entry.Container = rebalanceContainer
+		}
+		if err := history.Add(entry); err != nil {
+			log.Warn().Err(err).Str("torrent", t.Name).Msg("failed to update history")
+		}
+
+		log.Info().Str("torrent", t.Name).Str("to", rebalanceTo).Msg("moved torrent")
+		moved++
+	}
+
+	log.Info().
+		Int("moved", moved).
+		Int("failed", failed).
+		Msgf("remove the moved torrents from %s (keeping the data) once %s is seeding them", rebalanceFrom, rebalanceTo)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d torrents failed to move", failed, len(pending))
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/internal/recorder"
+)
+
+var (
+	// recordDir is where PTP responses are saved with --record
+	recordDir string
+	// replayDir holds the PTP responses answered from with --replay
+	replayDir string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "save PTP responses, with credentials and passkeys redacted, to this directory")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "answer requests to PTP with the responses saved by --record in this directory")
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
+}
+
+// setupRecording routes requests to PTP through the recorder or replayer when asked to
+func setupRecording() {
+	switch {
+	case recordDir != "":
+		log.Info().Str("dir", recordDir).Msg("recording PTP responses")
+		archiver.UseTransport(func(next http.RoundTripper) http.RoundTripper {
+			return recorder.Record(recordDir, next)
+		})
+	case replayDir != "":
+		log.Warn().Str("dir", replayDir).Msg("replaying recorded PTP responses, nothing is sent to PTP")
+		// every client shares the recording, so each response is only replayed once
+		replay := recorder.Replay(replayDir)
+		archiver.UseTransport(func(http.RoundTripper) http.RoundTripper {
+			return replay
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	redownloadContainer string
+
+	redownloadCmd = &cobra.Command{
+		Use:   "redownload <torrentID>",
+		Short: "Re-download an archived torrent from PTP and add it to its container again",
+		Long: `Re-download an archived torrent from PTP and add it to its container again, e.g. after it was
+accidentally removed from the client. The container is taken from the history unless --container is
+given. The torrent goes through the same category, tags and free space checks as a fetched one.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runRedownload,
+	}
+)
+
+func init() {
+	redownloadCmd.GroupID = "operation"
+	rootCmd.AddCommand(redownloadCmd)
+
+	redownloadCmd.Flags().StringVar(&redownloadContainer, "container", "", "container to add the torrent to instead of the one in the history")
+	redownloadCmd.RegisterFlagCompletionFunc("container", completeContainers)
+}
+
+func runRedownload(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+
+	torrentID, container := args[0], redownloadContainer
+	if e, ok := findHistoryEntry(history, args[0]); ok {
+		if e.TorrentID != "" {
+			torrentID = e.TorrentID
+		}
+		if container == "" {
+			container = e.Container
+		}
+	}
+	if container == "" {
+		return fmt.Errorf("torrent %s not found in the history, use --container to pick a container", args[0])
+	}
+	if _, ok := cfg.Containers[container]; !ok {
+		return withExitCode(ExitConfig, fmt.Errorf("container %s not found in config", container))
+	}
+
+	client, err := archiver.NewClient(cfg, version.Version, version.Commit, version.Date)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create client")
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+	client.SetState(st)
+	client.SetHistory(history)
+
+	result, err := client.Redownload(container, torrentID)
+	if err != nil {
+		return err
+	}
+
+	return manualAddResult(cmd, result, torrentID, container)
+}
+
+// manualAddResult reports the outcome of adding a single torrent to a container by hand
+func manualAddResult(cmd *cobra.Command, result archiver.FetchResult, torrent, container string) error {
+	switch result {
+	case archiver.ResultAdded:
+		fmt.Fprintf(cmd.OutOrStdout(), "Added torrent %s to container %s\n", torrent, container)
+	case archiver.ResultDuplicate:
+		fmt.Fprintf(cmd.OutOrStdout(), "Torrent %s is already in the client of container %s\n", torrent, container)
+	case archiver.ResultUnavailable:
+		return withExitCode(ExitClientUnreachable, fmt.Errorf("torrent client of container %s is unavailable", container))
+	default:
+		return withExitCode(ExitSkipped, fmt.Errorf("torrent %s was not added: %s", torrent, result))
+	}
+	return nil
+}
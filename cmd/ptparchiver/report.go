@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report [container...]",
+	Short: "List archive torrents that are errored, missing data or not seeding",
+	RunE:  runReport,
+	Example: `  # Report on all containers
+  ptparchiver report
+
+  # Report on a single container
+  ptparchiver report hetzner`,
+}
+
+func init() {
+	reportCmd.GroupID = "operation"
+	reportCmd.ValidArgsFunction = completeContainers
+	rootCmd.AddCommand(reportCmd)
+}
+
+// seedingProblem returns why a torrent isn't meeting its seeding obligation, or an empty string if it is
+func seedingProblem(t client.Torrent) string {
+	switch t.State {
+	case client.StateError:
+		if t.Message != "" {
+			return "errored: " + t.Message
+		}
+		return "errored"
+	case client.StateMissing:
+		return "missing data"
+	case client.StatePaused:
+		if t.Complete() {
+			return "not seeding: paused"
+		}
+		return "paused before completion"
+	case client.StateQueued, client.StateUnknown:
+		if t.Complete() {
+			return fmt.Sprintf("not seeding: %s", t.State)
+		}
+	}
+	return ""
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	cfg, err := findAndLoadConfig()
+	if err != nil {
+		return err
+	}
+
+	names, err := selectContainers(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	type summary struct {
+		name, target    string
+		total, problems int
+		unreachable     bool
+	}
+	var summaries []summary
+
+	clients := newClientCache(cfg)
+
+	out := cmd.OutOrStdout()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tCLIENT\tTORRENT\tSIZE\tPROGRESS\tPROBLEM")
+
+	for _, name := range names {
+		container := cfg.Containers[name]
+		if container.Client == "" {
+			log.Debug().Str("container", name).Msg("skipping watch directory container, torrents can't be inspected")
+			continue
+		}
+		if container.Category == "" {
+			// listing without a category returns every torrent on the client
+			log.Warn().Str("container", name).Msg("skipping container without a category, its torrents can't be told apart from the rest of the client")
+			continue
+		}
+
+		s := summary{name: name, target: container.Client}
+
+		tc, err := clients.get(container.Client)
+		if err != nil {
+			s.unreachable = true
+			summaries = append(summaries, s)
+			continue
+		}
+
+		torrents, err := tc.ListTorrents(container.Category)
+		if err != nil {
+			log.Error().Err(err).Str("container", name).Msg("failed to list torrents")
+			s.unreachable = true
+			summaries = append(summaries, s)
+			continue
+		}
+
+		slices.SortFunc(torrents, func(a, b client.Torrent) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		s.total = len(torrents)
+		for _, t := range torrents {
+			problem := seedingProblem(t)
+			if problem == "" {
+				continue
+			}
+			s.problems++
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.1f%%\t%s\n",
+				name, container.Client, t.Name, units.HumanSize(float64(t.Size)), t.Progress*100, problem)
+		}
+		summaries = append(summaries, s)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tCLIENT\tTORRENTS\tHEALTHY\tPROBLEMS")
+	for _, s := range summaries {
+		if s.unreachable {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\tclient unreachable\n", s.name, s.target)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", s.name, s.target, s.total, s.total-s.problems, s.problems)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/internal/state"
+)
+
+// scheduleContainers fetches for every container on its own schedule until the process exits, so a slow
+// client or a long backoff of one container never holds up the others. Fetches from PTP are still
+// spaced out by fetchSleep by the archiver. Container names received on triggers are fetched right away,
+// an empty list fetches every container.
+func scheduleContainers(client *archiver.Client, st *state.State, containers []string, interval time.Duration, triggers <-chan []string) {
+	manual := make(map[string]chan struct{}, len(containers))
+	for _, name := range containers {
+		manual[name] = make(chan struct{}, 1)
+	}
+
+	go func() {
+		for names := range triggers {
+			if len(names) == 0 {
+				names = containers
+			}
+			for _, name := range names {
+				// a trigger that's already pending covers this one
+				select {
+				case manual[name] <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, name := range containers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scheduleContainer(client, st, name, interval, manual[name])
+		}()
+	}
+	wg.Wait()
+}
+
+// wakeCheckInterval is how often a scheduler compares the wall clock against its next run. Timers use the
+// monotonic clock, which stops while the system is suspended, so a plain ticker would delay the next fetch
+// by however long a laptop slept or a VM was paused.
+const wakeCheckInterval = time.Minute
+
+// scheduleContainer fetches for the container right away and then every interval, catching up immediately
+// when the process wakes up after the next run was due. A fetch triggered on manual restarts the interval.
+func scheduleContainer(client *archiver.Client, st *state.State, name string, interval time.Duration, manual <-chan struct{}) {
+	ticker := time.NewTicker(wakeCheckInterval)
+	defer ticker.Stop()
+
+	// Round(0) strips the monotonic reading, so the times below compare as wall clock times
+	nextRun := time.Now().Round(0)
+	for {
+		if _, err := client.FetchContainers([]string{name}, 1); err != nil {
+			log.Error().Err(err).Str("container", name).Msg("failed to fetch torrents")
+		}
+
+		nextRun = nextRun.Add(interval)
+		if now := time.Now().Round(0); nextRun.Before(now) {
+			nextRun = now.Add(interval)
+		}
+		nextFetch := backoffFetch(st, name, nextRun, interval)
+		recordNextFetch(st, name, nextRun, nextFetch)
+		log.Info().
+			Str("container", name).
+			Time("nextRun", nextRun).
+			Msgf("scheduling next fetch in %s", formatDuration(time.Until(nextRun)))
+		if nextFetch.After(nextRun) {
+			log.Info().
+				Str("container", name).
+				Time("nextFetch", nextFetch).
+				Msgf("backing off, fetching again in %s", formatDuration(time.Until(nextFetch)))
+		}
+
+		lastCheck := time.Now().Round(0)
+		triggered := false
+	wait:
+		for {
+			select {
+			case <-manual:
+				triggered = true
+				break wait
+			case <-ticker.C:
+			}
+
+			now := time.Now().Round(0)
+			if gap := now.Sub(lastCheck); gap > 2*wakeCheckInterval || gap < 0 {
+				log.Warn().
+					Str("container", name).
+					Str("gap", gap.Round(time.Second).String()).
+					Msg("system was suspended or the clock jumped")
+				// a clock set back would otherwise postpone the next run by as much
+				if nextRun.Sub(now) > interval {
+					nextRun = now.Add(interval)
+				}
+			}
+			lastCheck = now
+
+			if !now.Before(nextRun) {
+				break
+			}
+		}
+
+		if triggered {
+			log.Info().Str("container", name).Msg("performing triggered fetch")
+			nextRun = time.Now().Round(0)
+		} else if late := time.Since(nextRun); late > 2*wakeCheckInterval {
+			log.Info().
+				Str("container", name).
+				Str("late", late.Round(time.Second).String()).
+				Msg("next fetch was due while suspended, performing catch-up fetch")
+			// the missed runs are folded into this one instead of being repeated
+			nextRun = time.Now().Round(0)
+		} else {
+			log.Info().Str("container", name).Msg("performing scheduled fetch")
+		}
+	}
+}
+
+// backoffFetch returns the first scheduled run at or after the end of the container's backoff, since
+// runs before it skip the container
+func backoffFetch(st *state.State, name string, nextRun time.Time, interval time.Duration) time.Time {
+	until := st.BackoffUntil(name)
+	if !until.After(nextRun) || interval <= 0 {
+		return nextRun
+	}
+	runs := (until.Sub(nextRun) + interval - 1) / interval
+	return nextRun.Add(runs * interval)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s0up4200/ptparchiver-go/internal/state"
+)
+
+func TestBackoffFetch(t *testing.T) {
+	nextRun := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	interval := time.Hour
+
+	tests := []struct {
+		name     string
+		backoff  time.Time
+		interval time.Duration
+		want     time.Time
+	}{
+		{name: "not backing off", interval: interval, want: nextRun},
+		{name: "backoff ended", backoff: nextRun.Add(-time.Minute), interval: interval, want: nextRun},
+		{name: "backoff ends at the next run", backoff: nextRun, interval: interval, want: nextRun},
+		{name: "backoff within the interval", backoff: nextRun.Add(10 * time.Minute), interval: interval, want: nextRun.Add(time.Hour)},
+		{name: "backoff on a later run", backoff: nextRun.Add(3 * time.Hour), interval: interval, want: nextRun.Add(3 * time.Hour)},
+		{name: "backoff past a later run", backoff: nextRun.Add(3*time.Hour + time.Second), interval: interval, want: nextRun.Add(4 * time.Hour)},
+		{name: "no interval", backoff: nextRun.Add(3 * time.Hour), want: nextRun},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := state.Load(filepath.Join(t.TempDir(), state.FileName))
+			if err != nil {
+				t.Fatalf("failed to load state: %v", err)
+			}
+			st.Containers["hetzner"] = &state.ContainerState{BackoffUntil: tt.backoff}
+
+			if got := backoffFetch(st, "hetzner", nextRun, tt.interval); !got.Equal(tt.want) {
+				t.Errorf("backoffFetch() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
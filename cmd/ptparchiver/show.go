@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	showJSON bool
+
+	showCmd = &cobra.Command{
+		Use:   "show <torrentID|infohash>",
+		Short: "Show when and where a torrent was archived and its current state on the client",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runShow,
+	}
+)
+
+func init() {
+	showCmd.GroupID = "operation"
+	rootCmd.AddCommand(showCmd)
+
+	showCmd.Flags().BoolVar(&showJSON, "json", false, "output as JSON")
+}
+
+type showEntry struct {
+	state.HistoryEntry
+	// OnClient is the torrent as the client currently sees it, nil if it isn't there or can't be checked
+	OnClient *torrentEntry `json:"onClient,omitempty"`
+	// ClientError is why the client couldn't be checked
+	ClientError string `json:"clientError,omitempty"`
+}
+
+// findHistoryEntry looks a torrent up in the history by PTP torrent ID or infohash
+func findHistoryEntry(history *state.History, id string) (state.HistoryEntry, bool) {
+	if e, ok := history.Get(id); ok {
+		return e, true
+	}
+	for _, e := range history.Entries() {
+		if e.TorrentID != "" && e.TorrentID == id {
+			return e, true
+		}
+	}
+	return state.HistoryEntry{}, false
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+
+	e, ok := findHistoryEntry(history, args[0])
+	if !ok {
+		return fmt.Errorf("torrent %s not found in the history", args[0])
+	}
+
+	entry := showEntry{HistoryEntry: e}
+	if e.Client != "" {
+		entry.OnClient, err = lookupOnClient(newClientCache(cfg), e)
+		if err != nil {
+			entry.ClientError = err.Error()
+		}
+	}
+
+	if showJSON {
+		return writeJSON(cmd.OutOrStdout(), entry)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Torrent:\t%s\n", e.Name)
+	fmt.Fprintf(w, "Torrent ID:\t%s\n", valueOrDash(e.TorrentID))
+	fmt.Fprintf(w, "Infohash:\t%s\n", e.Hash)
+	fmt.Fprintf(w, "Size:\t%s\n", units.BytesSize(float64(e.Size)))
+	fmt.Fprintf(w, "Container:\t%s\n", valueOrDash(e.Container))
+	fmt.Fprintf(w, "Archived:\t%s\n", e.AddedAt.Local().Format(time.RFC3339))
+
+	if e.WatchFile != "" {
+		fmt.Fprintf(w, "Watch file:\t%s\n", e.WatchFile)
+		switch {
+		case e.PickedUpAt != nil:
+			fmt.Fprintf(w, "Picked up:\t%s\n", e.PickedUpAt.Local().Format(time.RFC3339))
+		case e.CleanedUpAt != nil:
+			fmt.Fprintf(w, "Picked up:\tnever, removed %s\n", e.CleanedUpAt.Local().Format(time.RFC3339))
+		default:
+			fmt.Fprintln(w, "Picked up:\tnot yet")
+		}
+	}
+
+	if e.Client != "" {
+		fmt.Fprintf(w, "Client:\t%s\n", e.Client)
+		switch {
+		case entry.ClientError != "":
+			fmt.Fprintf(w, "State:\tunknown (%s)\n", entry.ClientError)
+		case entry.OnClient == nil:
+			fmt.Fprintln(w, "State:\tnot on client")
+		default:
+			t := entry.OnClient
+			fmt.Fprintf(w, "State:\t%s\n", t.State)
+			fmt.Fprintf(w, "Progress:\t%.1f%%\n", t.Progress*100)
+			fmt.Fprintf(w, "Uploaded:\t%s\n", units.BytesSize(float64(t.Uploaded)))
+			fmt.Fprintf(w, "Ratio:\t%.2f\n", t.Ratio)
+		}
+	}
+
+	return w.Flush()
+}
+
+// lookupOnClient returns the torrent of a history entry as its client currently sees it, or nil if it's gone
+func lookupOnClient(clients *clientCache, e state.HistoryEntry) (*torrentEntry, error) {
+	tc, err := clients.get(e.Client)
+	if err != nil {
+		return nil, fmt.Errorf("client unreachable: %w", err)
+	}
+
+	torrents, err := tc.ListTorrents("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	for _, t := range torrents {
+		if strings.EqualFold(t.Hash, e.Hash) {
+			return newTorrentEntry(t), nil
+		}
+	}
+	return nil, nil
+}
+
+func newTorrentEntry(t client.Torrent) *torrentEntry {
+	return &torrentEntry{
+		Hash:     t.Hash,
+		Name:     t.Name,
+		Size:     t.Size,
+		Progress: t.Progress,
+		State:    string(t.State),
+		Uploaded: t.Uploaded,
+		Ratio:    t.Ratio,
+		AddedOn:  t.AddedOn,
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// notifyTriggerSignal sends a fetch of every container to triggers whenever the process receives SIGUSR1
+func notifyTriggerSignal(triggers chan<- []string) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+
+	go func() {
+		for range sigs {
+			log.Info().Msg("received SIGUSR1, triggering fetch")
+			triggers <- nil
+		}
+	}()
+}
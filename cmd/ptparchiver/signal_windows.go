@@ -0,0 +1,6 @@
+//go:build windows
+
+package main
+
+// notifyTriggerSignal does nothing since Windows has no SIGUSR1, the control socket can be used instead
+func notifyTriggerSignal(triggers chan<- []string) {}
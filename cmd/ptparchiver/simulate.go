@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	simulateJSON bool
+
+	simulateCmd = &cobra.Command{
+		Use:   "simulate <container>",
+		Short: "Explain whether a fetch for a container would go ahead, without contacting PTP",
+		Long: `Evaluate every check a fetch for the container goes through (paused, backoff, client availability,
+stalled and downloading limits, container size and free space) and print the outcome of each, without
+contacting archive.php. Exits with code 6 when a fetch would be skipped.`,
+		Args:              cobra.ExactArgs(1),
+		RunE:              runSimulate,
+		ValidArgsFunction: completeContainers,
+	}
+)
+
+func init() {
+	simulateCmd.GroupID = "operation"
+	rootCmd.AddCommand(simulateCmd)
+
+	simulateCmd.Flags().BoolVar(&simulateJSON, "json", false, "output as JSON")
+}
+
+func runSimulate(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	if _, ok := cfg.Containers[name]; !ok {
+		return withExitCode(ExitConfig, fmt.Errorf("container %s not found in config", name))
+	}
+
+	client, err := archiver.NewClient(cfg, version.Version, version.Commit, version.Date)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create client")
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+	client.SetState(st)
+
+	decisions, err := client.Simulate(name)
+	if err != nil {
+		return err
+	}
+
+	var blocked *archiver.Decision
+	for i := range decisions {
+		if !decisions[i].Pass {
+			blocked = &decisions[i]
+			break
+		}
+	}
+	if blocked != nil {
+		exitStatus = ExitSkipped
+	}
+
+	if simulateJSON {
+		return writeJSON(cmd.OutOrStdout(), decisions)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+	for _, d := range decisions {
+		result := "pass"
+		if !d.Pass {
+			result = "skip"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Check, result, d.Detail)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if blocked != nil {
+		fmt.Fprintf(cmd.OutOrStdout(), "\nA fetch for %s would be skipped because of the %s check.\n", name, blocked.Check)
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "\nA fetch for %s would ask PTP for a torrent.\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [container...]",
+	Short: "Show the health of every container",
+	RunE:  runStatus,
+	Example: `  # Show all containers
+  ptparchiver status
+
+  # Show selected containers
+  ptparchiver status hetzner homelab`,
+}
+
+func init() {
+	statusCmd.GroupID = "operation"
+	rootCmd.AddCommand(statusCmd)
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+
+	names, err := selectContainers(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	clients := newClientCache(cfg)
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tSTATE\tCLIENT\tREACHABLE\tCATEGORY\tSTALLED\tFREE SPACE\tLAST FETCH\tNEXT FETCH")
+
+	for _, name := range names {
+		container := cfg.Containers[name]
+
+		target, reachable, stalled, freeSpace := "-", "-", "-", "-"
+
+		switch {
+		case container.UsesWatchDir():
+			target = "watch: " + strings.Join(container.WatchDirectories(), ",")
+			reachable = "yes"
+			for _, dir := range container.WatchDirectories() {
+				if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+					reachable = "no"
+				}
+			}
+		case container.Client != "":
+			target = container.Client
+
+			tc, err := clients.get(container.Client)
+			if err != nil {
+				reachable = "no"
+				break
+			}
+			reachable = "yes"
+			stalled, freeSpace = clientUsage(cfg, container, tc)
+			if eta, ok := st.TimeUntilFull(container.Client); ok {
+				freeSpace += fmt.Sprintf(" (full in %s)", formatETA(eta))
+			}
+		}
+
+		lastFetch, nextFetch := "never", "-"
+		cs, ok := st.Container(name)
+		if ok && !cs.LastFetch.IsZero() {
+			lastFetch = fmt.Sprintf("%s (%s ago)", cs.LastResult, formatDuration(time.Since(cs.LastFetch)))
+			if cs.LastError != "" {
+				lastFetch += ": " + cs.LastError
+			}
+		}
+		// only the run command schedules fetches, and a paused container isn't fetched whenever it's due
+		if ok && !cs.NextFetch.IsZero() && !st.IsPaused(name) {
+			nextFetch = formatNextRun(cs.NextFetch)
+		}
+
+		schedule := "active"
+		if st.IsPaused(name) {
+			schedule = "paused"
+		} else if until := st.BackoffUntil(name); time.Now().Before(until) {
+			schedule = fmt.Sprintf("backing off (%s)", formatDuration(time.Until(until)))
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			name, schedule, target, reachable, valueOrDash(container.Category), stalled, freeSpace, lastFetch, nextFetch)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	nextRun := "not scheduled (run command not active)"
+	if next := st.NextRun(); !next.IsZero() {
+		nextRun = formatNextRun(next)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "\nNext scheduled run: %s\n", nextRun)
+
+	if !st.LastAudit.IsZero() {
+		fmt.Fprintf(cmd.OutOrStdout(), "Last seeding audit: %s (%d problems)\n", st.LastAudit.Format(time.RFC3339), st.AuditProblems)
+	}
+	if st.ScriptVersion != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "Official script version: %s (reported since %s)\n", st.ScriptVersion, st.ScriptVersionSince.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// clientUsage returns the stalled count against the limit and the free space reported by the client,
+// mirroring which checks the fetch command performs for each client type
+func clientUsage(cfg *config.Config, container config.Container, tc client.TorrentClient) (string, string) {
+	stalled, freeSpace := "-", "-"
+	clientType := client.Type(cfg, container.Client)
+
+	if clientType != "" {
+		// the same count the archiver compares against maxStalled
+		count, err := archiver.CountStalled(container, tc)
+		switch {
+		case err != nil:
+			stalled = "error"
+		case container.MaxStalled > 0:
+			stalled = fmt.Sprintf("%d/%d", count, container.MaxStalled)
+		default:
+			stalled = fmt.Sprintf("%d/unlimited", count)
+		}
+	}
+
+	if client.ReportsFreeSpace(cfg, container.Client) {
+		space, err := tc.GetFreeSpace()
+		if err != nil {
+			freeSpace = "error"
+		} else {
+			freeSpace = units.HumanSize(float64(space))
+		}
+	}
+
+	return stalled, freeSpace
+}
+
+// formatNextRun formats a scheduled time along with how long until it's due
+func formatNextRun(t time.Time) string {
+	if until := time.Until(t); until > 0 {
+		return fmt.Sprintf("%s (in %s)", t.Format(time.RFC3339), formatDuration(until))
+	}
+	return t.Format(time.RFC3339) + " (overdue)"
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
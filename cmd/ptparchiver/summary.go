@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	summaryFormat string
+	summaryOutput string
+	summaryDays   int
+
+	summaryCmd = &cobra.Command{
+		Use:   "summary",
+		Short: "Render a summary of the last week as markdown or HTML",
+		Args:  cobra.NoArgs,
+		RunE:  runSummary,
+		Example: `  # Print a markdown summary of the last 7 days
+  ptparchiver summary
+
+  # Write an HTML summary, e.g. from a weekly cron job
+  ptparchiver summary --format html --output /var/www/ptparchiver.html`,
+	}
+)
+
+func init() {
+	summaryCmd.GroupID = "operation"
+	rootCmd.AddCommand(summaryCmd)
+
+	summaryCmd.Flags().StringVar(&summaryFormat, "format", "markdown", "output format, markdown or html")
+	summaryCmd.Flags().StringVarP(&summaryOutput, "output", "o", "", "write the summary to a file instead of stdout")
+	summaryCmd.Flags().IntVar(&summaryDays, "days", 7, "number of days to summarize")
+}
+
+// summaryReport is the data rendered by the summary templates
+type summaryReport struct {
+	From, To   time.Time
+	Days       int
+	Torrents   int
+	Bytes      string
+	PrevBytes  string
+	Containers []summaryContainer
+	Clients    []summaryClient
+}
+
+type summaryContainer struct {
+	Name      string
+	Torrents  int
+	Bytes     string
+	PrevBytes string
+	Failures  int
+	Skipped   int
+	Filled    string
+	Uploaded  string
+	Ratio     string
+	LastError string
+}
+
+type summaryClient struct {
+	Name      string
+	FreeSpace string
+	FullIn    string
+}
+
+func runSummary(cmd *cobra.Command, args []string) error {
+	if summaryFormat != "markdown" && summaryFormat != "html" {
+		return fmt.Errorf("invalid format %q, must be markdown or html", summaryFormat)
+	}
+	if summaryDays < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	// the upload totals come from the clients, which need the credentials
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		return err
+	}
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+
+	report := buildSummary(cfg, st, history, summaryDays)
+	addSummaryUploads(cfg, &report)
+
+	out := cmd.OutOrStdout()
+	if summaryOutput != "" {
+		f, err := os.Create(summaryOutput)
+		if err != nil {
+			log.Error().Err(err).Str("path", summaryOutput).Msg("failed to create summary file")
+			return fmt.Errorf("failed to create summary file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := renderSummary(out, summaryFormat, report); err != nil {
+		return fmt.Errorf("failed to render summary: %w", err)
+	}
+
+	if summaryOutput != "" {
+		log.Info().Str("path", summaryOutput).Msg("wrote summary")
+	}
+	return nil
+}
+
+// buildSummary collects the archived torrents and fetch results of the last days, compared against the
+// days before, along with the fill level of each container and the free space trend of each client
+func buildSummary(cfg *config.Config, st *state.State, history *state.History, days int) summaryReport {
+	to := time.Now()
+	from := to.AddDate(0, 0, -days)
+	prevFrom := from.AddDate(0, 0, -days)
+
+	report := summaryReport{From: from, To: to, Days: days}
+
+	archived := make(map[string]int)
+	bytes := make(map[string]int64)
+	prevBytes := make(map[string]int64)
+	used := make(map[string]int64)
+	var total, prevTotal int64
+	for _, e := range history.Entries() {
+		used[e.Container] += e.Size
+		switch {
+		case !e.AddedAt.Before(from):
+			archived[e.Container]++
+			bytes[e.Container] += e.Size
+			report.Torrents++
+			total += e.Size
+		case !e.AddedAt.Before(prevFrom):
+			prevBytes[e.Container] += e.Size
+			prevTotal += e.Size
+		}
+	}
+	report.Bytes = units.HumanSize(float64(total))
+	report.PrevBytes = units.HumanSize(float64(prevTotal))
+
+	names, _ := selectContainers(cfg, nil)
+	for _, name := range names {
+		sc := summaryContainer{
+			Name:      name,
+			Torrents:  archived[name],
+			Bytes:     units.HumanSize(float64(bytes[name])),
+			PrevBytes: units.HumanSize(float64(prevBytes[name])),
+			Filled:    "-",
+			Uploaded:  "-",
+			Ratio:     "-",
+		}
+
+		if cs, ok := st.Container(name); ok {
+			for result, n := range cs.ResultsSince(from) {
+				switch {
+				case result == string(archiver.ResultError):
+					sc.Failures += n
+				case strings.HasPrefix(result, "skipped"):
+					sc.Skipped += n
+				}
+			}
+			sc.LastError = cs.LastError
+		}
+
+		// the history only knows torrents added by ptparchiver, or imported with the import command
+		if size, err := config.ParseSize(cfg.Containers[name].Size); err == nil && size > 0 {
+			sc.Filled = fmt.Sprintf("%.1f%%", float64(used[name])/float64(size)*100)
+		}
+
+		report.Containers = append(report.Containers, sc)
+	}
+
+	for _, name := range clientNames(cfg) {
+		sc := summaryClient{Name: name, FreeSpace: "-", FullIn: "-"}
+		if sample, ok := st.LatestFreeSpace(name); ok {
+			sc.FreeSpace = units.HumanSize(float64(sample.Free))
+		}
+		if eta, ok := st.TimeUntilFull(name); ok {
+			sc.FullIn = formatETA(eta)
+		}
+		report.Clients = append(report.Clients, sc)
+	}
+
+	return report
+}
+
+// addSummaryUploads fills in the all-time upload and ratio of each container's torrents on its client,
+// containers that can't be listed keep "-"
+func addSummaryUploads(cfg *config.Config, report *summaryReport) {
+	clients := newClientCache(cfg)
+	for i := range report.Containers {
+		sc := &report.Containers[i]
+		container := cfg.Containers[sc.Name]
+
+		// listing without a category returns every torrent on the client
+		if container.Client == "" || container.Category == "" {
+			continue
+		}
+
+		tc, err := clients.get(container.Client)
+		if err != nil {
+			continue
+		}
+
+		torrents, err := tc.ListTorrents(container.Category)
+		if err != nil {
+			log.Error().Err(err).Str("container", sc.Name).Msg("failed to list torrents")
+			continue
+		}
+
+		var used, uploaded int64
+		for _, t := range torrents {
+			used += t.Size
+			uploaded += t.Uploaded
+		}
+		sc.Uploaded = units.HumanSize(float64(uploaded))
+		if used > 0 {
+			sc.Ratio = fmt.Sprintf("%.2f", float64(uploaded)/float64(used))
+		}
+	}
+}
+
+const summaryMarkdown = `# ptparchiver summary
+
+{{ .From.Format "2006-01-02" }} to {{ .To.Format "2006-01-02" }} ({{ .Days }} days)
+
+Archived **{{ .Torrents }}** torrents totalling **{{ .Bytes }}** (previous {{ .Days }} days: {{ .PrevBytes }}).
+
+## Containers
+
+| Container | Torrents | Archived | Previous | Failures | Skipped | Filled | Uploaded | Ratio |
+|---|---|---|---|---|---|---|---|---|
+{{- range .Containers }}
+| {{ .Name }} | {{ .Torrents }} | {{ .Bytes }} | {{ .PrevBytes }} | {{ .Failures }} | {{ .Skipped }} | {{ .Filled }} | {{ .Uploaded }} | {{ .Ratio }} |
+{{- end }}
+{{ range .Containers }}{{ if .LastError }}
+- **{{ .Name }}** last error: {{ .LastError }}
+{{- end }}{{ end }}
+
+## Clients
+
+| Client | Free space | Full in |
+|---|---|---|
+{{- range .Clients }}
+| {{ .Name }} | {{ .FreeSpace }} | {{ .FullIn }} |
+{{- end }}
+`
+
+const summaryHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ptparchiver summary</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>ptparchiver summary</h1>
+<p>{{ .From.Format "2006-01-02" }} to {{ .To.Format "2006-01-02" }} ({{ .Days }} days)</p>
+<p>Archived <strong>{{ .Torrents }}</strong> torrents totalling <strong>{{ .Bytes }}</strong> (previous {{ .Days }} days: {{ .PrevBytes }}).</p>
+<h2>Containers</h2>
+<table>
+<tr><th>Container</th><th>Torrents</th><th>Archived</th><th>Previous</th><th>Failures</th><th>Skipped</th><th>Filled</th><th>Uploaded</th><th>Ratio</th><th>Last error</th></tr>
+{{- range .Containers }}
+<tr><td>{{ .Name }}</td><td>{{ .Torrents }}</td><td>{{ .Bytes }}</td><td>{{ .PrevBytes }}</td><td>{{ .Failures }}</td><td>{{ .Skipped }}</td><td>{{ .Filled }}</td><td>{{ .Uploaded }}</td><td>{{ .Ratio }}</td><td>{{ .LastError }}</td></tr>
+{{- end }}
+</table>
+<h2>Clients</h2>
+<table>
+<tr><th>Client</th><th>Free space</th><th>Full in</th></tr>
+{{- range .Clients }}
+<tr><td>{{ .Name }}</td><td>{{ .FreeSpace }}</td><td>{{ .FullIn }}</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`
+
+// renderSummary writes the report in the given format, HTML is escaped by html/template
+func renderSummary(w io.Writer, format string, report summaryReport) error {
+	if format == "html" {
+		tmpl, err := htmltemplate.New("summary").Parse(summaryHTML)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, report)
+	}
+
+	tmpl, err := texttemplate.New("summary").Parse(summaryMarkdown)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, report)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test [client|all]",
+	Short: "Test connectivity to configured torrent clients",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runTest,
+	Example: `  # Test all configured clients
+  ptparchiver test
+
+  # Test a single client
+  ptparchiver test qbit-local
+
+  # Test the PTP API credentials
+  ptparchiver test ptp`,
+}
+
+var testPTPCmd = &cobra.Command{
+	Use:   "ptp",
+	Short: "Verify the PTP API credentials without fetching anything",
+	Args:  cobra.NoArgs,
+	RunE:  runTestPTP,
+}
+
+func init() {
+	testCmd.GroupID = "setup"
+	testCmd.AddCommand(testPTPCmd)
+	rootCmd.AddCommand(testCmd)
+}
+
+func runTestPTP(cmd *cobra.Command, args []string) error {
+	cfg, err := findAndLoadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := archiver.CheckCredentials(cfg, version.Version); err != nil {
+		log.Error().Err(err).Str("apiUser", cfg.ApiUser).Msg("PTP credential check failed")
+		return err
+	}
+
+	// containers may fetch for other accounts
+	users := []string{cfg.ApiUser}
+	if len(cfg.Containers) > 0 {
+		users = nil
+		for _, name := range slices.Sorted(maps.Keys(cfg.Containers)) {
+			if user := cfg.Account(name).ApiUser; !slices.Contains(users, user) {
+				users = append(users, user)
+			}
+		}
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "PTP credentials for %s are valid\n", strings.Join(users, ", "))
+	return nil
+}
+
+// clientTestResult holds the outcome of each check run against a client
+type clientTestResult struct {
+	name       string
+	clientType string
+	login      string
+	version    string
+	freeSpace  string
+	categories string
+	failed     bool
+}
+
+func runTest(cmd *cobra.Command, args []string) error {
+	cfg, err := findAndLoadConfig()
+	if err != nil {
+		return err
+	}
+
+	names := clientNames(cfg)
+	if len(args) == 1 && args[0] != "all" {
+		if client.Type(cfg, args[0]) == "" {
+			return fmt.Errorf("client %s not found", args[0])
+		}
+		names = []string{args[0]}
+	}
+
+	if len(names) == 0 {
+		return fmt.Errorf("no torrent clients configured")
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLIENT\tTYPE\tLOGIN\tVERSION\tFREE SPACE\tCATEGORIES\tRESULT")
+
+	for _, name := range names {
+		result := testClient(cfg, name)
+
+		status := "PASS"
+		if result.failed {
+			status = "FAIL"
+			failed++
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			result.name, result.clientType, result.login, result.version, result.freeSpace, result.categories, status)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "\n%d passed, %d failed\n", len(names)-failed, failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d clients failed", failed, len(names))
+	}
+	return nil
+}
+
+// testClient logs into the client and checks its version, free space and the categories used by its containers
+func testClient(cfg *config.Config, name string) clientTestResult {
+	result := clientTestResult{
+		name:       name,
+		clientType: client.Type(cfg, name),
+		login:      "-",
+		version:    "-",
+		freeSpace:  "-",
+		categories: "-",
+	}
+
+	tc, err := client.New(cfg, name)
+	if err != nil {
+		log.Error().Err(err).Str("client", name).Msg("failed to connect")
+		result.login = "failed"
+		result.failed = true
+		return result
+	}
+	result.login = "ok"
+
+	version, minimum, supported, err := client.SupportedVersion(tc)
+	if err != nil {
+		log.Error().Err(err).Str("client", name).Msg("failed to get version")
+		result.version = "failed"
+		result.failed = true
+	} else if !supported {
+		// adding may still work, so this is only a warning
+		log.Warn().Str("client", name).Str("version", version).Str("minimum", minimum).Msg("torrent client is older than supported")
+		result.version = fmt.Sprintf("%s (unsupported, needs %s)", version, minimum)
+	} else {
+		result.version = version
+	}
+
+	// rTorrent only reports free space with freeSpaceMode local
+	if client.ReportsFreeSpace(cfg, name) {
+		space, err := tc.GetFreeSpace()
+		if err != nil {
+			log.Error().Err(err).Str("client", name).Msg("failed to get free space")
+			result.freeSpace = "failed"
+			result.failed = true
+		} else {
+			result.freeSpace = units.HumanSize(float64(space))
+		}
+	}
+
+	var missing []string
+	checked := make(map[string]struct{})
+	for _, containerName := range slices.Sorted(maps.Keys(cfg.Containers)) {
+		container := cfg.Containers[containerName]
+		if container.Client != name || container.Category == "" {
+			continue
+		}
+		if _, ok := checked[container.Category]; ok {
+			continue
+		}
+		checked[container.Category] = struct{}{}
+
+		exists, err := tc.CategoryExists(container.Category)
+		if err != nil {
+			log.Error().Err(err).Str("client", name).Str("category", container.Category).Msg("failed to check category")
+			result.categories = "failed"
+			result.failed = true
+			return result
+		}
+		if !exists {
+			missing = append(missing, container.Category)
+		}
+	}
+
+	switch {
+	case len(checked) == 0:
+		result.categories = "-"
+	case len(missing) > 0:
+		// categories are created on demand when adding torrents, so this is only a warning
+		log.Warn().Str("client", name).Strs("categories", missing).Msg("categories do not exist yet")
+		result.categories = "missing: " + strings.Join(missing, ",")
+	default:
+		result.categories = "ok"
+	}
+
+	return result
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	torrentsJSON bool
+
+	torrentsCmd = &cobra.Command{
+		Use:               "torrents <container>",
+		Short:             "List the torrents in a container's category on its client",
+		Args:              cobra.ExactArgs(1),
+		RunE:              runTorrents,
+		ValidArgsFunction: completeContainers,
+	}
+)
+
+func init() {
+	torrentsCmd.GroupID = "operation"
+	rootCmd.AddCommand(torrentsCmd)
+
+	torrentsCmd.Flags().BoolVar(&torrentsJSON, "json", false, "output as JSON")
+}
+
+type torrentEntry struct {
+	Hash     string    `json:"hash"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Progress float64   `json:"progress"`
+	State    string    `json:"state"`
+	Uploaded int64     `json:"uploaded"`
+	Ratio    float64   `json:"ratio"`
+	AddedOn  time.Time `json:"addedOn"`
+}
+
+func runTorrents(cmd *cobra.Command, args []string) error {
+	cfg, err := findAndLoadConfig()
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	container, ok := cfg.Containers[name]
+	if !ok {
+		return fmt.Errorf("container %s not found", name)
+	}
+	if container.Client == "" || container.UsesWatchDir() {
+		return fmt.Errorf("container %s saves to a watch directory, its torrents can't be listed", name)
+	}
+
+	tc, err := newClientCache(cfg).get(container.Client)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", container.Client, err)
+	}
+
+	torrents, err := tc.ListTorrents(container.Category)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	entries := make([]torrentEntry, 0, len(torrents))
+	for _, t := range torrents {
+		entries = append(entries, *newTorrentEntry(t))
+	}
+	slices.SortFunc(entries, func(a, b torrentEntry) int {
+		return a.AddedOn.Compare(b.AddedOn)
+	})
+
+	if torrentsJSON {
+		return writeJSON(cmd.OutOrStdout(), entries)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tSTATE\tPROGRESS\tRATIO\tADDED")
+	for _, e := range entries {
+		added := "-"
+		if !e.AddedOn.IsZero() {
+			added = e.AddedOn.Format(time.DateOnly)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.0f%%\t%.2f\t%s\n",
+			e.Name, units.BytesSize(float64(e.Size)), e.State, e.Progress*100, e.Ratio, added)
+	}
+	return w.Flush()
+}
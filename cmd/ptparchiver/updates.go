@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/archiver"
+	"github.com/s0up4200/ptparchiver-go/pkg/version"
+)
+
+const updateCheckInterval = 24 * time.Hour
+
+// watchForUpdates checks for a new ptparchiver release and a newer official Python script
+// once a day for as long as the run command is active
+func watchForUpdates(client *archiver.Client) {
+	ticker := time.NewTicker(updateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		checkForUpdates(client)
+		<-ticker.C
+	}
+}
+
+func checkForUpdates(client *archiver.Client) {
+	client.ScriptVersionWarning()
+
+	// development builds can't be compared against releases
+	if version.Version == "dev" {
+		return
+	}
+
+	info, err := version.Check("s0up4200", "ptparchiver-go")
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to check for updates")
+		return
+	}
+
+	if info.UpdateAvailable {
+		log.Warn().
+			Str("current", info.Version).
+			Str("latest", info.Latest).
+			Str("updateUrl", info.UpdateURL).
+			Msg("update available, run ptparchiver self-update to install it")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:               "usage [container...]",
+	Short:             "Compare the size of each container's torrents against its configured size, with upload totals",
+	RunE:              runUsage,
+	ValidArgsFunction: completeContainers,
+}
+
+func init() {
+	usageCmd.GroupID = "operation"
+	rootCmd.AddCommand(usageCmd)
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	cfg, err := findAndLoadConfig()
+	if err != nil {
+		return err
+	}
+
+	names, err := selectContainers(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	clients := newClientCache(cfg)
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tCLIENT\tTORRENTS\tUSED\tSIZE\tFILLED\tREMAINING\tUPLOADED\tRATIO")
+
+	for _, name := range names {
+		container := cfg.Containers[name]
+
+		if container.Client == "" {
+			fmt.Fprintf(w, "%s\twatch: %s\t-\t-\t%s\t-\t-\t-\t-\n", name, strings.Join(container.WatchDirectories(), ","), container.Size)
+			continue
+		}
+
+		// listing without a category returns every torrent on the client
+		if container.Category == "" {
+			log.Warn().Str("container", name).Msg("container has no category, its torrents can't be told apart from the rest of the client")
+			fmt.Fprintf(w, "%s\t%s\tno category\t-\t%s\t-\t-\t-\t-\n", name, container.Client, container.Size)
+			continue
+		}
+
+		size, err := config.ParseSize(container.Size)
+		if err != nil {
+			log.Error().Err(err).Str("container", name).Msg("failed to parse container size")
+			fmt.Fprintf(w, "%s\t%s\t-\t-\tinvalid\t-\t-\t-\t-\n", name, container.Client)
+			continue
+		}
+
+		tc, err := clients.get(container.Client)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%s\tunreachable\t-\t%s\t-\t-\t-\t-\n", name, container.Client, container.Size)
+			continue
+		}
+
+		torrents, err := tc.ListTorrents(container.Category)
+		if err != nil {
+			log.Error().Err(err).Str("container", name).Msg("failed to list torrents")
+			fmt.Fprintf(w, "%s\t%s\terror\t-\t%s\t-\t-\t-\t-\n", name, container.Client, container.Size)
+			continue
+		}
+
+		var used, uploaded int64
+		for _, t := range torrents {
+			used += t.Size
+			uploaded += t.Uploaded
+		}
+
+		// overall ratio of the container, weighting each torrent by its size
+		ratio := "-"
+		if used > 0 {
+			ratio = fmt.Sprintf("%.2f", float64(uploaded)/float64(used))
+		}
+
+		filled := 0.0
+		if size > 0 {
+			filled = float64(used) / float64(size) * 100
+		}
+
+		remaining := "0B"
+		if used < size {
+			remaining = units.BytesSize(float64(size - used))
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%.1f%%\t%s\t%s\t%s\n",
+			name, container.Client, len(torrents), units.BytesSize(float64(used)), units.BytesSize(float64(size)), filled, remaining,
+			units.BytesSize(float64(uploaded)), ratio)
+	}
+
+	return w.Flush()
+}
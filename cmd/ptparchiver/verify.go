@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Compare the local history against what each client actually has",
+	Long: `Compare the local history against what each client actually has.
+
+Reports torrents recorded in the history that are missing from their client, and
+torrents in a container's category that the history doesn't know about. Use the
+import command to add the latter to the history.`,
+	Args: cobra.NoArgs,
+	RunE: runVerify,
+}
+
+func init() {
+	verifyCmd.GroupID = "operation"
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	configPath, err := findConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	history, err := loadHistory(configPath)
+	if err != nil {
+		return err
+	}
+	entries := history.Entries()
+
+	// every client that is either used by a container or referenced by the history
+	clientSet := make(map[string]struct{})
+	for _, container := range cfg.Containers {
+		if container.Client != "" {
+			clientSet[container.Client] = struct{}{}
+		}
+	}
+	for _, e := range entries {
+		if e.Client != "" {
+			clientSet[e.Client] = struct{}{}
+		}
+	}
+
+	clients := newClientCache(cfg)
+	torrentsByClient := make(map[string]map[string]client.Torrent)
+	for _, name := range slices.Sorted(maps.Keys(clientSet)) {
+		tc, err := clients.get(name)
+		if err != nil {
+			continue
+		}
+
+		torrents, err := tc.ListTorrents("")
+		if err != nil {
+			log.Error().Err(err).Str("client", name).Msg("failed to list torrents")
+			continue
+		}
+
+		byHash := make(map[string]client.Torrent, len(torrents))
+		for _, t := range torrents {
+			byHash[t.Hash] = t
+		}
+		torrentsByClient[name] = byHash
+	}
+
+	out := cmd.OutOrStdout()
+	issues, skipped := 0, 0
+
+	fmt.Fprintln(out, "Recorded in history but missing from client:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tCLIENT\tTORRENT\tHASH\tADDED")
+	for _, e := range entries {
+		if e.Client == "" {
+			// watch directory torrents can't be checked
+			continue
+		}
+
+		byHash, ok := torrentsByClient[e.Client]
+		if !ok {
+			skipped++
+			continue
+		}
+
+		if _, ok := byHash[e.Hash]; !ok {
+			issues++
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				valueOrDash(e.Container), e.Client, e.Name, e.Hash, e.AddedAt.Format("2006-01-02"))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nIn an archive category but unknown to history:")
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tCLIENT\tTORRENT\tHASH\tSIZE")
+	for _, name := range slices.Sorted(maps.Keys(cfg.Containers)) {
+		container := cfg.Containers[name]
+		byHash, ok := torrentsByClient[container.Client]
+		if container.Client == "" || !ok {
+			continue
+		}
+
+		for _, hash := range slices.Sorted(maps.Keys(byHash)) {
+			t := byHash[hash]
+			if t.Category != container.Category {
+				continue
+			}
+			if _, ok := history.Get(t.Hash); ok {
+				continue
+			}
+			issues++
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				name, container.Client, t.Name, t.Hash, units.HumanSize(float64(t.Size)))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if skipped > 0 {
+		log.Warn().Int("entries", skipped).Msg("some history entries could not be checked because their client is unreachable")
+	}
+
+	if issues > 0 {
+		return fmt.Errorf("verify found %d discrepancies", issues)
+	}
+
+	log.Info().Int("entries", len(entries)).Msg("history matches the clients")
+	return nil
+}
@@ -0,0 +1,78 @@
+package archiver
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/zeebo/bencode"
+)
+
+// rewriteAnnounce points the announce URLs of a .torrent file at host, and at scheme unless it's empty.
+// Every other key, the info dictionary in particular, is copied as is so the infohash doesn't change.
+func rewriteAnnounce(data []byte, scheme, host string) ([]byte, error) {
+	var torrent map[string]bencode.RawMessage
+	if err := bencode.DecodeBytes(data, &torrent); err != nil {
+		return nil, fmt.Errorf("failed to decode torrent: %w", err)
+	}
+
+	if raw, ok := torrent["announce"]; ok {
+		var announce string
+		if err := bencode.DecodeBytes(raw, &announce); err != nil {
+			return nil, fmt.Errorf("failed to decode announce: %w", err)
+		}
+		announce, err := rewriteAnnounceURL(announce, scheme, host)
+		if err != nil {
+			return nil, err
+		}
+		if torrent["announce"], err = bencode.EncodeBytes(announce); err != nil {
+			return nil, fmt.Errorf("failed to encode announce: %w", err)
+		}
+	}
+
+	if raw, ok := torrent["announce-list"]; ok {
+		var tiers [][]string
+		if err := bencode.DecodeBytes(raw, &tiers); err != nil {
+			return nil, fmt.Errorf("failed to decode announce-list: %w", err)
+		}
+		for _, tier := range tiers {
+			for i, announce := range tier {
+				rewritten, err := rewriteAnnounceURL(announce, scheme, host)
+				if err != nil {
+					return nil, err
+				}
+				tier[i] = rewritten
+			}
+		}
+		var err error
+		if torrent["announce-list"], err = bencode.EncodeBytes(tiers); err != nil {
+			return nil, fmt.Errorf("failed to encode announce-list: %w", err)
+		}
+	}
+
+	out, err := bencode.EncodeBytes(torrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode torrent: %w", err)
+	}
+	return out, nil
+}
+
+// rewriteAnnounceURL replaces the host of an announce URL. Its port is kept unless host has its own or the
+// scheme changes, since the port belongs to the original scheme.
+func rewriteAnnounceURL(announce, scheme, host string) (string, error) {
+	u, err := url.Parse(announce)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse announce URL: %w", err)
+	}
+
+	if _, _, err := net.SplitHostPort(host); err == nil || u.Port() == "" || scheme != "" {
+		u.Host = host
+	} else {
+		u.Host = net.JoinHostPort(strings.Trim(host, "[]"), u.Port())
+	}
+	if scheme != "" {
+		u.Scheme = scheme
+	}
+	return u.String(), nil
+}
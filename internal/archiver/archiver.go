@@ -2,11 +2,17 @@ package archiver
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/semver"
@@ -15,28 +21,77 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/s0up4200/ptparchiver-go/internal/client"
 	"github.com/s0up4200/ptparchiver-go/internal/config"
-	"github.com/zeebo/bencode"
+	"github.com/s0up4200/ptparchiver-go/internal/httpclient"
+	"github.com/s0up4200/ptparchiver-go/internal/state"
 )
 
-func init() {
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339})
-}
-
+// Client fetches from PTP and adds to the torrent clients. It is safe for concurrent use once SetState
+// and SetHistory have been called, so containers can be fetched on their own schedules and by triggers.
 type Client struct {
-	cfg     *config.Config
+	cfg  *config.Config
+	http *http.Client
+	// ptpLimiter spaces out fetches from PTP by fetchSleep, also when containers are fetched concurrently
+	ptpLimiter *rateLimiter
+	// repeats keeps errors that recur every cycle from flooding the log
+	repeats *repeats
+	// circuits skips torrent clients that keep failing to connect for a cooldown
+	circuits *circuits
+	// influx writes the metrics of every fetch cycle, nil unless configured
+	influx *influxWriter
+	// statsd sends counters and timers around fetches and adds, nil unless configured
+	statsd *statsdEmitter
+
+	// mu guards clients, unavailable, checking, watchDirs, targets, reserved, cycles and the counts of every cycle
+	mu      sync.Mutex
 	clients map[string]client.TorrentClient
+	// targets holds the lock of every client or watch directory container, see lockTarget
+	targets map[string]*sync.Mutex
+	// watchDirs holds the watch directory client of each container, kept so round-robin continues between fetches
+	watchDirs map[string]*client.WatchDirClient
+	// unavailable holds the connection error of every client that couldn't be connected to
+	unavailable map[string]error
+	// checking holds a channel for every client being checked by preflight, closed once the check is done
+	checking map[string]chan struct{}
+	// reserved holds the size of the torrents added to each client during the fetch cycles in progress,
+	// which clients don't count against their free space until the data is written
+	reserved map[string]uint64
+	// cycles holds the fetch cycles in progress, reserved is only reset when there are none
+	cycles  map[*fetchCycle]struct{}
+	state   *state.State
+	history *state.History
+	version string
 	log     zerolog.Logger
+
+	// scriptVersion is the newest official Python script version PTP has reported, lastScriptVersion
+	// the one it reported last, for noticing changes when there is no state
+	scriptVersion     string
+	lastScriptVersion string
+	scriptVersionMu   sync.Mutex
 }
 
+// FetchResult describes the outcome of a single fetch attempt
+type FetchResult string
+
+const (
+	ResultAdded         FetchResult = "added"
+	ResultStalled       FetchResult = "skipped: too many stalled"
+	ResultClientStalled FetchResult = "skipped: client stalled limit"
+	ResultGlobalStalled FetchResult = "skipped: global stalled limit"
+	ResultDownloading   FetchResult = "skipped: too many downloading"
+	ResultNoSpace       FetchResult = "skipped: insufficient space"
+	ResultSizeFiltered  FetchResult = "skipped: size filtered"
+	ResultBlocklisted   FetchResult = "skipped: blocklisted"
+	ResultContainerFull FetchResult = "skipped: container full"
+	ResultSpaceUnknown  FetchResult = "skipped: free space unavailable"
+	ResultUnavailable   FetchResult = "skipped: client unavailable"
+	ResultDuplicate     FetchResult = "skipped: already in client"
+	ResultNoTorrents    FetchResult = "skipped: no torrents available"
+	ResultError         FetchResult = "error"
+)
+
 // make sure we're aware of any changes made to the python version
 const serverVersion = "0.10.0"
 
-type torrentInfo struct {
-	Info struct {
-		Name string `bencode:"name"`
-	} `bencode:"info"`
-}
-
 func NewClient(cfg *config.Config, ver, commit, date string) (*Client, error) {
 	logger := log.With().Logger()
 	logger.Info().
@@ -44,8 +99,113 @@ func NewClient(cfg *config.Config, ver, commit, date string) (*Client, error) {
 		Str("serverVersion", serverVersion).
 		Msg("initializing PTP archiver")
 
-	// Initialize clients map
-	clients := make(map[string]client.TorrentClient)
+	if err := cfg.VersionPolicy.Validate(); err != nil {
+		logger.Error().Err(err).Msg("invalid config")
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	if err := cfg.Blocklist.Validate(); err != nil {
+		logger.Error().Err(err).Msg("invalid config")
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	for name, container := range cfg.Containers {
+		if _, _, err := container.SpeedLimits(); err != nil {
+			logger.Error().Err(err).Str("container", name).Msg("invalid config")
+			return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+		}
+		if container.StalledTag != "" && client.Type(cfg, container.Client) != client.TypeQBittorrent {
+			err := fmt.Errorf("stalledTag is only supported for qBittorrent clients")
+			logger.Error().Err(err).Str("container", name).Msg("invalid config")
+			return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+		}
+		if container.CountCategoryUsage {
+			// without a category every torrent on the client would count toward the container
+			if container.Category == "" && !container.UsesWatchDir() {
+				err := fmt.Errorf("countCategoryUsage requires a category")
+				logger.Error().Err(err).Str("container", name).Msg("invalid config")
+				return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+			}
+			if _, err := config.ParseSize(container.Size); err != nil {
+				logger.Error().Err(err).Str("container", name).Msg("invalid config")
+				return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+			}
+		}
+		if err := container.Blocklist.Validate(); err != nil {
+			logger.Error().Err(err).Str("container", name).Msg("invalid config")
+			return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+		}
+		if _, _, err := container.TorrentSizeLimits(); err != nil {
+			logger.Error().Err(err).Str("container", name).Msg("invalid config")
+			return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+		}
+		if err := container.WatchDirSelect.Validate(); err != nil {
+			logger.Error().Err(err).Str("container", name).Msg("invalid config")
+			return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+		}
+		if _, err := container.WatchDirPermissions(); err != nil {
+			logger.Error().Err(err).Str("container", name).Msg("invalid config")
+			return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+		}
+		if err := container.ValidateAccount(); err != nil {
+			logger.Error().Err(err).Str("container", name).Msg("invalid config")
+			return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+		}
+		if host := cfg.ContainerAnnounceHost(name); host != "" {
+			if _, _, err := config.ParseAnnounceHost(host); err != nil {
+				logger.Error().Err(err).Str("container", name).Msg("invalid config")
+				return nil, fmt.Errorf("%w: container %s: %w", ErrInvalidConfig, name, err)
+			}
+		}
+	}
+	for _, conflict := range cfg.CategoryConflicts() {
+		logger.Warn().
+			Str("client", conflict.Client).
+			Str("category", conflict.Category).
+			Strs("containers", conflict.Containers).
+			Msg("containers share a client and category, so their stalled counts and usage include each other's torrents. " +
+				"Give each container its own category, or a distinct stalledTag")
+	}
+
+	// shared by every request to PTP so the fetch and download calls re-use connections
+	httpClient, err := httpclient.New(httpclient.Options{
+		Timeouts:    cfg.Timeouts,
+		BindAddress: cfg.BindAddress,
+		IPFamily:    cfg.IPFamily,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create http client")
+		return nil, fmt.Errorf("failed to create http client: %w", err)
+	}
+
+	wrapTransport(httpClient)
+
+	influx, err := newInfluxWriter(cfg)
+	if err != nil {
+		logger.Error().Err(err).Msg("invalid config")
+		return nil, fmt.Errorf("%w: influx: %w", ErrInvalidConfig, err)
+	}
+	statsd, err := newStatsdEmitter(cfg.Statsd)
+	if err != nil {
+		logger.Error().Err(err).Msg("invalid config")
+		return nil, fmt.Errorf("%w: statsd: %w", ErrInvalidConfig, err)
+	}
+
+	c := &Client{
+		cfg:         cfg,
+		http:        httpClient,
+		influx:      influx,
+		statsd:      statsd,
+		ptpLimiter:  newRateLimiter(time.Duration(cfg.FetchSleep) * time.Second),
+		repeats:     newRepeats(),
+		circuits:    newCircuits(cfg.CircuitBreaker),
+		clients:     make(map[string]client.TorrentClient),
+		targets:     make(map[string]*sync.Mutex),
+		watchDirs:   make(map[string]*client.WatchDirClient),
+		unavailable: make(map[string]error),
+		checking:    make(map[string]chan struct{}),
+		cycles:      make(map[*fetchCycle]struct{}),
+		version:     ver,
+		log:         logger,
+	}
 
 	// Find which clients are needed
 	activeClients := make(map[string]struct{})
@@ -55,125 +215,322 @@ func NewClient(cfg *config.Config, ver, commit, date string) (*Client, error) {
 		}
 	}
 
-	// Initialize only the qBittorrent clients that are used
-	for name, qbitConfig := range cfg.QBitClients {
-		if _, isActive := activeClients[name]; !isActive {
-			logger.Debug().
-				Str("client", name).
-				Msg("skipping unused qBittorrent client")
+	// Initialize only the clients that are used
+	c.connect(slices.Collect(maps.Keys(activeClients)))
+
+	return c, nil
+}
+
+// ptpTransport wraps the transport of requests to PTP when set, see UseTransport
+var ptpTransport func(http.RoundTripper) http.RoundTripper
+
+// UseTransport makes every request to PTP go through the transport wrap returns for the default one,
+// e.g. to record or replay the responses
+func UseTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	ptpTransport = wrap
+}
+
+func wrapTransport(httpClient *http.Client) {
+	if ptpTransport != nil {
+		httpClient.Transport = ptpTransport(httpClient.Transport)
+	}
+}
+
+// clientConnectTimeout bounds how long connecting to a single torrent client may take
+const clientConnectTimeout = 2 * time.Minute
+
+// connect logs in to the named torrent clients concurrently. A client that fails to connect is
+// marked unavailable, so only its own containers are skipped until it can be reached again.
+func (c *Client) connect(names []string) {
+	type result struct {
+		name string
+		tc   client.TorrentClient
+		err  error
+		// version of the client and, if it's older than supported, the minimum version
+		version, minimum string
+	}
+
+	results := make(chan result, len(names))
+	started := 0
+	for _, name := range names {
+		clientType := client.Type(c.cfg, name)
+		if clientType == "" {
+			// reported when the container is fetched
 			continue
 		}
 
-		logger.Debug().
+		c.log.Debug().
 			Str("client", name).
-			Msg("connecting to qBittorrent client")
-
-		qb, err := client.NewQBitClient(
-			qbitConfig.URL,
-			qbitConfig.Username,
-			qbitConfig.Password,
-			qbitConfig.BasicUser,
-			qbitConfig.BasicPass,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize qbittorrent client %s: %w", name, err)
+			Str("type", clientType).
+			Msg("connecting to torrent client")
+
+		started++
+		go func() {
+			tc, err := c.dial(name)
+			if err != nil {
+				results <- result{name: name, err: fmt.Errorf("%w %s (%s): %w", ErrClientUnavailable, name, clientType, err)}
+				return
+			}
+
+			r := result{name: name, tc: tc}
+			version, minimum, supported, err := client.SupportedVersion(tc)
+			if err != nil {
+				// a client that can't answer fails the preflight check before anything is fetched
+				c.log.Debug().Err(err).Str("client", name).Msg("failed to get torrent client version")
+			} else {
+				r.version = version
+				if !supported {
+					r.minimum = minimum
+				}
+			}
+			results <- r
+		}()
+	}
+
+	for i := 0; i < started; i++ {
+		r := <-results
+		logger := c.log.With().Str("client", r.name).Logger()
+		if r.err != nil {
+			c.repeats.event("connect:"+r.name, r.err, logger.Warn(), logger).
+				Err(r.err).
+				Msg("failed to connect to torrent client, skipping its containers")
+			c.mu.Lock()
+			c.unavailable[r.name] = r.err
+			c.mu.Unlock()
+			c.circuitFailure(r.name, r.err, logger)
+			continue
 		}
 
+		c.repeats.resolve("connect:"+r.name, logger, "torrent client is reachable again")
+		c.repeats.resolve("unavailable:"+r.name, logger, "")
 		logger.Info().
-			Str("client", name).
-			Msg("successfully connected to qBittorrent client")
+			Str("type", client.Type(c.cfg, r.name)).
+			Str("version", r.version).
+			Msg("successfully connected to torrent client")
+		if r.minimum != "" {
+			logger.Warn().
+				Str("version", r.version).
+				Str("minimum", r.minimum).
+				Msg("torrent client is older than supported, adding torrents may fail")
+		}
 
-		clients[name] = qb
+		c.mu.Lock()
+		c.clients[r.name] = r.tc
+		delete(c.unavailable, r.name)
+		c.mu.Unlock()
 	}
+}
 
-	// Initialize only the rTorrent clients that are used
-	for name, rtorrConfig := range cfg.RTorrClients {
-		if _, isActive := activeClients[name]; !isActive {
-			logger.Debug().
-				Str("client", name).
-				Msg("skipping unused rTorrent client")
+// dial connects to the named client, giving up after clientConnectTimeout. The client
+// libraries don't all accept a context, so a hung attempt is abandoned rather than cancelled.
+func (c *Client) dial(name string) (client.TorrentClient, error) {
+	type result struct {
+		tc  client.TorrentClient
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		tc, err := client.New(c.cfg, name)
+		done <- result{tc, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.tc, r.err
+	case <-time.After(clientConnectTimeout):
+		return nil, fmt.Errorf("timed out after %s", clientConnectTimeout)
+	}
+}
+
+// preflight checks that the clients used by the containers are reachable and logged in before
+// anything is fetched from PTP, so a fetch isn't wasted on a torrent that can't be added.
+// Clients that fail are reconnected once and otherwise marked unavailable until the next cycle.
+// A client already being checked by a concurrent cycle is waited for rather than checked again.
+func (c *Client) preflight(containers []string) {
+	names := make(map[string]struct{})
+	for _, name := range containers {
+		if container := c.cfg.Containers[name]; container.Client != "" && !container.UsesWatchDir() {
+			names[container.Client] = struct{}{}
+		}
+	}
+
+	var check []string
+	var pending []chan struct{}
+	c.mu.Lock()
+	for name := range names {
+		if done, ok := c.checking[name]; ok {
+			pending = append(pending, done)
 			continue
 		}
+		c.checking[name] = make(chan struct{})
+		check = append(check, name)
+	}
+	c.mu.Unlock()
 
-		logger.Debug().
-			Str("client", name).
-			Msg("connecting to rTorrent client")
+	var reconnect []string
+	for _, name := range check {
+		if until, open := c.circuits.open(name); open {
+			c.log.Debug().Str("client", name).Time("until", until).Msg("circuit is open, not connecting to torrent client")
+			c.markCircuitOpen(name, until)
+			continue
+		}
 
-		rt, err := client.NewRTorrentClient(
-			rtorrConfig.URL,
-			rtorrConfig.BasicUser,
-			rtorrConfig.BasicPass,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize rtorrent client %s: %w", name, err)
+		c.mu.Lock()
+		tc, ok := c.clients[name]
+		c.mu.Unlock()
+		if !ok {
+			// never connected or marked unavailable by an earlier cycle
+			reconnect = append(reconnect, name)
+			continue
 		}
 
-		logger.Info().
-			Str("client", name).
-			Msg("successfully connected to rTorrent client")
+		if _, err := tc.Version(); err != nil {
+			logger := c.log.With().Str("client", name).Logger()
+			c.mu.Lock()
+			delete(c.clients, name)
+			c.mu.Unlock()
+			closeClient(tc)
+			if until, open := c.circuitFailure(name, err, logger); open {
+				c.markCircuitOpen(name, until)
+				continue
+			}
+			c.repeats.event("preflight:"+name, err, logger.Warn(), logger).
+				Err(err).
+				Msg("torrent client failed preflight check, reconnecting")
+			reconnect = append(reconnect, name)
+			continue
+		}
+		c.circuits.success(name)
+		c.repeats.resolve("preflight:"+name, c.log, "")
+	}
+	c.connect(reconnect)
 
-		clients[name] = rt
+	c.mu.Lock()
+	for _, name := range check {
+		close(c.checking[name])
+		delete(c.checking, name)
+	}
+	c.mu.Unlock()
+	for _, done := range pending {
+		<-done
 	}
 
-	// Initialize only the Deluge clients that are used
-	for name, delugeConfig := range cfg.DelugeClients {
-		if _, isActive := activeClients[name]; !isActive {
-			logger.Debug().
-				Str("client", name).
-				Msg("skipping unused Deluge client")
-			continue
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, err := range c.unavailable {
+		// an open circuit was already reported when it opened
+		if _, ok := names[name]; ok && !errors.Is(err, ErrCircuitOpen) {
+			logger := c.log.With().Str("client", name).Logger()
+			c.repeats.event("unavailable:"+name, err, logger.Warn(), logger).
+				Err(err).
+				Msg("torrent client is unavailable, its containers will be skipped this cycle")
 		}
+	}
+}
 
-		logger.Debug().
-			Str("client", name).
-			Msg("connecting to Deluge client")
+// circuitFailure counts a failed connection attempt against the client's circuit, warning once when it
+// opens, and returns until when the circuit is open
+func (c *Client) circuitFailure(name string, err error, logger zerolog.Logger) (time.Time, bool) {
+	until, opened := c.circuits.failure(name)
+	if opened {
+		logger.Warn().
+			Err(err).
+			Int("failures", c.cfg.CircuitBreaker.Failures).
+			Time("until", until).
+			Msg("torrent client keeps failing, skipping its containers until the cooldown ends")
+	}
+	return until, !until.IsZero()
+}
 
-		dc, err := client.NewDelugeClient(delugeConfig)
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize deluge client %s: %w", name, err)
-		}
+// closeClient releases what a dropped client holds on to, such as the TLS relay of a qBittorrent client
+func closeClient(tc client.TorrentClient) {
+	if closer, ok := tc.(io.Closer); ok {
+		closer.Close()
+	}
+}
 
-		logger.Info().
-			Str("client", name).
-			Msg("successfully connected to Deluge client")
+// markCircuitOpen marks the client unavailable until its circuit closes
+func (c *Client) markCircuitOpen(name string, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tc, ok := c.clients[name]; ok {
+		closeClient(tc)
+		delete(c.clients, name)
+	}
+	c.unavailable[name] = fmt.Errorf("%w %s: %w until %s", ErrClientUnavailable, name, ErrCircuitOpen, until.Format(time.DateTime))
+}
 
-		clients[name] = dc
+// setHeaders adds the PTP API credentials and User-Agent to a request
+func (c *Client) setHeaders(req *http.Request, account config.Account) {
+	req.Header.Add("ApiUser", account.ApiUser)
+	req.Header.Add("ApiKey", account.ApiKey)
+	req.Header.Set("User-Agent", c.userAgent())
+}
+
+// userAgent returns the configured User-Agent, always including the ptparchiver-go version
+func (c *Client) userAgent() string {
+	ua := fmt.Sprintf("ptparchiver-go/%s", c.version)
+	if c.cfg.UserAgent != "" {
+		return fmt.Sprintf("%s (%s)", c.cfg.UserAgent, ua)
 	}
+	return ua
+}
 
-	return &Client{
-		cfg:     cfg,
-		clients: clients,
-		log:     logger,
-	}, nil
+// drainAndClose reads what is left of a response body before closing it, so the connection can be re-used
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	body.Close()
 }
 
-// fetches a torrent file for the given container
-func (c *Client) fetchFromPTP(name string, container config.Container) ([]byte, error) {
-	client := &http.Client{}
+// containerLogger returns a logger that adds the container, and the client it adds to, to every line
+func (c *Client) containerLogger(name string) zerolog.Logger {
+	ctx := c.log.With().Str("container", name)
+	if container, ok := c.cfg.Containers[name]; ok && container.Client != "" && !container.UsesWatchDir() {
+		ctx = ctx.Str("client", container.Client)
+	}
+	return ctx.Logger()
+}
 
-	fetchURL := fmt.Sprintf("%s/%s", c.cfg.BaseURL, "archive.php")
+// fetches a torrent file for the given container, returning it along with its PTP torrent ID
+func (c *Client) fetchFromPTP(name string, container config.Container) ([]byte, string, error) {
+	logger := c.containerLogger(name)
+	account := c.cfg.Account(name)
+	fetchURL := fmt.Sprintf("%s/%s", account.BaseURL, "archive.php")
 	req, err := http.NewRequest("GET", fetchURL, nil)
 	if err != nil {
-		c.log.Error().Err(err).Str("url", fetchURL).Msg("failed to create fetch request")
-		return nil, fmt.Errorf("failed to create fetch request: %w", err)
+		logger.Error().Err(err).Str("url", fetchURL).Msg("failed to create fetch request")
+		return nil, "", fmt.Errorf("failed to create fetch request: %w", err)
 	}
 
-	req.Header.Add("ApiUser", c.cfg.ApiUser)
-	req.Header.Add("ApiKey", c.cfg.ApiKey)
+	c.setHeaders(req, account)
 
 	q := req.URL.Query()
 	q.Add("action", "fetch")
 	q.Add("ContainerName", name)
 	q.Add("ContainerSize", container.Size)
 	q.Add("MaxStalled", fmt.Sprintf("%d", container.MaxStalled))
+	for key, value := range container.ExtraParams {
+		if q.Has(key) {
+			logger.Warn().Str("param", key).Msg("ignoring extra parameter that would override a built-in one")
+			continue
+		}
+		q.Add(key, value)
+	}
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := client.Do(req)
+	c.ptpLimiter.Wait()
+	resp, err := c.http.Do(req)
 	if err != nil {
-		c.log.Error().Err(err).Str("url", fetchURL).Msg("failed to fetch from PTP")
-		return nil, fmt.Errorf("failed to fetch from PTP: %w", err)
+		logger.Error().Err(err).Str("url", fetchURL).Msg("failed to fetch from PTP")
+		return nil, "", fmt.Errorf("failed to fetch from PTP: %w", err)
+	}
+	defer drainAndClose(resp.Body)
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		logger.Error().Str("status", resp.Status).Msg("PTP rejected the API credentials")
+		return nil, "", fmt.Errorf("%w: %s", ErrUnauthorized, resp.Status)
 	}
-	defer resp.Body.Close()
 
 	var fetchResp struct {
 		Status        string      `json:"Status"`
@@ -185,31 +542,24 @@ func (c *Client) fetchFromPTP(name string, container config.Container) ([]byte,
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&fetchResp); err != nil {
-		c.log.Error().Err(err).Msg("failed to decode fetch response")
-		return nil, fmt.Errorf("failed to decode fetch response: %w", err)
+		logger.Error().Err(err).Msg("failed to decode fetch response")
+		return nil, "", fmt.Errorf("failed to decode fetch response: %w", err)
 	}
 
-	// check version compatibility first
-	if fetchResp.ScriptVersion != "" {
-		// convert PTP version to semver format if needed
-		serverVerStr := fetchResp.ScriptVersion
-		if !strings.Contains(serverVerStr, ".") {
-			serverVerStr += ".0"
+	if fetchResp.ContainerID != nil {
+		status := fetchResp.Status
+		if fetchResp.Error != "" {
+			status += ": " + fetchResp.Error
+		} else if fetchResp.Message != "" {
+			status += ": " + fetchResp.Message
 		}
+		c.recordRemote(name, fmt.Sprint(fetchResp.ContainerID), status)
+	}
 
-		serverVer, err := semver.NewVersion(serverVerStr)
-		if err != nil {
-			c.log.Warn().Err(err).Str("version", serverVerStr).Msg("invalid server version format")
-		} else {
-			currentVer, err := semver.NewVersion(serverVersion)
-			if err != nil {
-				c.log.Warn().Err(err).Str("version", serverVersion).Msg("invalid current version format")
-			} else if serverVer.GreaterThan(currentVer) {
-				c.log.Warn().
-					Str("currentVersion", currentVer.String()).
-					Str("pythonVersion", serverVer.String()).
-					Msg("newer version of the official Python script is available - check for important changes")
-			}
+	// check version compatibility first
+	if fetchResp.ScriptVersion != "" {
+		if err := c.checkScriptVersion(fetchResp.ScriptVersion); err != nil {
+			return nil, "", err
 		}
 	}
 
@@ -221,260 +571,1091 @@ func (c *Client) fetchFromPTP(name string, container config.Container) ([]byte,
 		} else if fetchResp.Message != "" {
 			errorMsg = fetchResp.Message
 		}
-		c.log.Error().Str("error", errorMsg).Msg("PTP API returned error")
-		return nil, fmt.Errorf("PTP API returned error: %s", errorMsg)
+		if isNoTorrentsMessage(errorMsg) {
+			return nil, "", fmt.Errorf("%w: %s", ErrNoTorrents, errorMsg)
+		}
+		logger.Error().Str("error", errorMsg).Msg("PTP API returned error")
+		return nil, "", fmt.Errorf("PTP API returned error: %s", errorMsg)
+	}
+
+	if fetchResp.TorrentID == "" {
+		return nil, "", fmt.Errorf("%w: no torrent ID in response", ErrNoTorrents)
 	}
 
-	downloadURL := fmt.Sprintf("%s/%s", c.cfg.BaseURL, "torrents.php")
-	req, err = http.NewRequest("GET", downloadURL, nil)
+	if entry, ok := c.blocklisted(name, fetchResp.TorrentID, ""); ok {
+		return nil, fetchResp.TorrentID, fmt.Errorf("%w: %s", ErrBlocklisted, entry)
+	}
+
+	torrentData, err := c.downloadTorrent(logger, account, fetchResp.TorrentID)
 	if err != nil {
-		c.log.Error().Err(err).Str("url", downloadURL).Msg("failed to create download request")
+		return nil, "", err
+	}
+
+	logger.Info().
+		Str("status", fetchResp.Status).
+		Interface("containerID", fetchResp.ContainerID).
+		Str("torrentID", fetchResp.TorrentID).
+		Msg("received fetch response from PTP")
+
+	return torrentData, fetchResp.TorrentID, nil
+}
+
+// downloadTorrent downloads the torrent file with the given PTP torrent ID from torrents.php
+func (c *Client) downloadTorrent(logger zerolog.Logger, account config.Account, torrentID string) ([]byte, error) {
+	downloadURL := fmt.Sprintf("%s/%s", account.BaseURL, "torrents.php")
+	req, err := http.NewRequest("GET", downloadURL, nil)
+	if err != nil {
+		logger.Error().Err(err).Str("url", downloadURL).Msg("failed to create download request")
 		return nil, fmt.Errorf("failed to create download request: %w", err)
 	}
 
-	req.Header.Add("ApiUser", c.cfg.ApiUser)
-	req.Header.Add("ApiKey", c.cfg.ApiKey)
+	c.setHeaders(req, account)
 
-	q = req.URL.Query()
+	q := req.URL.Query()
 	q.Add("action", "download")
-	q.Add("id", fetchResp.TorrentID)
+	q.Add("id", torrentID)
 	req.URL.RawQuery = q.Encode()
 
-	resp, err = client.Do(req)
+	resp, err := c.http.Do(req)
 	if err != nil {
-		c.log.Error().Err(err).Str("url", downloadURL).Str("torrentID", fetchResp.TorrentID).Msg("failed to download torrent")
+		logger.Error().Err(err).Str("url", downloadURL).Str("torrentID", torrentID).Msg("failed to download torrent")
 		return nil, fmt.Errorf("failed to download torrent: %w", err)
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
 
-	torrentData, err := io.ReadAll(resp.Body)
+	maxSize, err := c.cfg.MaxTorrentFileBytes()
 	if err != nil {
-		c.log.Error().Err(err).Str("torrentID", fetchResp.TorrentID).Msg("failed to read torrent data")
-		return nil, fmt.Errorf("failed to read torrent data: %w", err)
+		return nil, err
 	}
 
-	c.log.Info().
-		Str("status", fetchResp.Status).
-		Interface("containerID", fetchResp.ContainerID).
-		Str("torrentID", fetchResp.TorrentID).
-		Msg("received fetch response from PTP")
+	if resp.ContentLength > maxSize {
+		logger.Error().
+			Str("torrentID", torrentID).
+			Int64("contentLength", resp.ContentLength).
+			Int64("maxSize", maxSize).
+			Msg("torrent file is larger than the maximum size")
+		return nil, fmt.Errorf("torrent file of %d bytes exceeds the maximum of %d bytes", resp.ContentLength, maxSize)
+	}
+
+	// read one byte past the limit so an oversized body without a Content-Length is caught too
+	torrentData, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		logger.Error().Err(err).Str("torrentID", torrentID).Msg("failed to read torrent data")
+		return nil, fmt.Errorf("failed to read torrent data: %w", err)
+	}
+	if int64(len(torrentData)) > maxSize {
+		logger.Error().
+			Str("torrentID", torrentID).
+			Int64("maxSize", maxSize).
+			Msg("torrent file is larger than the maximum size")
+		return nil, fmt.Errorf("torrent file exceeds the maximum of %d bytes", maxSize)
+	}
 
 	return torrentData, nil
 }
 
-func (c *Client) FetchForContainer(name string) error {
-	container, ok := c.cfg.Containers[name]
-	if !ok {
-		c.log.Error().Str("container", name).Msg("container not found")
-		return fmt.Errorf("container %s not found", name)
+// checkScriptVersion compares the official Python script version reported by PTP against the one this
+// build is aware of, applying the configured version policy when it is newer. The warning is only logged
+// when the version changes, not on every fetch.
+func (c *Client) checkScriptVersion(reported string) error {
+	// convert PTP version to semver format if needed
+	serverVerStr := reported
+	if !strings.Contains(serverVerStr, ".") {
+		serverVerStr += ".0"
 	}
 
-	// Get or create appropriate client
-	var torrentClient client.TorrentClient
-	var err error
+	serverVer, err := semver.NewVersion(serverVerStr)
+	if err != nil {
+		c.log.Warn().Err(err).Str("version", serverVerStr).Msg("invalid server version format")
+		return nil
+	}
 
-	if container.WatchDir != "" {
-		// Use watch directory client
-		torrentClient, err = client.NewWatchDirClient(container.WatchDir)
+	currentVer, err := semver.NewVersion(serverVersion)
+	if err != nil {
+		c.log.Warn().Err(err).Str("version", serverVersion).Msg("invalid current version format")
+		return nil
+	}
+
+	previous := c.recordScriptVersion(serverVer.String())
+	changed := previous != serverVer.String()
+	if changed && previous != "" {
+		c.log.Info().
+			Str("previousVersion", previous).
+			Str("pythonVersion", serverVer.String()).
+			Msg("official Python script version changed")
+	}
+
+	if !serverVer.GreaterThan(currentVer) {
+		c.setScriptVersion("")
+		return nil
+	}
+
+	c.setScriptVersion(serverVer.String())
+	return c.scriptVersionErr(changed)
+}
+
+// recordScriptVersion remembers the script version PTP reported and returns the one it reported before.
+// It's kept in the state when there is one, so a restart doesn't report the same version again.
+func (c *Client) recordScriptVersion(v string) string {
+	c.scriptVersionMu.Lock()
+	previous := c.lastScriptVersion
+	c.lastScriptVersion = v
+	c.scriptVersionMu.Unlock()
+
+	if c.state == nil {
+		return previous
+	}
+	recorded, err := c.state.RecordScriptVersion(v)
+	if err != nil {
+		c.log.Warn().Err(err).Msg("failed to record script version")
+		return previous
+	}
+	return recorded
+}
+
+// scriptVersionErr applies the version policy to a newer script version reported earlier, returning
+// ErrScriptVersion if fetching must stop. Warnings are only logged when logWarning is set.
+func (c *Client) scriptVersionErr(logWarning bool) error {
+	scriptVersion := c.NewerScriptVersion()
+	if scriptVersion == "" {
+		return nil
+	}
+
+	switch c.cfg.VersionPolicy.OrDefault() {
+	case config.VersionPolicyFail:
+		c.log.Error().
+			Str("currentVersion", serverVersion).
+			Str("pythonVersion", scriptVersion).
+			Msg("newer version of the official Python script is available, not fetching until ptparchiver-go is updated")
+		return fmt.Errorf("%w %s, this build supports %s", ErrScriptVersion, scriptVersion, serverVersion)
+	case config.VersionPolicyWarn:
+		if logWarning {
+			c.log.Warn().
+				Str("currentVersion", serverVersion).
+				Str("pythonVersion", scriptVersion).
+				Msg("newer version of the official Python script is available - check for important changes")
+		}
+	}
+	return nil
+}
+
+// ScriptVersionWarning logs the newer official Python script version if one was reported and the
+// version policy isn't ignore, for periodic reminders from long running commands
+func (c *Client) ScriptVersionWarning() {
+	_ = c.scriptVersionErr(true)
+}
+
+func (c *Client) setScriptVersion(v string) {
+	c.scriptVersionMu.Lock()
+	defer c.scriptVersionMu.Unlock()
+
+	c.scriptVersion = v
+}
+
+// NewerScriptVersion returns the official Python script version reported by PTP if it is newer
+// than the version this build is aware of, or an empty string
+func (c *Client) NewerScriptVersion() string {
+	c.scriptVersionMu.Lock()
+	defer c.scriptVersionMu.Unlock()
+
+	return c.scriptVersion
+}
+
+// SetState enables recording of fetch results to the given state. It must be called before fetching.
+func (c *Client) SetState(s *state.State) {
+	c.state = s
+}
+
+// SetHistory enables recording of added torrents to the given history. It must be called before fetching.
+func (c *Client) SetHistory(h *state.History) {
+	c.history = h
+}
+
+// recordRemote stores the ContainerID PTP returned for the container, warning when it changed since
+// that usually means the container was renamed locally and PTP now treats it as a new container
+func (c *Client) recordRemote(name, id, status string) {
+	logger := c.containerLogger(name)
+	if c.state == nil {
+		return
+	}
+
+	previous, err := c.state.RecordRemote(name, id, status)
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to record container ID")
+		return
+	}
+
+	if previous != "" && previous != id {
+		logger.Warn().
+			Str("previousContainerID", previous).
+			Str("containerID", id).
+			Msg("PTP returned a different container ID than before")
+	}
+}
+
+// recordHistory stores a newly added torrent, skipping torrents that couldn't be decoded
+func (c *Client) recordHistory(entry state.HistoryEntry) {
+	if c.history == nil || entry.Hash == "" {
+		return
+	}
+
+	if err := c.history.Add(entry); err != nil {
+		c.log.Warn().Err(err).Str("torrent", entry.Name).Msg("failed to record torrent in history")
+	}
+}
+
+// historyEntry looks up a torrent in the history, if one is set
+func (c *Client) historyEntry(hash string) (state.HistoryEntry, bool) {
+	if c.history == nil || hash == "" {
+		return state.HistoryEntry{}, false
+	}
+	return c.history.Get(hash)
+}
+
+func (c *Client) FetchForContainer(name string) error {
+	cycle := c.startCycle()
+	defer c.endCycle(cycle)
+
+	_, err := c.fetch(cycle, name)
+	return err
+}
+
+// FetchCount fetches up to count torrents for the given container, stopping early
+// once the stalled or free space checks cause a fetch to be skipped. It returns the
+// result of the last fetch attempt.
+func (c *Client) FetchCount(name string, count int) (FetchResult, error) {
+	cycle := c.startCycle()
+	defer c.endCycle(cycle)
+
+	return c.fetchCount(cycle, name, count)
+}
+
+// fetchCount is FetchCount as part of the given cycle
+func (c *Client) fetchCount(cycle *fetchCycle, name string, count int) (FetchResult, error) {
+	logger := c.containerLogger(name)
+	for i := 0; i < count; i++ {
+		logger.Debug().
+			Int("index", i+1).
+			Int("total", count).
+			Msg("fetching torrent")
+
+		result, err := c.fetch(cycle, name)
 		if err != nil {
-			c.log.Error().Err(err).Str("watchDir", container.WatchDir).Msg("failed to create watch directory client")
-			return fmt.Errorf("failed to create watch directory client: %w", err)
+			return result, err
+		}
+
+		// a torrent the client already has, or that was filtered by size or the blocklist, doesn't say
+		// anything about the container's capacity
+		if result != ResultAdded && result != ResultDuplicate && result != ResultSizeFiltered && result != ResultBlocklisted {
+			logger.Info().
+				Int("fetched", i).
+				Int("requested", count).
+				Msg("stopping early, container cannot take more torrents right now")
+			return result, nil
+		}
+	}
+
+	return ResultAdded, nil
+}
+
+// reservedSpace returns the size of the torrents added to the client during the fetch cycles in progress
+func (c *Client) reservedSpace(name string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reserved[name]
+}
+
+// fetchCycle caches the counts taken during one FetchContainers call, so containers sharing a client and
+// category don't count them again. Its maps are guarded by Client.mu.
+type fetchCycle struct {
+	stalled     map[stalledKey]int
+	downloading map[stalledKey]int
+}
+
+func newFetchCycle() *fetchCycle {
+	return &fetchCycle{
+		stalled:     make(map[stalledKey]int),
+		downloading: make(map[stalledKey]int),
+	}
+}
+
+// startCycle starts a fetch cycle, resetting the reserved space unless another cycle is in progress
+func (c *Client) startCycle() *fetchCycle {
+	cycle := newFetchCycle()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.cycles) == 0 {
+		c.reserved = make(map[string]uint64)
+	}
+	c.cycles[cycle] = struct{}{}
+	return cycle
+}
+
+func (c *Client) endCycle(cycle *fetchCycle) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cycles, cycle)
+}
+
+// stalledKey identifies the torrents a stalled count was taken for
+type stalledKey struct {
+	client   string
+	category string
+	states   string
+	tag      string
+	metadata int
+}
+
+// stalledKeyFor returns the key of the container's stalled count. Containers counting by tag only
+// share it across categories.
+func stalledKeyFor(container config.Container) stalledKey {
+	category := container.Category
+	if container.StalledTag != "" && container.StalledTagOnly {
+		category = ""
+	}
+	return stalledKey{container.Client, category, strings.Join(container.StalledStates, ","), container.StalledTag, container.MetadataStalledAfter}
+}
+
+// countStalled returns the number of stalled torrents of the container on its client, re-using the count
+// from earlier in the cycle when containers share a client and category
+func (c *Client) countStalled(cycle *fetchCycle, container config.Container, tc client.TorrentClient) (int, error) {
+	key := stalledKeyFor(container)
+	c.mu.Lock()
+	count, ok := cycle.stalled[key]
+	c.mu.Unlock()
+	if ok {
+		return count, nil
+	}
+
+	count, err := CountStalled(container, tc)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	cycle.stalled[key] = count
+	c.mu.Unlock()
+	return count, nil
+}
+
+// CountStalled returns the number of stalled torrents of the container on its client, counting only
+// torrents with the container's stalledTag on qBittorrent
+func CountStalled(container config.Container, tc client.TorrentClient) (int, error) {
+	key := stalledKeyFor(container)
+	metadataAfter := time.Duration(container.MetadataStalledAfter) * time.Minute
+	if qbit, isQbit := tc.(*client.QBitClient); isQbit && key.tag != "" {
+		return qbit.CountStalledTorrentsWithTag(key.category, key.tag, container.StalledStates, metadataAfter)
+	}
+	return tc.CountStalledTorrents(container.Category, container.StalledStates, metadataAfter)
+}
+
+// archiveTarget is a client and category that containers add torrents to
+type archiveTarget struct {
+	container config.Container
+	client    client.TorrentClient
+}
+
+// archiveTargets returns the connected client and category of every container, in container name order.
+// Containers sharing a client and category are only returned once.
+func (c *Client) archiveTargets() []archiveTarget {
+	seen := make(map[stalledKey]bool)
+	var targets []archiveTarget
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range slices.Sorted(maps.Keys(c.cfg.Containers)) {
+		container := c.cfg.Containers[name]
+		if container.UsesWatchDir() || container.Client == "" {
+			continue
 		}
-	} else if container.Client != "" {
-		// Use qBittorrent client
-		torrentClient, ok = c.clients[container.Client]
+		if _, down := c.unavailable[container.Client]; down {
+			continue
+		}
+
+		tc, ok := c.clients[container.Client]
 		if !ok {
-			c.log.Error().Str("client", container.Client).Msg("client not found")
-			return fmt.Errorf("client %s not found", container.Client)
+			continue
 		}
-	} else {
-		c.log.Error().Str("container", name).Msg("container must specify either watchDir or client")
-		return fmt.Errorf("container %s must specify either watchDir or client", name)
+
+		key := stalledKey{client: container.Client, category: container.Category}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		targets = append(targets, archiveTarget{container: container, client: tc})
 	}
 
-	// Only check stalled downloads for qBittorrent and rTorrent clients
-	if container.Client != "" {
-		// Check if the client is qBittorrent or rTorrent
-		_, isQbit := torrentClient.(*client.QBitClient)
-		_, isRtorr := torrentClient.(*client.RTorrentClient)
+	return targets
+}
+
+// countsStalled reports whether the client can count its stalled torrents, which every torrent client can
+// but a watch directory can't
+func countsStalled(tc client.TorrentClient) bool {
+	_, isWatchDir := tc.(*client.WatchDirClient)
+	return !isWatchDir
+}
+
+// countStalledGlobal returns the number of stalled torrents across the categories of every container
+// using a torrent client, or only those using the named client if it isn't empty
+func (c *Client) countStalledGlobal(cycle *fetchCycle, clientName string) (int, error) {
+	total := 0
+	for _, target := range c.archiveTargets() {
+		if clientName != "" && target.container.Client != clientName {
+			continue
+		}
+
+		if !countsStalled(target.client) {
+			continue
+		}
+
+		container := target.container
+		count, err := c.countStalled(cycle, container, target.client)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count stalled torrents on %s: %w", container.Client, err)
+		}
+		total += count
+	}
+
+	return total, nil
+}
 
-		if (isQbit || isRtorr) && container.MaxStalled > 0 {
+// countDownloading returns the number of incomplete, active torrents across the categories of every container
+func (c *Client) countDownloading(cycle *fetchCycle) (int, error) {
+	total := 0
+	for _, target := range c.archiveTargets() {
+		key := stalledKey{client: target.container.Client, category: target.container.Category}
+		c.mu.Lock()
+		count, ok := cycle.downloading[key]
+		c.mu.Unlock()
+		if ok {
+			total += count
+			continue
+		}
+
+		torrents, err := target.client.ListTorrents(target.container.Category)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list torrents on %s: %w", target.container.Client, err)
+		}
+
+		count = 0
+		for _, t := range torrents {
+			if !t.Complete() && (t.State == client.StateDownloading || t.State == client.StateStalled) {
+				count++
+			}
+		}
+
+		c.mu.Lock()
+		cycle.downloading[key] = count
+		c.mu.Unlock()
+		total += count
+	}
+
+	return total, nil
+}
+
+// fetch runs a single fetch for the container and records the result in the state
+func (c *Client) fetch(cycle *fetchCycle, name string) (FetchResult, error) {
+	start := time.Now()
+	result, err := c.fetchForContainer(cycle, name)
+	c.statsd.count("fetch", 1, "container", name, "result", string(result))
+	c.statsd.timing("fetch.duration", time.Since(start), "container", name)
+
+	if c.state != nil {
+		if stateErr := c.state.RecordFetch(name, string(result), err); stateErr != nil {
+			logger := c.containerLogger(name)
+			logger.Warn().Err(stateErr).Msg("failed to record fetch result")
+		}
+		c.updateBackoff(name, result)
+	}
+
+	return result, err
+}
+
+// defaultInterval is the run interval in minutes used for backoff when none is configured
+const defaultInterval = 360
+
+// updateBackoff backs off fetching for a container PTP had nothing to assign to, and clears
+// the backoff once a torrent is added again
+func (c *Client) updateBackoff(name string, result FetchResult) {
+	logger := c.containerLogger(name)
+	switch result {
+	case ResultNoTorrents:
+		interval := c.cfg.Interval
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+		until, err := c.state.Backoff(name, c.cfg.Backoff.AfterOrDefault(), time.Duration(interval)*time.Minute,
+			c.cfg.Backoff.MaxIntervalOrDefault())
+		if err != nil {
+			logger.Warn().Err(err).Msg("failed to record backoff")
+			return
+		}
+		if until.IsZero() {
+			return
+		}
+		logger.Info().
+			Time("until", until).
+			Msg("backing off fetching for container")
+	case ResultAdded:
+		if err := c.state.ClearBackoff(name); err != nil {
+			logger.Warn().Err(err).Msg("failed to clear backoff")
+		}
+	}
+}
+
+// isNoTorrentsMessage reports whether a PTP error message means there is currently nothing to assign
+func isNoTorrentsMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, phrase := range []string{"no torrents", "nothing to", "no more torrents", "no suitable"} {
+		if strings.Contains(msg, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchForContainer fetches and adds a single torrent for the container
+func (c *Client) fetchForContainer(cycle *fetchCycle, name string) (FetchResult, error) {
+	logger := c.containerLogger(name)
+	container, ok := c.cfg.Containers[name]
+	if !ok {
+		logger.Error().Msg("container not found")
+		return ResultError, fmt.Errorf("container %s not found", name)
+	}
+
+	torrentClient, result, err := c.containerClient(name, container)
+	if torrentClient == nil {
+		return result, err
+	}
+
+	// held until the torrent is added, even while waiting on PTP, so the checks below still hold then
+	defer c.lockTarget(name, container)()
+
+	// Only check stalled downloads for torrent clients, watch directories can't see what happens to their torrents
+	if container.Client != "" {
+		if countsStalled(torrentClient) && container.MaxStalled > 0 {
 			// Check stalled downloads count
-			stalledCount, err := torrentClient.CountStalledTorrents(container.Category)
+			stalledCount, err := c.countStalled(cycle, container, torrentClient)
 			if err != nil {
-				return err
+				return ResultError, err
 			}
 
-			c.log.Debug().
-				Str("container", name).
+			logger.Debug().
 				Str("category", container.Category).
 				Int("stalledCount", stalledCount).
 				Int("maxStalled", container.MaxStalled).
 				Msg("checking stalled downloads")
 
 			if stalledCount >= container.MaxStalled {
-				c.log.Info().
-					Str("container", name).
+				logger.Info().
 					Str("category", container.Category).
 					Int("stalledCount", stalledCount).
 					Int("maxStalled", container.MaxStalled).
 					Msg("skipping fetch due to too many stalled downloads")
-				return nil
+				return ResultStalled, nil
 			}
 		}
 	}
 
-	c.log.Info().
-		Str("container", name).
+	if limit := c.cfg.ClientMaxStalled(container.Client); limit > 0 {
+		total, err := c.countStalledGlobal(cycle, container.Client)
+		if err != nil {
+			return ResultError, err
+		}
+
+		if total >= limit {
+			logger.Info().
+				Int("stalledCount", total).
+				Int("maxStalled", limit).
+				Msg("skipping fetch due to too many stalled downloads on client")
+			return ResultClientStalled, nil
+		}
+	}
+
+	if c.cfg.MaxDownloading > 0 {
+		total, err := c.countDownloading(cycle)
+		if err != nil {
+			return ResultError, err
+		}
+
+		if total >= c.cfg.MaxDownloading {
+			logger.Info().
+				Int("downloading", total).
+				Int("maxDownloading", c.cfg.MaxDownloading).
+				Msg("skipping fetch, too many archive torrents downloading")
+			return ResultDownloading, nil
+		}
+	}
+
+	if c.cfg.MaxStalledGlobal > 0 {
+		total, err := c.countStalledGlobal(cycle, "")
+		if err != nil {
+			return ResultError, err
+		}
+
+		if total >= c.cfg.MaxStalledGlobal {
+			logger.Warn().
+				Int("stalledCount", total).
+				Int("maxStalledGlobal", c.cfg.MaxStalledGlobal).
+				Msg("skipping fetch, too many stalled downloads across all containers")
+			return ResultGlobalStalled, nil
+		}
+	}
+
+	if container.CountCategoryUsage && container.Client != "" {
+		remaining, err := remainingCapacity(container, torrentClient)
+		if err != nil {
+			return ResultError, err
+		}
+		if remaining <= 0 {
+			logger.Info().
+				Str("size", container.Size).
+				Msg("skipping fetch, the torrents in the category already fill the container")
+			return ResultContainerFull, nil
+		}
+	}
+
+	// with the fail policy, don't let PTP assign torrents that won't be downloaded
+	if err := c.scriptVersionErr(false); err != nil {
+		return ResultError, err
+	}
+
+	logger.Info().
 		Msg("fetching torrent for container")
 
-	torrent, err := c.fetchFromPTP(name, container)
+	start := time.Now()
+	torrent, torrentID, err := c.fetchFromPTP(name, container)
+	c.statsd.timing("ptp.duration", time.Since(start), "container", name)
+	if errors.Is(err, ErrNoTorrents) {
+		logger.Info().
+			Msg("PTP has no torrents to assign right now")
+		return ResultNoTorrents, nil
+	}
+	if errors.Is(err, ErrBlocklisted) {
+		logger.Info().
+			Err(err).
+			Str("torrentID", torrentID).
+			Msg("skipping blocklisted torrent")
+		return ResultBlocklisted, nil
+	}
 	if err != nil {
-		c.log.Error().
+		c.repeats.event("fetch:"+name, err, logger.Error(), logger).
 			Err(err).
-			Str("container", name).
 			Msg("failed to fetch torrent from PTP")
-		return fmt.Errorf("failed to fetch torrent: %w", err)
-	}
-
-	// extract torrent info
-	var t struct {
-		Info struct {
-			Name   string `bencode:"name"`
-			Length int64  `bencode:"length"`
-			Files  []struct {
-				Length int64    `bencode:"length"`
-				Path   []string `bencode:"path"`
-			} `bencode:"files"`
-		} `bencode:"info"`
-	}
-	if err := bencode.DecodeBytes(torrent, &t); err != nil {
-		c.log.Warn().
+		return ResultError, fmt.Errorf("failed to fetch torrent: %w", err)
+	}
+	c.repeats.resolve("fetch:"+name, logger, "fetching from PTP works again")
+
+	// the name patterns can only be matched once the torrent is downloaded
+	if meta, err := parseTorrent(torrent); err == nil {
+		if entry, ok := c.blocklisted(name, torrentID, meta.Name); ok {
+			logger.Info().
+				Str("torrent", meta.Name).
+				Str("torrentID", torrentID).
+				Str("entry", entry).
+				Msg("skipping blocklisted torrent")
+			c.recordBlocked(name, torrentID, meta.Name)
+			return ResultBlocklisted, nil
+		}
+	}
+
+	return c.addTorrent(name, container, torrentClient, torrent, torrentID)
+}
+
+// containerClient returns the torrent client the container adds to, creating its watch directory
+// client on first use. A nil client comes with the result of the skipped fetch.
+func (c *Client) containerClient(name string, container config.Container) (client.TorrentClient, FetchResult, error) {
+	logger := c.containerLogger(name)
+	if container.UsesWatchDir() {
+		// Use watch directory client
+		c.mu.Lock()
+		watchDirClient, ok := c.watchDirs[name]
+		c.mu.Unlock()
+		if !ok {
+			var err error
+			watchDirClient, err = client.NewWatchDirClient(container, logger)
+			if err != nil {
+				logger.Error().Err(err).Strs("watchDirs", container.WatchDirectories()).Msg("failed to create watch directory client")
+				return nil, ResultError, fmt.Errorf("failed to create watch directory client: %w", err)
+			}
+			c.mu.Lock()
+			c.watchDirs[name] = watchDirClient
+			c.mu.Unlock()
+		}
+		return watchDirClient, "", nil
+	}
+
+	if container.Client != "" {
+		c.mu.Lock()
+		err, down := c.unavailable[container.Client]
+		torrentClient, ok := c.clients[container.Client]
+		c.mu.Unlock()
+		if down && errors.Is(err, ErrCircuitOpen) {
+			logger.Debug().Err(err).Msg("skipping fetch, torrent client circuit is open")
+			return nil, ResultUnavailable, nil
+		}
+		if down {
+			c.repeats.event("skip:"+name, err, logger.Warn(), logger).
+				Err(err).
+				Msg("skipping fetch, torrent client is unavailable")
+			return nil, ResultUnavailable, nil
+		}
+		c.repeats.resolve("skip:"+name, logger, "")
+		if !ok {
+			logger.Error().Msg("client not found")
+			return nil, ResultError, fmt.Errorf("client %s not found", container.Client)
+		}
+		return torrentClient, "", nil
+	}
+
+	logger.Error().Msg("container must specify either watchDir or client")
+	return nil, ResultError, fmt.Errorf("container %s must specify either watchDir or client", name)
+}
+
+// lockTarget locks the client the container adds to, or its watch directory client, and returns the
+// unlock function. It keeps containers sharing a client from both passing the stalled and free space
+// checks for room only one of their torrents fits in.
+func (c *Client) lockTarget(name string, container config.Container) func() {
+	key := container.Client
+	if container.UsesWatchDir() {
+		key = "watchdir:" + name
+	}
+
+	c.mu.Lock()
+	lock, ok := c.targets[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.targets[key] = lock
+	}
+	c.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// addTorrent checks the free space for a downloaded torrent file and adds it to the container's client.
+// The caller must hold the container's lockTarget.
+func (c *Client) addTorrent(name string, container config.Container, torrentClient client.TorrentClient, torrent []byte, torrentID string) (FetchResult, error) {
+	logger := c.containerLogger(name)
+	meta, err := parseTorrent(torrent)
+	if err != nil {
+		logger.Warn().
 			Err(err).
 			Msg("failed to decode torrent info")
-		t.Info.Name = "unknown"
+		meta.Name = "unknown"
+	} else {
+		logger.Debug().
+			Str("torrentID", torrentID).
+			Str("torrent", meta.Name).
+			Str("infoHash", meta.InfoHash).
+			Msg("decoded torrent")
 	}
+	totalSize := meta.Size
 
-	// Calculate total size
-	var totalSize int64
-	if t.Info.Length > 0 {
-		totalSize = t.Info.Length
-	} else {
-		for _, file := range t.Info.Files {
-			totalSize += file.Length
+	// archive.php has no size options, so the container's size limits are enforced once the torrent is
+	// downloaded. They were validated in NewClient, and a torrent that couldn't be decoded has no size.
+	minSize, maxSize, _ := container.TorrentSizeLimits()
+	if err == nil && minSize > 0 && totalSize < minSize {
+		logger.Info().
+			Str("torrent", meta.Name).
+			Str("torrentID", torrentID).
+			Str("size", units.HumanSize(float64(totalSize))).
+			Str("minTorrentSize", units.HumanSize(float64(minSize))).
+			Msg("skipping torrent smaller than minTorrentSize")
+		return ResultSizeFiltered, nil
+	}
+	if maxSize > 0 && totalSize > maxSize {
+		logger.Warn().
+			Str("torrent", meta.Name).
+			Str("torrentID", torrentID).
+			Str("size", units.HumanSize(float64(totalSize))).
+			Str("maxTorrentSize", units.HumanSize(float64(maxSize))).
+			Msg("skipping torrent larger than maxTorrentSize")
+		if container.OversizeDir != "" {
+			c.saveOversized(logger, container.OversizeDir, meta.Name, torrentID, torrent)
 		}
+		return ResultSizeFiltered, nil
 	}
 
-	// Check available disk space - skip for rTorrent clients and watch directory clients
-	if _, isRTorrent := torrentClient.(*client.RTorrentClient); isRTorrent {
-		c.log.Debug().
-			Str("container", name).
+	if container.CountCategoryUsage && container.Client != "" {
+		remaining, err := remainingCapacity(container, torrentClient)
+		if err != nil {
+			return ResultError, err
+		}
+		if totalSize > remaining {
+			logger.Info().
+				Str("torrent", meta.Name).
+				Str("torrentID", torrentID).
+				Str("size", units.HumanSize(float64(totalSize))).
+				Str("remaining", units.HumanSize(float64(max(remaining, 0)))).
+				Msg("skipping torrent that doesn't fit in what is left of the container")
+			return ResultContainerFull, nil
+		}
+	}
+
+	spaceChecked := false
+	// Check available disk space - skip for watch directory clients and rTorrent clients without freeSpaceMode local
+	if rtorr, isRTorrent := torrentClient.(*client.RTorrentClient); isRTorrent && !rtorr.ReportsFreeSpace() {
+		logger.Debug().
 			Str("torrentSize", units.HumanSize(float64(totalSize))).
 			Msg("skipping disk space check for rTorrent")
 	} else if _, isWatchDir := torrentClient.(*client.WatchDirClient); isWatchDir {
-		c.log.Debug().
-			Str("container", name).
+		logger.Debug().
 			Str("torrentSize", units.HumanSize(float64(totalSize))).
 			Msg("skipping disk space check for watch directory")
 	} else {
+		spaceChecked = true
 		freeSpace, err := torrentClient.GetFreeSpace()
 		if err != nil {
-			c.log.Warn().
+			c.repeats.event("space:"+name, err, logger.Warn(), logger).
 				Err(err).
-				Str("container", name).
 				Msg("failed to get free space, skipping fetch")
-			return nil
+			return ResultSpaceUnknown, nil
+		}
+		c.repeats.resolve("space:"+name, logger, "")
+
+		if c.state != nil {
+			if err := c.state.RecordFreeSpace(container.Client, freeSpace); err != nil {
+				logger.Warn().Err(err).Msg("failed to record free space")
+			}
+		}
+
+		// torrents added earlier in the cycle may not have been written yet
+		if reserved := c.reservedSpace(container.Client); reserved > 0 {
+			logger.Debug().
+				Str("freeSpace", units.HumanSize(float64(freeSpace))).
+				Str("reservedSpace", units.HumanSize(float64(reserved))).
+				Msg("subtracting space reserved by torrents added this cycle")
+			freeSpace -= min(reserved, freeSpace)
 		}
 
 		// Add some buffer (10% extra) to the required space
 		requiredSpace := uint64(float64(totalSize) * 1.1)
 
-		c.log.Debug().
-			Str("container", name).
+		logger.Debug().
 			Str("availableSpace", units.HumanSize(float64(freeSpace))).
 			Str("requiredSpace", units.HumanSize(float64(requiredSpace))).
 			Str("torrentSize", units.HumanSize(float64(totalSize))).
 			Msg("checking disk space")
 
 		if freeSpace < requiredSpace {
-			c.log.Info().
-				Str("container", name).
+			logger.Info().
 				Str("freeSpace", units.HumanSize(float64(freeSpace))).
 				Str("requiredSpace", units.HumanSize(float64(requiredSpace))).
-				Str("torrentName", t.Info.Name).
+				Str("torrentName", meta.Name).
+				Str("infoHash", meta.InfoHash).
 				Msg("skipping fetch due to insufficient disk space")
-			return nil
+			return ResultNoSpace, nil
 		}
 	}
 
 	opts := map[string]string{
 		"category": container.Category,
 	}
-	if len(container.Tags) > 0 {
-		opts["tags"] = strings.Join(container.Tags, ",")
+	tags := torrentTags(container.Tags, container.AutoTags, meta.Name)
+	if container.TagTorrentID && torrentID != "" {
+		tags = append(tags, torrentIDTag(torrentID))
+		opts["tag_torrent_id"] = "true"
+	}
+	if len(tags) > 0 {
+		opts["tags"] = strings.Join(tags, ",")
 	}
 	if container.StartPaused || container.AddPaused {
 		opts["paused"] = "true"
 	}
+	if container.SequentialDownload {
+		opts["sequential"] = "true"
+	}
+	if container.FirstLastPiecePrio {
+		opts["first_last_piece_prio"] = "true"
+	}
+	// validated in NewClient
+	downloadLimit, uploadLimit, _ := container.SpeedLimits()
+	if downloadLimit > 0 {
+		opts["download_limit"] = strconv.FormatInt(downloadLimit, 10)
+	}
+	if uploadLimit > 0 {
+		opts["upload_limit"] = strconv.FormatInt(uploadLimit, 10)
+	}
+
+	if meta.InfoHash != "" {
+		opts["hash"] = meta.InfoHash
+	}
+	// also names the file in a watch directory when the torrent name can't be used
+	opts["torrent_id"] = torrentID
+	if container.Sidecar {
+		opts["sidecar"] = "true"
+		opts["size"] = strconv.FormatInt(totalSize, 10)
+	}
+
+	historyEntry := state.HistoryEntry{
+		Hash:      meta.InfoHash,
+		Name:      meta.Name,
+		Size:      totalSize,
+		Container: name,
+		Client:    container.Client,
+		TorrentID: torrentID,
+		AddedAt:   time.Now(),
+	}
 
-	err = torrentClient.AddTorrent(torrent, t.Info.Name, opts)
+	if announceHost := c.cfg.ContainerAnnounceHost(name); announceHost != "" {
+		// validated in NewClient
+		scheme, host, _ := config.ParseAnnounceHost(announceHost)
+		torrent, err = rewriteAnnounce(torrent, scheme, host)
+		if err != nil {
+			logger.Error().Err(err).Str("torrent", meta.Name).Msg("failed to rewrite announce URL")
+			return ResultError, fmt.Errorf("failed to rewrite announce URL: %w", err)
+		}
+		logger.Debug().Str("announceHost", announceHost).Msg("rewrote announce URL")
+	}
+
+	start := time.Now()
+	err = torrentClient.AddTorrent(torrent, meta.Name, opts)
+	c.statsd.timing("add.duration", time.Since(start), "container", name)
+	if errors.Is(err, client.ErrAlreadyExists) {
+		logger.Info().
+			Str("torrent", meta.Name).
+			Str("torrentID", torrentID).
+			Str("infoHash", meta.InfoHash).
+			Msg("torrent is already in the client, skipping")
+
+		// it's still part of the container as far as PTP is concerned
+		if _, known := c.historyEntry(meta.InfoHash); !known {
+			c.recordHistory(historyEntry)
+		}
+		c.statsd.count("add", 1, "container", name, "result", string(ResultDuplicate))
+		return ResultDuplicate, nil
+	}
 	if err != nil {
-		c.log.Error().
+		logger.Error().
 			Err(err).
-			Str("container", name).
+			Str("infoHash", meta.InfoHash).
 			Msg("failed to add torrent")
-		return fmt.Errorf("failed to add torrent: %w", err)
+		c.statsd.count("add", 1, "container", name, "result", string(ResultError))
+		return ResultError, fmt.Errorf("failed to add torrent: %w", err)
+	}
+	c.statsd.count("add", 1, "container", name, "result", string(ResultAdded))
+	c.statsd.count("add.bytes", totalSize, "container", name)
+
+	// the new torrent may itself be stalled, so count again before the next add to this category
+	c.mu.Lock()
+	for cycle := range c.cycles {
+		maps.DeleteFunc(cycle.stalled, func(key stalledKey, _ int) bool {
+			return key.client == container.Client && (key.category == container.Category || key.category == "")
+		})
+		delete(cycle.downloading, stalledKey{client: container.Client, category: container.Category})
 	}
+	// and keep its size from being counted as free space by the next add to this client
+	if spaceChecked && c.reserved != nil {
+		c.reserved[container.Client] += uint64(totalSize)
+	}
+	c.mu.Unlock()
 
-	c.log.Info().
-		Str("container", name).
-		Str("torrent", t.Info.Name).
+	logger.Info().
+		Str("torrent", meta.Name).
+		Str("torrentID", torrentID).
+		Str("infoHash", meta.InfoHash).
 		Str("size", units.HumanSize(float64(totalSize))).
 		Msg("successfully added torrent")
 
-	return nil
+	if watchDirClient, ok := torrentClient.(*client.WatchDirClient); ok {
+		historyEntry.WatchFile = watchDirClient.LastSaved()
+	}
+	c.recordHistory(historyEntry)
+
+	return ResultAdded, nil
 }
 
-func (c *Client) FetchAll() error {
-	var errors []error
+// saveOversized keeps a torrent skipped for its size in the directory, so it can still be added by hand
+func (c *Client) saveOversized(logger zerolog.Logger, dir, name, torrentID string, torrent []byte) {
+	path := filepath.Join(dir, client.SafeFileName(name, torrentID)+".torrent")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.Warn().Err(err).Str("dir", dir).Msg("failed to create oversize directory")
+		return
+	}
+	if err := os.WriteFile(path, torrent, 0644); err != nil {
+		logger.Warn().Err(err).Str("path", path).Msg("failed to save oversized torrent")
+		return
+	}
+	logger.Info().Str("path", path).Msg("saved oversized torrent")
+}
+
+// FetchAll fetches a single torrent for every container
+func (c *Client) FetchAll() (*FetchSummary, error) {
 	containers := make([]string, 0, len(c.cfg.Containers))
 
 	for name := range c.cfg.Containers {
 		containers = append(containers, name)
 	}
 
+	return c.FetchContainers(containers, 1)
+}
+
+// FetchContainers fetches up to count torrents for each of the named containers in turn.
+// Failing containers don't stop the others and are reported in the summary instead.
+func (c *Client) FetchContainers(containers []string, count int) (*FetchSummary, error) {
+	for _, name := range containers {
+		if _, ok := c.cfg.Containers[name]; !ok {
+			c.log.Error().Str("container", name).Msg("container not found")
+			return nil, fmt.Errorf("container %s not found", name)
+		}
+	}
+
+	summary := newFetchSummary()
+	start := time.Now()
+	results := make(map[string]string, len(containers))
+
+	c.preflight(containers)
+	cycle := c.startCycle()
+	defer c.endCycle(cycle)
+
 	c.log.Debug().
 		Int("containerCount", len(containers)).
-		Msg("starting fetch for all containers")
+		Msg("starting fetch for containers")
 
 	for i, name := range containers {
-		c.log.Debug().
-			Str("container", name).
+		logger := c.containerLogger(name)
+		logger.Debug().
 			Int("index", i+1).
 			Int("total", len(containers)).
 			Msg("processing container")
 
-		if err := c.FetchForContainer(name); err != nil {
-			errors = append(errors, fmt.Errorf("%s: %w", name, err))
+		if c.state != nil && c.state.IsPaused(name) {
+			logger.Info().Msg("container is paused, skipping fetch")
+			results[name] = "skipped: paused"
+			continue
+		}
+
+		if c.state != nil {
+			if until := c.state.BackoffUntil(name); time.Now().Before(until) {
+				logger.Info().
+					Time("until", until).
+					Msg("PTP had no torrents for container recently, skipping fetch")
+				results[name] = "skipped: backoff"
+				continue
+			}
 		}
 
-		// only sleep if this isn't the last container
-		if i < len(containers)-1 {
-			c.log.Debug().
-				Int("seconds", c.cfg.FetchSleep).
-				Msg("sleeping between container fetches")
-			time.Sleep(time.Duration(c.cfg.FetchSleep) * time.Second)
+		result, err := c.fetchCount(cycle, name, count)
+		summary.Results[result]++
+		results[name] = string(result)
+		if err != nil {
+			summary.Errors = append(summary.Errors, fmt.Errorf("%s: %w", name, err))
 		}
 	}
 
-	if len(errors) > 0 {
+	c.writeMetrics(start, cycle, containers, results)
+
+	if len(summary.Errors) > 0 {
 		c.log.Error().
-			Int("failedCount", len(errors)).
-			Errs("errors", errors).
+			Int("failedCount", len(summary.Errors)).
+			Errs("errors", summary.Errors).
 			Msg("failed to fetch for some containers")
-		return nil
+		return summary, nil
 	}
 
-	c.log.Info().Msg("successfully completed fetch for all containers")
-	return nil
+	c.log.Info().Msg("successfully completed fetch for containers")
+	return summary, nil
 }
@@ -0,0 +1,111 @@
+package archiver
+
+import (
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/mock"
+)
+
+// stalledClient is an in-memory client reporting a fixed number of stalled torrents
+type stalledClient struct {
+	*mock.Client
+	stalled int
+	calls   atomic.Int32
+	// states are those of the last count
+	states []string
+}
+
+func (c *stalledClient) CountStalledTorrents(category string, states []string, metadataAfter time.Duration) (int, error) {
+	c.calls.Add(1)
+	c.states = states
+	return c.stalled, nil
+}
+
+// newTestClient returns an archiver for cfg connected to tc for every configured client
+func newTestClient(t *testing.T, cfg *config.Config, tc client.TorrentClient) *Client {
+	t.Helper()
+	client.UseFakeClients(func(string) client.TorrentClient { return tc })
+	t.Cleanup(func() { client.UseFakeClients(nil) })
+
+	cfg.ApiUser, cfg.ApiKey, cfg.BaseURL = "user", "key", "http://127.0.0.1:1"
+	c, err := NewClient(cfg, "test", "", "")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return c
+}
+
+func TestFetchSkipsStalledClients(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.Config
+	}{
+		{name: "qbittorrent", cfg: &config.Config{QBitClients: map[string]config.QBitConfig{"seedbox": {}}}},
+		{name: "rtorrent", cfg: &config.Config{RTorrClients: map[string]config.RTorrConfig{"seedbox": {}}}},
+		{name: "deluge", cfg: &config.Config{DelugeClients: map[string]config.DelugeConfig{"seedbox": {}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Containers = map[string]config.Container{
+				"archive": {Client: "seedbox", Category: "archive", Size: "1T", MaxStalled: 2},
+			}
+			tc := &stalledClient{Client: mock.NewClient(), stalled: 2}
+			c := newTestClient(t, tt.cfg, tc)
+
+			summary, err := c.FetchContainers([]string{"archive"}, 1)
+			if err != nil {
+				t.Fatalf("FetchContainers() error = %v", err)
+			}
+			if summary.Results[ResultStalled] != 1 {
+				t.Errorf("FetchContainers() results = %v, want the fetch skipped as stalled", summary.Results)
+			}
+		})
+	}
+}
+
+func TestFetchCountsStalledStatesOnDeluge(t *testing.T) {
+	cfg := &config.Config{
+		DelugeClients: map[string]config.DelugeConfig{"seedbox": {}},
+		Containers: map[string]config.Container{
+			"archive": {Client: "seedbox", Category: "archive", Size: "1T", MaxStalled: 1, StalledStates: []string{"stalled", "queued"}},
+		},
+	}
+	tc := &stalledClient{Client: mock.NewClient(), stalled: 1}
+	c := newTestClient(t, cfg, tc)
+
+	if _, err := c.FetchContainers([]string{"archive"}, 1); err != nil {
+		t.Fatalf("FetchContainers() error = %v", err)
+	}
+	if !slices.Equal(tc.states, []string{"stalled", "queued"}) {
+		t.Errorf("counted states %v, want the container's stalledStates", tc.states)
+	}
+}
+
+func TestFetchCountsStalledOncePerCycle(t *testing.T) {
+	cfg := &config.Config{
+		QBitClients: map[string]config.QBitConfig{"seedbox": {}},
+		Containers: map[string]config.Container{
+			"hetzner": {Client: "seedbox", Category: "archive", Size: "1T", MaxStalled: 2},
+			"ovh":     {Client: "seedbox", Category: "archive", Size: "1T", MaxStalled: 2},
+		},
+	}
+	tc := &stalledClient{Client: mock.NewClient(), stalled: 2}
+	c := newTestClient(t, cfg, tc)
+
+	summary, err := c.FetchContainers([]string{"hetzner", "ovh"}, 1)
+	if err != nil {
+		t.Fatalf("FetchContainers() error = %v", err)
+	}
+	if summary.Results[ResultStalled] != 2 {
+		t.Errorf("FetchContainers() results = %v, want both fetches skipped as stalled", summary.Results)
+	}
+	if calls := tc.calls.Load(); calls != 1 {
+		t.Errorf("CountStalledTorrents() called %d times, want the count shared by the containers", calls)
+	}
+}
@@ -0,0 +1,156 @@
+package archiver
+
+import (
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/state"
+)
+
+// AuditProblem describes why an archived torrent isn't being seeded
+type AuditProblem string
+
+const (
+	ProblemDeleted     AuditProblem = "deleted from client"
+	ProblemMissingData AuditProblem = "missing data"
+	ProblemErrored     AuditProblem = "errored"
+)
+
+// AuditFinding is an archived torrent from the history that failed the audit
+type AuditFinding struct {
+	Entry   state.HistoryEntry
+	Problem AuditProblem
+	// Message is the error reported by the client, if any
+	Message string
+}
+
+// AuditResult is the outcome of an audit of every archived torrent
+type AuditResult struct {
+	// Checked is the number of history entries whose client could be queried
+	Checked int
+	// Unchecked is the number of history entries whose client is unavailable or a watch directory
+	Unchecked int
+	Findings  []AuditFinding
+	// Resumed is the number of paused torrents that were started again
+	Resumed int
+	// Pickup is the state of the .torrent files saved to watch directories
+	Pickup PickupResult
+}
+
+// Audit verifies that every torrent recorded in the history still exists on its client with its data,
+// logging a warning for each one that doesn't
+func (c *Client) Audit() *AuditResult {
+	result := &AuditResult{}
+	if c.history == nil {
+		return result
+	}
+
+	c.mu.Lock()
+	connected := make(map[string]client.TorrentClient, len(c.clients))
+	for name, tc := range c.clients {
+		if _, down := c.unavailable[name]; !down {
+			connected[name] = tc
+		}
+	}
+	c.mu.Unlock()
+
+	torrentsByClient := make(map[string]map[string]client.Torrent)
+	for name, tc := range connected {
+		torrents, err := tc.ListTorrents("")
+		if err != nil {
+			c.log.Error().Err(err).Str("client", name).Msg("failed to list torrents for audit")
+			continue
+		}
+
+		byHash := make(map[string]client.Torrent, len(torrents))
+		for _, t := range torrents {
+			byHash[t.Hash] = t
+		}
+		torrentsByClient[name] = byHash
+	}
+
+	for _, e := range c.history.Entries() {
+		byHash, ok := torrentsByClient[e.Client]
+		if !ok {
+			result.Unchecked++
+			continue
+		}
+		result.Checked++
+
+		finding := AuditFinding{Entry: e}
+		t, ok := byHash[e.Hash]
+		switch {
+		case !ok:
+			finding.Problem = ProblemDeleted
+		case t.State == client.StateMissing:
+			finding.Problem = ProblemMissingData
+			finding.Message = t.Message
+		case t.State == client.StateError:
+			finding.Problem = ProblemErrored
+			finding.Message = t.Message
+		case t.State == client.StatePaused:
+			if c.resumePaused(connected[e.Client], e) {
+				result.Resumed++
+			}
+			continue
+		default:
+			continue
+		}
+
+		c.log.Warn().
+			Str("container", e.Container).
+			Str("client", e.Client).
+			Str("torrent", e.Name).
+			Str("infoHash", e.Hash).
+			Str("problem", string(finding.Problem)).
+			Str("message", finding.Message).
+			Msg("archived torrent failed audit")
+		result.Findings = append(result.Findings, finding)
+	}
+
+	result.Pickup = c.checkPickup()
+
+	c.log.Info().
+		Int("checked", result.Checked).
+		Int("unchecked", result.Unchecked).
+		Int("problems", len(result.Findings)).
+		Int("resumed", result.Resumed).
+		Msg("finished seeding audit")
+
+	if c.state != nil {
+		if err := c.state.RecordAudit(len(result.Findings)); err != nil {
+			c.log.Warn().Err(err).Msg("failed to record audit result")
+		}
+	}
+
+	return result
+}
+
+// resumePaused starts an archived torrent found paused when autoResume is enabled, unless its container
+// adds torrents paused on purpose. It reports whether the torrent was resumed.
+func (c *Client) resumePaused(tc client.TorrentClient, e state.HistoryEntry) bool {
+	if !c.cfg.AutoResume {
+		return false
+	}
+
+	container, ok := c.cfg.Containers[e.Container]
+	if !ok || container.StartPaused || container.AddPaused {
+		return false
+	}
+
+	if err := tc.ResumeTorrent(e.Hash); err != nil {
+		c.log.Error().
+			Err(err).
+			Str("client", e.Client).
+			Str("torrent", e.Name).
+			Str("infoHash", e.Hash).
+			Msg("failed to resume paused archive torrent")
+		return false
+	}
+
+	c.log.Warn().
+		Str("container", e.Container).
+		Str("client", e.Client).
+		Str("torrent", e.Name).
+		Str("infoHash", e.Hash).
+		Msg("resumed archive torrent that was paused")
+	return true
+}
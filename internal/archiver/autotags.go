@@ -0,0 +1,67 @@
+package archiver
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	resolutionPattern = regexp.MustCompile(`\b(2160p|1080p|1080i|720p|576p|480p)\b`)
+	yearPattern       = regexp.MustCompile(`\b(19\d{2}|20\d{2})\b`)
+)
+
+// sourcePatterns map the release sources recognized in torrent names to their tag, a remux is
+// tagged with both remux and bluray
+var sourcePatterns = []struct {
+	tag     string
+	pattern *regexp.Regexp
+}{
+	{"remux", regexp.MustCompile(`\bremux\b`)},
+	{"bluray", regexp.MustCompile(`\b(blu-?ray|bdrip|brrip)\b`)},
+	{"web-dl", regexp.MustCompile(`\bweb-?dl\b`)},
+	{"webrip", regexp.MustCompile(`\bweb-?rip\b`)},
+	{"hdtv", regexp.MustCompile(`\bhdtv\b`)},
+	{"dvd", regexp.MustCompile(`\bdvd(rip|5|9)?\b`)},
+}
+
+// releaseTags derives tags for the resolution, year and source from a release name such as
+// Movie.Title.1999.1080p.BluRay.REMUX.AVC-GROUP, which gives 1080p, 1999, remux and bluray
+func releaseTags(name string) []string {
+	// underscores are word characters, so they'd hide the tokens from \b
+	name = strings.ToLower(strings.ReplaceAll(name, "_", "."))
+
+	var tags []string
+	if m := resolutionPattern.FindString(name); m != "" {
+		tags = append(tags, m)
+	}
+	// the last year, since titles can start with one such as 2001.A.Space.Odyssey.1968
+	if m := yearPattern.FindAllString(name, -1); len(m) > 0 {
+		tags = append(tags, m[len(m)-1])
+	}
+	for _, source := range sourcePatterns {
+		if source.pattern.MatchString(name) {
+			tags = append(tags, source.tag)
+		}
+	}
+	return tags
+}
+
+// torrentIDTag returns the tag tracing a torrent back to its PTP torrent ID
+func torrentIDTag(torrentID string) string {
+	return "ptp-" + torrentID
+}
+
+// torrentTags returns the container's tags, followed by the tags derived from the torrent name when autoTags is set
+func torrentTags(configured []string, autoTags bool, name string) []string {
+	tags := slices.Clone(configured)
+	if !autoTags {
+		return tags
+	}
+	for _, tag := range releaseTags(name) {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
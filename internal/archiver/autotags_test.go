@@ -0,0 +1,60 @@
+package archiver
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestReleaseTags(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{name: "Movie.Title.1999.1080p.BluRay.REMUX.AVC-GROUP", want: []string{"1080p", "1999", "remux", "bluray"}},
+		{name: "Movie.Title.2012.720p.WEB-DL.DD5.1.H.264-GROUP", want: []string{"720p", "2012", "web-dl"}},
+		{name: "Movie.Title.2020.2160p.WEBRip.x265-GROUP", want: []string{"2160p", "2020", "webrip"}},
+		{name: "Blade_Runner_1982_2160p_UHD_BluRay_x265", want: []string{"2160p", "1982", "bluray"}},
+		{name: "2001.A.Space.Odyssey.1968.1080p.BDRip.x264", want: []string{"1080p", "1968", "bluray"}},
+		{name: "Some Movie (1995) DVD9", want: []string{"1995", "dvd"}},
+		{name: "Old.Show.1080i.HDTV", want: []string{"1080i", "hdtv"}},
+		{name: "Movie.Title.10800p.19999", want: nil},
+		{name: "Untitled", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := releaseTags(tt.name); !slices.Equal(got, tt.want) {
+				t.Errorf("releaseTags(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTorrentTags(t *testing.T) {
+	const name = "Movie.Title.1999.1080p.BluRay.x264-GROUP"
+
+	tests := []struct {
+		name       string
+		configured []string
+		autoTags   bool
+		want       []string
+	}{
+		{name: "configured only", configured: []string{"archive"}, want: []string{"archive"}},
+		{name: "auto tags", configured: []string{"archive"}, autoTags: true, want: []string{"archive", "1080p", "1999", "bluray"}},
+		{name: "no configured tags", autoTags: true, want: []string{"1080p", "1999", "bluray"}},
+		{name: "duplicates", configured: []string{"bluray", "archive"}, autoTags: true, want: []string{"bluray", "archive", "1080p", "1999"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := torrentTags(tt.configured, tt.autoTags, name)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("torrentTags() = %v, want %v", got, tt.want)
+			}
+			// the container's tags are shared by every torrent, so they must not be modified
+			if len(tt.configured) > 0 && len(got) > len(tt.configured) && &got[0] == &tt.configured[0] {
+				t.Error("torrentTags() appended to the configured tags")
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package archiver
+
+// blocklisted returns the entry of the global or container blocklist matching the torrent. The name
+// is empty while only the torrent ID is known, then the name recorded when it was blocked before is used.
+func (c *Client) blocklisted(name, torrentID, torrentName string) (string, bool) {
+	if torrentName == "" && c.state != nil {
+		torrentName, _ = c.state.BlockedName(name, torrentID)
+	}
+	if entry, ok := c.cfg.Blocklist.Match(torrentID, torrentName); ok {
+		return entry, true
+	}
+	return c.cfg.Containers[name].Blocklist.Match(torrentID, torrentName)
+}
+
+// recordBlocked remembers the name of a blocklisted torrent, so it isn't downloaded again to match it
+// when PTP assigns it to the container once more
+func (c *Client) recordBlocked(name, torrentID, torrentName string) {
+	if c.state == nil || torrentID == "" {
+		return
+	}
+	if err := c.state.RecordBlocked(name, torrentID, torrentName); err != nil {
+		logger := c.containerLogger(name)
+		logger.Warn().Err(err).Msg("failed to record blocklisted torrent")
+	}
+}
@@ -0,0 +1,68 @@
+package archiver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+)
+
+// circuits tracks the failed connection attempts and preflight checks of every torrent client. Once a client fails
+// circuitBreaker.failures times in a row its circuit opens and it isn't tried again until the
+// cooldown has passed, instead of timing out on it every cycle.
+type circuits struct {
+	cfg config.CircuitBreaker
+
+	mu        sync.Mutex
+	failures  map[string]int
+	openUntil map[string]time.Time
+}
+
+func newCircuits(cfg config.CircuitBreaker) *circuits {
+	return &circuits{
+		cfg:       cfg,
+		failures:  make(map[string]int),
+		openUntil: make(map[string]time.Time),
+	}
+}
+
+// open returns until when the client's circuit is open, or false if it may be connected to
+func (c *circuits) open(name string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	until, ok := c.openUntil[name]
+	if !ok || !time.Now().Before(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// failure records a failed attempt and returns until when the circuit is open, if it is, and whether this
+// attempt opened it. The first attempt after the cooldown reopens it right away if it fails too, which
+// isn't reported as opening again.
+func (c *circuits) failure(name string) (until time.Time, opened bool) {
+	if !c.cfg.Enabled() {
+		return time.Time{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures[name]++
+	if c.failures[name] < c.cfg.Failures {
+		return time.Time{}, false
+	}
+	_, reopened := c.openUntil[name]
+	until = time.Now().Add(c.cfg.CooldownOrDefault())
+	c.openUntil[name] = until
+	return until, !reopened
+}
+
+// success resets the client's failures and reports whether its circuit had been opened
+func (c *circuits) success(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, wasOpen := c.openUntil[name]
+	delete(c.failures, name)
+	delete(c.openUntil, name)
+	return wasOpen
+}
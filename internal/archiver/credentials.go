@@ -0,0 +1,97 @@
+package archiver
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/httpclient"
+)
+
+// CheckCredentials verifies the API credentials against PTP without fetching anything, returning
+// ErrUnauthorized if they are rejected. Unlike NewClient it doesn't connect to any torrent client.
+func CheckCredentials(cfg *config.Config, ver string) error {
+	httpClient, err := httpclient.New(httpclient.Options{
+		Timeouts:    cfg.Timeouts,
+		BindAddress: cfg.BindAddress,
+		IPFamily:    cfg.IPFamily,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create http client: %w", err)
+	}
+
+	wrapTransport(httpClient)
+
+	c := &Client{cfg: cfg, http: httpClient, version: ver}
+	return c.CheckCredentials()
+}
+
+// CheckCredentials verifies the API credentials of every container against PTP, returning ErrUnauthorized
+// if any are rejected. Containers sharing credentials are checked once.
+func (c *Client) CheckCredentials() error {
+	names := slices.Sorted(maps.Keys(c.cfg.Containers))
+	if len(names) == 0 {
+		names = []string{""}
+	}
+
+	global := c.cfg.Account("")
+	var checked []config.Account
+	for _, name := range names {
+		account := c.cfg.Account(name)
+		if slices.Contains(checked, account) {
+			continue
+		}
+		checked = append(checked, account)
+
+		if err := c.checkAccount(account); err != nil {
+			if account != global {
+				return fmt.Errorf("container %s: %w", name, err)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkAccount verifies a single set of API credentials
+func (c *Client) checkAccount(account config.Account) error {
+	if account.ApiUser == "" || account.ApiKey == "" {
+		return fmt.Errorf("%w: apiUser and apiKey must be set", ErrUnauthorized)
+	}
+
+	// an empty search is the cheapest API call that requires the credentials
+	checkURL := fmt.Sprintf("%s/%s", account.BaseURL, "torrents.php")
+	req, err := http.NewRequest("GET", checkURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create credentials request: %w", err)
+	}
+
+	c.setHeaders(req, account)
+
+	q := req.URL.Query()
+	q.Add("json", "noredirect")
+	q.Add("searchstr", "")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach PTP: %w", err)
+	}
+	defer drainAndClose(resp.Body)
+
+	// without valid credentials PTP either refuses the request or redirects to the login page
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden ||
+		strings.Contains(resp.Request.URL.Path, "login") {
+		return fmt.Errorf("%w: %s", ErrUnauthorized, resp.Status)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from PTP: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package archiver
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidConfig is returned by NewClient when the config fails validation
+	ErrInvalidConfig = errors.New("invalid config")
+	// ErrUnauthorized is returned when PTP rejects the API credentials
+	ErrUnauthorized = errors.New("PTP rejected the API credentials")
+	// ErrClientUnavailable is returned when a torrent client can't be connected to
+	ErrClientUnavailable = errors.New("failed to connect to torrent client")
+	// ErrCircuitOpen is returned for a torrent client that is skipped after failing to connect repeatedly
+	ErrCircuitOpen = errors.New("circuit open")
+	// ErrScriptVersion is returned when PTP reports a newer official script version and the version policy is fail
+	ErrScriptVersion = errors.New("PTP reports a newer official script version")
+	// ErrNoTorrents is returned when PTP currently has no torrents to assign to a container
+	ErrNoTorrents = errors.New("PTP has no torrents available")
+	// ErrBlocklisted is returned when PTP assigns a torrent that is on the blocklist
+	ErrBlocklisted = errors.New("torrent is blocklisted")
+)
+
+// FetchSummary collects the outcome of fetching for several containers
+type FetchSummary struct {
+	// Results counts the result of every fetch attempt
+	Results map[FetchResult]int
+	// Errors holds the error of every container that failed, prefixed with the container name
+	Errors []error
+}
+
+func newFetchSummary() *FetchSummary {
+	return &FetchSummary{Results: make(map[FetchResult]int)}
+}
+
+// Unavailable reports whether any container was skipped because its torrent client couldn't be connected to
+func (s *FetchSummary) Unavailable() bool {
+	return s.Results[ResultUnavailable] > 0
+}
+
+// Skipped reports whether any fetch was skipped by the stalled or free space checks
+func (s *FetchSummary) Skipped() bool {
+	for result, n := range s.Results {
+		if n > 0 && result != ResultAdded && result != ResultError && result != ResultUnavailable {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the errors of all failed containers combined, or nil if none failed
+func (s *FetchSummary) Err() error {
+	if len(s.Errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to fetch for %d container(s): %w", len(s.Errors), errors.Join(s.Errors...))
+}
@@ -0,0 +1,145 @@
+package archiver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/httpclient"
+)
+
+// influxWriter posts the metrics of every fetch cycle in InfluxDB line protocol
+type influxWriter struct {
+	cfg  config.Influx
+	url  string
+	http *http.Client
+}
+
+// newInfluxWriter returns a writer for the configured server, or nil if writing metrics isn't enabled
+func newInfluxWriter(cfg *config.Config) (*influxWriter, error) {
+	if !cfg.Influx.Enabled() {
+		return nil, nil
+	}
+
+	writeURL, err := cfg.Influx.WriteURL()
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := httpclient.New(httpclient.Options{Timeouts: cfg.Timeouts})
+	if err != nil {
+		return nil, err
+	}
+
+	return &influxWriter{cfg: cfg.Influx, url: writeURL, http: httpClient}, nil
+}
+
+// write posts the lines to the server
+func (w *influxWriter) write(lines []string) error {
+	req, err := http.NewRequest("POST", w.url, strings.NewReader(strings.Join(lines, "\n")+"\n"))
+	if err != nil {
+		return fmt.Errorf("failed to create influx request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	switch {
+	case w.cfg.Token != "":
+		req.Header.Set("Authorization", "Token "+w.cfg.Token)
+	case w.cfg.Username != "":
+		req.SetBasicAuth(w.cfg.Username, w.cfg.Password)
+	}
+
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write to influx: %w", err)
+	}
+	defer drainAndClose(resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influx rejected the write: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// writeMetrics writes what the fetch cycle for the containers did: the result, torrents added and stalled
+// count of each container, their archive totals, and the last free space sample of their clients
+func (c *Client) writeMetrics(start time.Time, cycle *fetchCycle, containers []string, results map[string]string) {
+	if c.influx == nil {
+		return
+	}
+
+	now := time.Now().Unix()
+	var lines []string
+
+	added := make(map[string]int)
+	addedBytes := make(map[string]int64)
+	total := make(map[string]int)
+	totalBytes := make(map[string]int64)
+	if c.history != nil {
+		for _, e := range c.history.Entries() {
+			total[e.Container]++
+			totalBytes[e.Container] += e.Size
+			if !e.AddedAt.Before(start) {
+				added[e.Container]++
+				addedBytes[e.Container] += e.Size
+			}
+		}
+	}
+
+	clients := make(map[string]bool)
+	for _, name := range containers {
+		container := c.cfg.Containers[name]
+		tags := "container=" + influxTag(name)
+
+		fields := fmt.Sprintf("result=%s,added_torrents=%di,added_bytes=%di",
+			influxString(results[name]), added[name], addedBytes[name])
+		c.mu.Lock()
+		stalled, ok := cycle.stalled[stalledKeyFor(container)]
+		c.mu.Unlock()
+		if ok {
+			fields += fmt.Sprintf(",stalled=%di", stalled)
+		}
+		lines = append(lines, fmt.Sprintf("ptparchiver_fetch,%s %s %d", tags, fields, now))
+
+		lines = append(lines, fmt.Sprintf("ptparchiver_archive,%s torrents=%di,bytes=%di %d",
+			tags, total[name], totalBytes[name], now))
+
+		if container.Client != "" && !container.UsesWatchDir() {
+			clients[container.Client] = true
+		}
+	}
+
+	if c.state != nil {
+		for name := range clients {
+			// written with the time it was taken, since it is sampled at most hourly
+			if sample, ok := c.state.LatestFreeSpace(name); ok {
+				lines = append(lines, fmt.Sprintf("ptparchiver_client,client=%s free_bytes=%di %d",
+					influxTag(name), sample.Free, sample.Time.Unix()))
+			}
+		}
+	}
+
+	if err := c.influx.write(lines); err != nil {
+		c.repeats.event("influx", err, c.log.Warn(), c.log).
+			Err(err).
+			Msg("failed to write metrics")
+		return
+	}
+	c.repeats.resolve("influx", c.log, "writing metrics works again")
+	c.log.Debug().Int("lines", len(lines)).Msg("wrote metrics")
+}
+
+// influxTag escapes a tag value for line protocol
+func influxTag(v string) string {
+	return strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `).Replace(v)
+}
+
+// influxString quotes a string field value for line protocol
+func influxString(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
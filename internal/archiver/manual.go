@@ -0,0 +1,63 @@
+package archiver
+
+import (
+	"fmt"
+)
+
+// Redownload downloads the torrent file with the given PTP torrent ID from torrents.php and adds it to
+// the container like a fetched one, e.g. after it was accidentally removed from the client. The stalled
+// and downloading limits are not applied since the torrent is already part of the container.
+func (c *Client) Redownload(name, torrentID string) (FetchResult, error) {
+	logger := c.containerLogger(name)
+	container, ok := c.cfg.Containers[name]
+	if !ok {
+		logger.Error().Msg("container not found")
+		return ResultError, fmt.Errorf("container %s not found", name)
+	}
+
+	torrentClient, result, err := c.containerClient(name, container)
+	if torrentClient == nil {
+		return result, err
+	}
+
+	logger.Info().
+		Str("torrentID", torrentID).
+		Msg("re-downloading torrent")
+
+	c.ptpLimiter.Wait()
+	torrent, err := c.downloadTorrent(logger, c.cfg.Account(name), torrentID)
+	if err != nil {
+		return ResultError, err
+	}
+
+	defer c.lockTarget(name, container)()
+	return c.addTorrent(name, container, torrentClient, torrent, torrentID)
+}
+
+// Inject adds a local torrent file to the container like a fetched one, so torrents PTP assigned outside
+// of archive.php are tracked in the history too. The torrent ID is optional.
+func (c *Client) Inject(name string, torrent []byte, torrentID string) (FetchResult, error) {
+	logger := c.containerLogger(name)
+	container, ok := c.cfg.Containers[name]
+	if !ok {
+		logger.Error().Msg("container not found")
+		return ResultError, fmt.Errorf("container %s not found", name)
+	}
+
+	// unlike a download from PTP, a local file could be anything
+	if _, err := parseTorrent(torrent); err != nil {
+		return ResultError, fmt.Errorf("invalid torrent file: %w", err)
+	}
+
+	torrentClient, result, err := c.containerClient(name, container)
+	if torrentClient == nil {
+		return result, err
+	}
+
+	logger.Info().
+		Str("torrentID", torrentID).
+		Msg("injecting torrent")
+
+	defer c.lockTarget(name, container)()
+	return c.addTorrent(name, container, torrentClient, torrent, torrentID)
+}
@@ -0,0 +1,104 @@
+package archiver
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/s0up4200/ptparchiver-go/internal/state"
+)
+
+// pickupWarnAfter is how long a watch directory file may wait before it's reported as never picked up
+const pickupWarnAfter = 24 * time.Hour
+
+// PickupResult counts the watch directory files checked by an audit
+type PickupResult struct {
+	// PickedUp is the number of files found consumed by the downstream client since the last audit
+	PickedUp int
+	// Waiting is the number of files still in the watch directory
+	Waiting int
+	// NotPickedUp is the number of waiting files older than a day
+	NotPickedUp int
+	// CleanedUp is the number of old files removed because of watchDirCleanup
+	CleanedUp int
+}
+
+// checkPickup records which watch directory files have disappeared, warns about files the downstream client
+// never picked up and removes them once they're older than the container's watchDirCleanup
+func (c *Client) checkPickup() PickupResult {
+	var result PickupResult
+	if c.history == nil {
+		return result
+	}
+
+	now := time.Now()
+	var updated []state.HistoryEntry
+	for _, e := range c.history.Entries() {
+		if e.WatchFile == "" || e.PickedUpAt != nil || e.CleanedUpAt != nil {
+			continue
+		}
+
+		_, err := os.Stat(e.WatchFile)
+		if errors.Is(err, os.ErrNotExist) {
+			e.PickedUpAt = &now
+			updated = append(updated, e)
+			result.PickedUp++
+			continue
+		}
+		if err != nil {
+			c.log.Warn().Err(err).Str("path", e.WatchFile).Msg("failed to check watch directory file")
+			continue
+		}
+
+		result.Waiting++
+		age := now.Sub(e.AddedAt)
+		if age < pickupWarnAfter {
+			continue
+		}
+
+		if days := c.cfg.Containers[e.Container].WatchDirCleanup; days > 0 && age >= time.Duration(days)*24*time.Hour {
+			if err := os.Remove(e.WatchFile); err != nil {
+				c.log.Error().Err(err).Str("path", e.WatchFile).Msg("failed to remove watch directory file")
+				continue
+			}
+			// the sidecar is useless without its torrent
+			os.Remove(strings.TrimSuffix(e.WatchFile, ".torrent") + ".json")
+
+			c.log.Warn().
+				Str("container", e.Container).
+				Str("path", e.WatchFile).
+				Str("age", age.Round(time.Hour).String()).
+				Msg("removed watch directory file that was never picked up")
+			e.CleanedUpAt = &now
+			updated = append(updated, e)
+			result.Waiting--
+			result.CleanedUp++
+			continue
+		}
+
+		c.log.Warn().
+			Str("container", e.Container).
+			Str("path", e.WatchFile).
+			Str("age", age.Round(time.Hour).String()).
+			Msg("watch directory file was never picked up")
+		result.NotPickedUp++
+	}
+
+	if len(updated) > 0 {
+		if err := c.history.Add(updated...); err != nil {
+			c.log.Warn().Err(err).Msg("failed to record watch directory pickups")
+		}
+	}
+
+	if result != (PickupResult{}) {
+		c.log.Info().
+			Int("pickedUp", result.PickedUp).
+			Int("waiting", result.Waiting).
+			Int("notPickedUp", result.NotPickedUp).
+			Int("cleanedUp", result.CleanedUp).
+			Msg("checked watch directory pickups")
+	}
+
+	return result
+}
@@ -0,0 +1,31 @@
+package archiver
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter keeps at least interval between the requests of every caller sharing it
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{interval: interval}
+}
+
+// Wait blocks until the caller's turn, which is interval after the previous caller's
+func (r *rateLimiter) Wait() {
+	r.mu.Lock()
+	now := time.Now()
+	turn := r.next
+	if turn.Before(now) {
+		turn = now
+	}
+	r.next = turn.Add(r.interval)
+	r.mu.Unlock()
+
+	time.Sleep(time.Until(turn))
+}
@@ -0,0 +1,73 @@
+package archiver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// repeatLogInterval is how often an error that keeps recurring is logged again at its own level
+const repeatLogInterval = time.Hour
+
+// repeats collapses errors that recur every cycle, such as an unreachable client, so they're logged once
+// and then at most once per repeatLogInterval with how often they were seen, instead of on every attempt
+type repeats struct {
+	mu   sync.Mutex
+	seen map[string]*repeat
+}
+
+type repeat struct {
+	msg        string
+	count      int
+	first      time.Time
+	lastLogged time.Time
+}
+
+func newRepeats() *repeats {
+	return &repeats{seen: make(map[string]*repeat)}
+}
+
+// event records an occurrence of err under key and returns the event to log it with. That is e for a new
+// error and once per repeatLogInterval after, noting how often it was seen, and a debug event otherwise.
+func (r *repeats) event(key string, err error, e *zerolog.Event, logger zerolog.Logger) *zerolog.Event {
+	r.mu.Lock()
+	now := time.Now()
+	rep, ok := r.seen[key]
+	if !ok || rep.msg != err.Error() {
+		rep = &repeat{msg: err.Error(), first: now}
+		r.seen[key] = rep
+	}
+	rep.count++
+	show := rep.count == 1 || now.Sub(rep.lastLogged) >= repeatLogInterval
+	if show {
+		rep.lastLogged = now
+	}
+	count, first := rep.count, rep.first
+	r.mu.Unlock()
+
+	if !show {
+		e.Discard()
+		return logger.Debug().Int("repeats", count)
+	}
+	if count > 1 {
+		e = e.Str("repeated", fmt.Sprintf("seen %d times since %s", count, first.Format("15:04")))
+	}
+	return e
+}
+
+// resolve forgets the error recorded under key, logging msg if it had been repeating and msg isn't empty
+func (r *repeats) resolve(key string, logger zerolog.Logger, msg string) {
+	r.mu.Lock()
+	rep, ok := r.seen[key]
+	delete(r.seen, key)
+	r.mu.Unlock()
+
+	if ok && rep.count > 1 && msg != "" {
+		logger.Info().
+			Int("occurrences", rep.count).
+			Time("since", rep.first).
+			Msg(msg)
+	}
+}
@@ -0,0 +1,150 @@
+package archiver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+)
+
+// Decision is the outcome of one of the checks made before fetching for a container
+type Decision struct {
+	Check  string `json:"check"`
+	Pass   bool   `json:"pass"`
+	Detail string `json:"detail"`
+}
+
+// Simulate evaluates the checks a fetch for the container would go through, in the same order, without
+// contacting archive.php or adding anything. A fetch only reaches PTP when every decision passes.
+func (c *Client) Simulate(name string) ([]Decision, error) {
+	container, ok := c.cfg.Containers[name]
+	if !ok {
+		return nil, fmt.Errorf("container %s not found", name)
+	}
+
+	var decisions []Decision
+	add := func(check string, pass bool, format string, args ...any) {
+		decisions = append(decisions, Decision{Check: check, Pass: pass, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if c.state != nil && c.state.IsPaused(name) {
+		add("paused", false, "fetching was paused with the pause command")
+	} else {
+		add("paused", true, "not paused")
+	}
+
+	if c.state != nil {
+		if until := c.state.BackoffUntil(name); time.Now().Before(until) {
+			add("backoff", false, "PTP had no torrents recently, backing off until %s", until.Local().Format(time.RFC3339))
+		} else {
+			add("backoff", true, "not backing off")
+		}
+	}
+
+	var torrentClient client.TorrentClient
+	if container.UsesWatchDir() {
+		add("client", true, "watch directory %s", strings.Join(container.WatchDirectories(), ", "))
+	} else {
+		c.mu.Lock()
+		err, down := c.unavailable[container.Client]
+		torrentClient = c.clients[container.Client]
+		c.mu.Unlock()
+		switch {
+		case container.Client == "":
+			add("client", false, "container must specify either watchDir or client")
+			return decisions, nil
+		case down:
+			add("client", false, "%s is unavailable: %v", container.Client, err)
+			return decisions, nil
+		case torrentClient == nil:
+			add("client", false, "%s is not configured", container.Client)
+			return decisions, nil
+		}
+		add("client", true, "%s is connected", container.Client)
+	}
+
+	// the checks share their counts, as in a fetch cycle
+	cycle := newFetchCycle()
+	switch {
+	case container.MaxStalled <= 0:
+		add("maxStalled", true, "not configured")
+	case torrentClient == nil || !countsStalled(torrentClient):
+		add("maxStalled", true, "not supported by this client")
+	default:
+		count, err := c.countStalled(cycle, container, torrentClient)
+		if err != nil {
+			add("maxStalled", false, "failed to count stalled torrents: %v", err)
+		} else {
+			add("maxStalled", count < container.MaxStalled, "%d stalled in category %q, limit %d", count, container.Category, container.MaxStalled)
+		}
+	}
+
+	if limit := c.cfg.ClientMaxStalled(container.Client); limit > 0 {
+		count, err := c.countStalledGlobal(cycle, container.Client)
+		if err != nil {
+			add("client maxStalled", false, "%v", err)
+		} else {
+			add("client maxStalled", count < limit, "%d stalled on %s, limit %d", count, container.Client, limit)
+		}
+	}
+
+	if c.cfg.MaxDownloading > 0 {
+		count, err := c.countDownloading(cycle)
+		if err != nil {
+			add("maxDownloading", false, "%v", err)
+		} else {
+			add("maxDownloading", count < c.cfg.MaxDownloading, "%d downloading across all clients, limit %d", count, c.cfg.MaxDownloading)
+		}
+	}
+
+	if c.cfg.MaxStalledGlobal > 0 {
+		count, err := c.countStalledGlobal(cycle, "")
+		if err != nil {
+			add("maxStalledGlobal", false, "%v", err)
+		} else {
+			add("maxStalledGlobal", count < c.cfg.MaxStalledGlobal, "%d stalled across all containers, limit %d", count, c.cfg.MaxStalledGlobal)
+		}
+	}
+
+	if torrentClient != nil {
+		c.simulateSize(container, torrentClient, add)
+	}
+
+	if rtorr, ok := torrentClient.(*client.RTorrentClient); torrentClient == nil || (ok && !rtorr.ReportsFreeSpace()) {
+		add("free space", true, "not checked for this client")
+	} else if free, err := torrentClient.GetFreeSpace(); err != nil {
+		add("free space", false, "failed to get free space: %v", err)
+	} else {
+		// the size of the next torrent is only known once PTP assigns it, allowing for the same 10% buffer
+		add("free space", free > 0, "%s free, fits a torrent of up to %s",
+			units.BytesSize(float64(free)), units.BytesSize(float64(free)/1.1))
+	}
+
+	return decisions, nil
+}
+
+// simulateSize compares the torrents in the container's category against its configured size. PTP only
+// assigns torrents that fit in the size sent with the fetch, so a full container gets none.
+func (c *Client) simulateSize(container config.Container, tc client.TorrentClient, add func(string, bool, string, ...any)) {
+	size, err := config.ParseSize(container.Size)
+	if err != nil {
+		add("container size", false, "invalid size %q: %v", container.Size, err)
+		return
+	}
+
+	torrents, err := tc.ListTorrents(container.Category)
+	if err != nil {
+		add("container size", false, "failed to list torrents: %v", err)
+		return
+	}
+
+	var used int64
+	for _, t := range torrents {
+		used += t.Size
+	}
+	add("container size", used < size, "%s of %s used by %d torrents",
+		units.BytesSize(float64(used)), units.BytesSize(float64(size)), len(torrents))
+}
@@ -0,0 +1,90 @@
+package archiver
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+)
+
+// statsdEmitter sends counters and timers to a statsd agent. Sends are fire and forget, so a missing
+// agent never slows down or fails a fetch. All methods are no-ops on a nil emitter.
+type statsdEmitter struct {
+	conn   net.Conn
+	prefix string
+	dog    bool
+	tags   []string
+}
+
+// newStatsdEmitter returns an emitter for the configured agent, or nil if sending metrics isn't enabled
+func newStatsdEmitter(cfg config.Statsd) (*statsdEmitter, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to statsd: %w", err)
+	}
+
+	return &statsdEmitter{
+		conn:   conn,
+		prefix: cfg.PrefixOrDefault(),
+		dog:    cfg.DogStatsD,
+		tags:   cfg.Tags,
+	}, nil
+}
+
+// count adds value to a counter. tags are key and value pairs.
+func (s *statsdEmitter) count(name string, value int64, tags ...string) {
+	s.send(name, fmt.Sprintf("%d|c", value), tags)
+}
+
+// timing records how long something took in milliseconds
+func (s *statsdEmitter) timing(name string, d time.Duration, tags ...string) {
+	s.send(name, fmt.Sprintf("%d|ms", d.Milliseconds()), tags)
+}
+
+// send writes one metric. Plain statsd has no tags, so their values are appended to the name instead,
+// e.g. ptparchiver.fetch.hetzner.added
+func (s *statsdEmitter) send(name, value string, tags []string) {
+	if s == nil {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(s.prefix + "." + name)
+	if !s.dog {
+		for i := 1; i < len(tags); i += 2 {
+			b.WriteString("." + statsdValue(tags[i]))
+		}
+	}
+	b.WriteString(":" + value)
+
+	if s.dog {
+		all := append([]string(nil), s.tags...)
+		for i := 1; i < len(tags); i += 2 {
+			all = append(all, tags[i-1]+":"+statsdValue(tags[i]))
+		}
+		if len(all) > 0 {
+			b.WriteString("|#" + strings.Join(all, ","))
+		}
+	}
+
+	// UDP, so errors only mean the agent isn't listening
+	_, _ = s.conn.Write([]byte(b.String()))
+}
+
+// statsdValue turns a tag value such as "skipped: no torrents available" into skipped_no_torrents_available,
+// which is safe both in metric names and DogStatsD tags
+func statsdValue(v string) string {
+	return strings.Join(strings.FieldsFunc(v, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	}), "_")
+}
@@ -0,0 +1,57 @@
+package archiver
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/zeebo/bencode"
+)
+
+// torrentMeta holds the details of a .torrent file needed by the archiver
+type torrentMeta struct {
+	Name     string
+	Size     int64
+	InfoHash string
+}
+
+// parseTorrent extracts the name, total size and infohash from a .torrent file
+func parseTorrent(data []byte) (torrentMeta, error) {
+	var raw struct {
+		Info bencode.RawMessage `bencode:"info"`
+	}
+	if err := bencode.DecodeBytes(data, &raw); err != nil {
+		return torrentMeta{}, fmt.Errorf("failed to decode torrent: %w", err)
+	}
+	if len(raw.Info) == 0 {
+		return torrentMeta{}, fmt.Errorf("torrent has no info dictionary")
+	}
+
+	var info struct {
+		Name   string `bencode:"name"`
+		Length int64  `bencode:"length"`
+		Files  []struct {
+			Length int64    `bencode:"length"`
+			Path   []string `bencode:"path"`
+		} `bencode:"files"`
+	}
+	if err := bencode.DecodeBytes(raw.Info, &info); err != nil {
+		return torrentMeta{}, fmt.Errorf("failed to decode torrent info: %w", err)
+	}
+
+	// the infohash is the sha1 of the bencoded info dictionary exactly as it appears in the file
+	sum := sha1.Sum(raw.Info)
+
+	meta := torrentMeta{
+		Name:     info.Name,
+		Size:     info.Length,
+		InfoHash: hex.EncodeToString(sum[:]),
+	}
+	if meta.Size == 0 {
+		for _, file := range info.Files {
+			meta.Size += file.Length
+		}
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,26 @@
+package archiver
+
+import (
+	"fmt"
+
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+)
+
+// remainingCapacity returns how much of the container's size isn't taken by the torrents already in its
+// category on the client, including ones added outside of ptparchiver
+func remainingCapacity(container config.Container, tc client.TorrentClient) (int64, error) {
+	// validated in NewClient
+	size, _ := config.ParseSize(container.Size)
+
+	torrents, err := tc.ListTorrents(container.Category)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list torrents in category %s: %w", container.Category, err)
+	}
+
+	var used int64
+	for _, t := range torrents {
+		used += t.Size
+	}
+	return size - used, nil
+}
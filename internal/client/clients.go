@@ -1,14 +1,107 @@
 // Package client provides interfaces and implementations for different torrent clients
 package client
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+)
+
 // TorrentClient defines the interface that all torrent clients must implement
 type TorrentClient interface {
-	// AddTorrent adds a new torrent to the client
+	// AddTorrent adds a new torrent to the client, returning ErrAlreadyExists if the client
+	// already has it. The infohash is passed in opts["hash"] when known.
 	AddTorrent(torrentData []byte, name string, opts map[string]string) error
 
 	// GetFreeSpace returns the available disk space in bytes
 	GetFreeSpace() (uint64, error)
 
-	// CountStalledTorrents returns the number of stalled downloads in the given category
-	CountStalledTorrents(category string) (int, error)
+	// CountStalledTorrents returns the number of stalled downloads in the given category. Torrents
+	// in any of the given states are counted, or the client's default notion of stalled if states is empty.
+	// Torrents waiting for their metadata for longer than metadataAfter are counted too, 0 disables that.
+	CountStalledTorrents(category string, states []string, metadataAfter time.Duration) (int, error)
+
+	// Version returns the version reported by the client
+	Version() (string, error)
+
+	// CategoryExists reports whether the category/label is known to the client
+	CategoryExists(category string) (bool, error)
+
+	// ListTorrents returns the torrents in the given category, or all torrents if category is empty
+	ListTorrents(category string) ([]Torrent, error)
+
+	// ExportTorrent returns the .torrent file for the given infohash
+	ExportTorrent(hash string) ([]byte, error)
+
+	// ResumeTorrent starts the paused or stopped torrent with the given infohash
+	ResumeTorrent(hash string) error
+
+	// SetCategory moves the torrent with the given infohash to the category/label, creating it if needed
+	SetCategory(hash, category string) error
+}
+
+// ErrAlreadyExists is returned by AddTorrent when the client already has the torrent
+var ErrAlreadyExists = errors.New("torrent already exists in client")
+
+// Client type names as used in the config file
+const (
+	TypeQBittorrent = "qbittorrent"
+	TypeRTorrent    = "rtorrent"
+	TypeDeluge      = "deluge"
+)
+
+// Type returns the kind of the named client, or an empty string if it isn't configured
+func Type(cfg *config.Config, name string) string {
+	if _, ok := cfg.QBitClients[name]; ok {
+		return TypeQBittorrent
+	}
+	if _, ok := cfg.RTorrClients[name]; ok {
+		return TypeRTorrent
+	}
+	if _, ok := cfg.DelugeClients[name]; ok {
+		return TypeDeluge
+	}
+	return ""
+}
+
+// ReportsFreeSpace reports whether the named client can tell its free space, which rTorrent only can
+// with freeSpaceMode local
+func ReportsFreeSpace(cfg *config.Config, name string) bool {
+	if rtorr, ok := cfg.RTorrClients[name]; ok {
+		return rtorr.FreeSpaceMode == config.FreeSpaceLocal
+	}
+	return true
+}
+
+// fakeClients replaces every configured client when set, see UseFakeClients
+var fakeClients func(name string) TorrentClient
+
+// UseFakeClients makes New return the client fn builds for every configured client instead of
+// connecting to it, e.g. to run a config against in-memory clients
+func UseFakeClients(fn func(name string) TorrentClient) {
+	fakeClients = fn
+}
+
+// New connects to the named torrent client from the config
+func New(cfg *config.Config, name string) (TorrentClient, error) {
+	logger := log.With().Str("client", name).Logger()
+
+	if fakeClients != nil && Type(cfg, name) != "" {
+		logger.Debug().Msg("using fake torrent client")
+		return fakeClients(name), nil
+	}
+
+	switch Type(cfg, name) {
+	case TypeQBittorrent:
+		return NewQBitClient(name, cfg.QBitClients[name], logger)
+	case TypeRTorrent:
+		return NewRTorrentClient(cfg.RTorrClients[name], cfg.IPFamily, logger)
+	case TypeDeluge:
+		return NewDelugeClient(cfg.DelugeClients[name], cfg.IPFamily, logger)
+	}
+
+	return nil, fmt.Errorf("client %s not found", name)
 }
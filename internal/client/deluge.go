@@ -5,10 +5,15 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/autobrr/go-deluge"
+	"github.com/rs/zerolog"
 	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/httpclient"
 )
 
 type DelugeClient struct {
@@ -18,42 +23,78 @@ type DelugeClient struct {
 		GetFreeSpace(ctx context.Context, path string) (int64, error)
 		TorrentsStatus(ctx context.Context, state deluge.TorrentState, ids []string) (map[string]*deluge.TorrentStatus, error)
 		LabelPlugin(ctx context.Context) (*deluge.LabelPlugin, error)
+		DaemonVersion(ctx context.Context) (string, error)
+		ResumeTorrents(ctx context.Context, ids ...string) error
 	}
+	log zerolog.Logger
+
+	// mu serializes calls, go-deluge sends every RPC over one connection and matches responses by a
+	// serial number it doesn't guard
+	mu sync.Mutex
 }
 
 // NewDelugeClient creates a new Deluge client instance
-func NewDelugeClient(cfg config.DelugeConfig) (*DelugeClient, error) {
+func NewDelugeClient(cfg config.DelugeConfig, ipFamily config.IPFamily, logger zerolog.Logger) (*DelugeClient, error) {
+	// go-deluge dials by itself, so resolve the host up front to apply the IP family
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.ConnectTimeout())
+	host, err := httpclient.LookupHost(ctx, cfg.Host, ipFamily)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve deluge host: %w", err)
+	}
+
 	settings := deluge.Settings{
-		Hostname:         cfg.Host,
+		Hostname:         host,
 		Port:             uint(cfg.Port),
 		Login:            cfg.Username,
 		Password:         cfg.Password,
-		ReadWriteTimeout: time.Second * 30,
+		ReadWriteTimeout: cfg.Timeouts.RequestTimeout(),
 	}
 
 	// Try to connect using v2 first
 	v2client := deluge.NewV2(settings)
-	err := v2client.Connect(context.Background())
+	err = delugeConnect(v2client, cfg.Timeouts)
 	if err == nil {
+		logger.Debug().Str("host", cfg.Host).Msg("connected to deluge 2")
 		return &DelugeClient{
 			client: v2client,
+			log:    logger,
 		}, nil
 	}
 
 	// Fall back to v1 if v2 fails
 	v1client := deluge.NewV1(settings)
-	err = v1client.Connect(context.Background())
+	err = delugeConnect(v1client, cfg.Timeouts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to deluge: %w", err)
 	}
 
+	logger.Debug().Str("host", cfg.Host).Msg("connected to deluge 1")
 	return &DelugeClient{
 		client: v1client,
+		log:    logger,
 	}, nil
 }
 
+// delugeConnect dials the daemon within the connect timeout. The TLS handshake and login are
+// bounded by the read/write timeout since go-deluge only uses the context for dialing.
+func delugeConnect(c interface{ Connect(context.Context) error }, timeouts config.Timeouts) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.ConnectTimeout())
+	defer cancel()
+
+	return c.Connect(ctx)
+}
+
 // AddTorrent implements the TorrentClient interface
 func (c *DelugeClient) AddTorrent(torrentData []byte, name string, opts map[string]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.log.Debug().
+		Str("name", name).
+		Interface("options", opts).
+		Msg("adding torrent to deluge")
+
 	// Convert torrent data to base64
 	fileContentBase64 := base64.StdEncoding.EncodeToString(torrentData)
 
@@ -71,9 +112,40 @@ func (c *DelugeClient) AddTorrent(torrentData []byte, name string, opts map[stri
 		options.DownloadLocation = &downloadDir
 	}
 
+	// Seed mode skips the hash check on Deluge 2
+	if skip, ok := opts["skip_checking"]; ok && skip == "true" {
+		seedMode := true
+		options.V2.SeedMode = &seedMode
+	}
+
+	if prio, ok := opts["first_last_piece_prio"]; ok && prio == "true" {
+		firstLast := true
+		options.PrioritizeFirstLastPieces = &firstLast
+	}
+
+	// sequential download only exists on Deluge 2
+	if sequential, ok := opts["sequential"]; ok && sequential == "true" {
+		seq := true
+		options.V2.SequentialDownload = &seq
+	}
+
+	// Deluge takes speed limits in KiB/s
+	if limit, err := strconv.ParseInt(opts["download_limit"], 10, 64); err == nil && limit > 0 {
+		kib := int(max(limit/1024, 1))
+		options.MaxDownloadSpeed = &kib
+	}
+	if limit, err := strconv.ParseInt(opts["upload_limit"], 10, 64); err == nil && limit > 0 {
+		kib := int(max(limit/1024, 1))
+		options.MaxUploadSpeed = &kib
+	}
+
 	// Add the torrent
 	hash, err := c.client.AddTorrentFile(context.Background(), name, fileContentBase64, &options)
 	if err != nil {
+		var rpcErr deluge.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.ExceptionType == "AddTorrentError" && strings.Contains(rpcErr.ExceptionMessage, "already") {
+			return ErrAlreadyExists
+		}
 		return fmt.Errorf("failed to add torrent: %w", err)
 	}
 
@@ -120,6 +192,9 @@ func delugeSetOrCreateTorrentLabel(ctx context.Context, plugin *deluge.LabelPlug
 
 // GetFreeSpace implements the TorrentClient interface
 func (c *DelugeClient) GetFreeSpace() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	// Get free space in the default download location
 	freeSpace, err := c.client.GetFreeSpace(context.Background(), "")
 	if err != nil {
@@ -129,20 +204,186 @@ func (c *DelugeClient) GetFreeSpace() (uint64, error) {
 	return uint64(freeSpace), nil
 }
 
-// CountStalledTorrents implements the TorrentClient interface
-func (c *DelugeClient) CountStalledTorrents(category string) (int, error) {
-	// Get all downloading torrents
-	torrents, err := c.client.TorrentsStatus(context.Background(), deluge.StateDownloading, nil)
+// CountStalledTorrents implements the TorrentClient interface. Without states, downloading torrents that
+// aren't receiving any data are counted.
+func (c *DelugeClient) CountStalledTorrents(category string, states []string, metadataAfter time.Duration) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	torrents, err := c.listTorrents(category)
+	if err != nil {
+		return 0, err
+	}
+	return countDelugeStalled(torrents, states, metadataAfter), nil
+}
+
+// countDelugeStalled counts the torrents in states, or the stalled ones without states, along with those
+// waiting for their metadata for longer than metadataAfter
+func countDelugeStalled(torrents []Torrent, states []string, metadataAfter time.Duration) int {
+	if len(states) == 0 {
+		states = []string{string(StateStalled)}
+	}
+	return countStates(torrents, states, metadataAfter)
+}
+
+// Version implements the TorrentClient interface
+func (c *DelugeClient) Version() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	version, err := c.client.DaemonVersion(context.Background())
+	if err != nil {
+		return "", fmt.Errorf("failed to get daemon version: %w", err)
+	}
+
+	return version, nil
+}
+
+// CategoryExists implements the TorrentClient interface
+func (c *DelugeClient) CategoryExists(category string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	labelPlugin, err := c.client.LabelPlugin(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("failed to get label plugin: %w", err)
+	}
+
+	// without the label plugin labels are silently skipped when adding torrents
+	if labelPlugin == nil {
+		return false, nil
+	}
+
+	labels, err := labelPlugin.GetLabels(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("failed to get labels: %w", err)
+	}
+
+	for _, label := range labels {
+		if label == category {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ListTorrents implements the TorrentClient interface
+func (c *DelugeClient) ListTorrents(category string) ([]Torrent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.listTorrents(category)
+}
+
+func (c *DelugeClient) listTorrents(category string) ([]Torrent, error) {
+	statuses, err := c.client.TorrentsStatus(context.Background(), deluge.StateUnspecified, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get torrents: %w", err)
+	}
+
+	labels := map[string]string{}
+	labelPlugin, err := c.client.LabelPlugin(context.Background())
 	if err != nil {
-		return 0, fmt.Errorf("failed to get session state: %w", err)
+		return nil, fmt.Errorf("failed to get label plugin: %w", err)
 	}
+	if labelPlugin != nil {
+		if labels, err = labelPlugin.GetTorrentsLabels(deluge.StateUnspecified, nil); err != nil {
+			return nil, fmt.Errorf("failed to get labels: %w", err)
+		}
+	}
+
+	return delugeTorrents(statuses, labels, category), nil
+}
+
+// delugeTorrents converts the statuses of the torrents labeled category, or of every torrent if category is empty
+func delugeTorrents(statuses map[string]*deluge.TorrentStatus, labels map[string]string, category string) []Torrent {
+	torrents := make([]Torrent, 0, len(statuses))
+	for hash, status := range statuses {
+		label := labels[hash]
+		if category != "" && label != category {
+			continue
+		}
 
-	stalledCount := 0
-	for _, torrent := range torrents {
-		if torrent.State == "Downloading" && torrent.DownloadPayloadRate == 0 {
-			stalledCount++
+		// deluge doesn't report the uploaded bytes, its ratio is the upload over the completed bytes and -1
+		// until something completes
+		var uploaded int64
+		if status.Ratio > 0 {
+			uploaded = int64(float64(status.Ratio) * float64(status.TotalDone))
 		}
+
+		torrents = append(torrents, Torrent{
+			Hash:     hash,
+			Name:     status.Name,
+			Category: label,
+			SavePath: status.SavePath,
+			Size:     status.TotalSize,
+			Progress: float64(status.Progress) / 100,
+			State:    delugeState(status),
+			Uploaded: uploaded,
+			Ratio:    float64(status.Ratio),
+			AddedOn:  time.Unix(int64(status.TimeAdded), 0),
+			// the size is only known once the metadata is
+			AwaitingMetadata: status.TotalSize == 0 && status.Progress < 100,
+		})
+	}
+
+	return torrents
+}
+
+// ExportTorrent implements the TorrentClient interface. Deluge has no RPC method for this.
+func (c *DelugeClient) ExportTorrent(hash string) ([]byte, error) {
+	return nil, fmt.Errorf("exporting torrents is not supported by deluge")
+}
+
+// SetCategory implements the TorrentClient interface
+func (c *DelugeClient) SetCategory(hash, category string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	labelPlugin, err := c.client.LabelPlugin(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get label plugin: %w", err)
 	}
+	if labelPlugin == nil {
+		return fmt.Errorf("the label plugin isn't enabled")
+	}
+	return delugeSetOrCreateTorrentLabel(context.Background(), labelPlugin, "deluge", hash, category)
+}
+
+// ResumeTorrent implements the TorrentClient interface
+func (c *DelugeClient) ResumeTorrent(hash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.client.ResumeTorrents(context.Background(), hash); err != nil {
+		return fmt.Errorf("failed to resume torrent: %w", err)
+	}
+	return nil
+}
 
-	return stalledCount, nil
+func delugeState(status *deluge.TorrentStatus) TorrentState {
+	switch deluge.TorrentState(status.State) {
+	case deluge.StateSeeding:
+		return StateSeeding
+	case deluge.StateDownloading:
+		if status.DownloadPayloadRate == 0 {
+			return StateStalled
+		}
+		return StateDownloading
+	case deluge.StatePaused:
+		return StatePaused
+	case deluge.StateQueued:
+		return StateQueued
+	case deluge.StateChecking, deluge.StateAllocating:
+		return StateChecking
+	case deluge.StateMoving:
+		return StateMoving
+	case deluge.StateError:
+		if strings.Contains(status.TrackerStatus, "No such file") {
+			return StateMissing
+		}
+		return StateError
+	}
+	return StateUnknown
 }
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autobrr/go-deluge"
+)
+
+func TestDelugeCountStalled(t *testing.T) {
+	added := float32(time.Now().Add(-2 * time.Hour).Unix())
+	statuses := map[string]*deluge.TorrentStatus{
+		"stalled":  {State: string(deluge.StateDownloading), TotalSize: 100, TimeAdded: added},
+		"active":   {State: string(deluge.StateDownloading), TotalSize: 100, DownloadPayloadRate: 500, TimeAdded: added},
+		"metadata": {State: string(deluge.StateQueued), TimeAdded: added},
+		"seeding":  {State: string(deluge.StateSeeding), TotalSize: 100, Progress: 100, TimeAdded: added},
+		"other":    {State: string(deluge.StateDownloading), TotalSize: 100, TimeAdded: added},
+	}
+	labels := map[string]string{
+		"stalled":  "archive",
+		"active":   "archive",
+		"metadata": "archive",
+		"seeding":  "archive",
+		"other":    "movies",
+	}
+
+	tests := []struct {
+		name          string
+		category      string
+		states        []string
+		metadataAfter time.Duration
+		want          int
+	}{
+		{name: "stalled by default", category: "archive", want: 1},
+		{name: "only the category", category: "movies", want: 1},
+		{name: "every label", want: 2},
+		{name: "stuck on metadata", category: "archive", metadataAfter: time.Hour, want: 2},
+		{name: "not stuck on metadata yet", category: "archive", metadataAfter: 3 * time.Hour, want: 1},
+		{name: "states", category: "archive", states: []string{"downloading", "stalled"}, want: 2},
+		{name: "states are case insensitive", category: "archive", states: []string{"Queued"}, want: 1},
+		{name: "states with metadata", category: "archive", states: []string{"seeding"}, metadataAfter: time.Hour, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			torrents := delugeTorrents(statuses, labels, tt.category)
+			if got := countDelugeStalled(torrents, tt.states, tt.metadataAfter); got != tt.want {
+				t.Errorf("countDelugeStalled() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+)
+
+// aggregateFreeSpace returns the most free space on any of the paths, which is what a single torrent has
+// to fit into, or with sum the free space of all of them, counting every filesystem once
+func aggregateFreeSpace(paths []string, aggregate config.FreeSpaceAggregate) (uint64, error) {
+	var total uint64
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		free, err := localFreeSpace(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get free space of %s: %w", path, err)
+		}
+
+		if aggregate != config.FreeSpaceSum {
+			total = max(total, free)
+			continue
+		}
+
+		device, err := localDevice(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get filesystem of %s: %w", path, err)
+		}
+		if !seen[device] {
+			seen[device] = true
+			total += free
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package client
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// localFreeSpace returns the bytes available to unprivileged users on the filesystem holding path
+func localFreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// localDevice identifies the filesystem holding path, so paths on the same one are only counted once
+func localDevice(path string) (string, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(stat.Dev), nil
+}
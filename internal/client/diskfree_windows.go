@@ -0,0 +1,33 @@
+//go:build windows
+
+package client
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// localFreeSpace returns the bytes available to the current user on the volume holding path
+func localFreeSpace(path string) (uint64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &available, nil, nil); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
+
+// localDevice identifies the volume holding path, so paths on the same one are only counted once
+func localDevice(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(filepath.VolumeName(abs)), nil
+}
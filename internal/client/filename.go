@@ -0,0 +1,55 @@
+package client
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFileNameBytes leaves room for the .torrent and .json extensions within the 255 byte limit of most filesystems
+const maxFileNameBytes = 200
+
+// reservedNames can't be used as file names on Windows, with or without an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SafeFileName turns a torrent name into a file name without an extension that is valid on Linux, macOS and
+// Windows, including shares mounted from them. Path separators, characters Windows rejects and control
+// characters are replaced, and overlong names are shortened. Names with nothing usable left or that
+// Windows reserves fall back to the first non-empty fallback, such as the torrent ID.
+func SafeFileName(name string, fallbacks ...string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	if len(safe) > maxFileNameBytes {
+		cut := maxFileNameBytes
+		for cut > 0 && !utf8.RuneStart(safe[cut]) {
+			cut--
+		}
+		safe = safe[:cut]
+	}
+	// leading dots would hide the file, and Windows drops trailing dots and spaces so a name ending in
+	// them wouldn't match what was written
+	safe = strings.Trim(strings.TrimSpace(safe), ". ")
+
+	base, _, _ := strings.Cut(safe, ".")
+	if strings.Trim(safe, "._") == "" || reservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+		for _, fallback := range fallbacks {
+			if fallback != "" {
+				return SafeFileName(fallback)
+			}
+		}
+		return "torrent"
+	}
+	return safe
+}
@@ -3,45 +3,132 @@ package client
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	qbittorrent "github.com/autobrr/go-qbittorrent"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/httpclient"
 )
 
 // QBitClient implements TorrentClient interface for qBittorrent
 type QBitClient struct {
 	client *qbittorrent.Client
+	log    zerolog.Logger
+	// name is the configured name of the client and relayed whether its requests go through a TLS relay
+	name    string
+	relayed bool
 }
 
-// NewQBitClient creates a new qBittorrent client
-func NewQBitClient(url, username, password, basicUser, basicPass string) (*QBitClient, error) {
+var (
+	// qbitRelays holds the TLS relay of each qBittorrent client by name, so reconnecting re-uses it
+	qbitRelays   = make(map[string]*httpclient.Relay)
+	qbitRelaysMu sync.Mutex
+)
+
+// qbitRelay returns the TLS relay of the named client, starting it if it isn't running.
+// go-qbittorrent builds its own transport, so requests are relayed through one that presents the certificate.
+func qbitRelay(name string, cfg config.QBitConfig) (*httpclient.Relay, error) {
+	qbitRelaysMu.Lock()
+	defer qbitRelaysMu.Unlock()
+
+	if relay, ok := qbitRelays[name]; ok {
+		return relay, nil
+	}
+
+	tlsConfig, err := cfg.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := httpclient.New(httpclient.Options{Timeouts: cfg.Timeouts, TLS: tlsConfig})
+	if err != nil {
+		return nil, err
+	}
+
+	relay, err := httpclient.NewRelay(cfg.URL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start tls relay: %w", err)
+	}
+	qbitRelays[name] = relay
+	return relay, nil
+}
+
+// closeQBitRelay stops the TLS relay of the named client, if it has one
+func closeQBitRelay(name string) {
+	qbitRelaysMu.Lock()
+	defer qbitRelaysMu.Unlock()
+
+	if relay, ok := qbitRelays[name]; ok {
+		relay.Close()
+		delete(qbitRelays, name)
+	}
+}
+
+// NewQBitClient creates a new qBittorrent client for the client configured under name
+func NewQBitClient(name string, cfg config.QBitConfig, logger zerolog.Logger) (*QBitClient, error) {
+	host := cfg.URL
+	if cfg.TLS.Enabled() {
+		relay, err := qbitRelay(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		host = relay.URL
+	}
+
 	qbConfig := qbittorrent.Config{
-		Host:      url,
-		Username:  username,
-		Password:  password,
-		BasicUser: basicUser,
-		BasicPass: basicPass,
+		Host:      host,
+		Username:  cfg.Username,
+		Password:  cfg.Password,
+		BasicUser: cfg.BasicUser,
+		BasicPass: cfg.BasicPass,
+		Timeout:   int(cfg.Timeouts.RequestTimeout().Seconds()),
 	}
 
 	qb := qbittorrent.NewClient(qbConfig)
 	if err := qb.Login(); err != nil {
-		log.Error().Err(err).Str("url", url).Msg("failed to login to qbittorrent")
+		closeQBitRelay(name)
+		logger.Error().Err(err).Str("url", cfg.URL).Msg("failed to login to qbittorrent")
 		return nil, fmt.Errorf("failed to login to qbittorrent: %w", err)
 	}
 
-	log.Debug().Str("url", url).Msg("connected to qbittorrent")
+	logger.Debug().Str("url", cfg.URL).Msg("connected to qbittorrent")
 	return &QBitClient{
-		client: qb,
+		client:  qb,
+		log:     logger,
+		name:    name,
+		relayed: cfg.TLS.Enabled(),
 	}, nil
 }
 
+// Close stops the TLS relay of the client, if it has one. The client can't be used afterwards.
+func (c *QBitClient) Close() error {
+	if c.relayed {
+		closeQBitRelay(c.name)
+	}
+	return nil
+}
+
 // AddTorrent adds a torrent to qBittorrent
 func (c *QBitClient) AddTorrent(torrentData []byte, name string, opts map[string]string) error {
-	log.Debug().
+	c.log.Debug().
 		Str("name", name).
 		Interface("options", opts).
 		Msg("adding torrent to qbittorrent")
 
+	// qBittorrent 4 answers a duplicate add with "Fails." and a 200, which the library treats as success
+	if hash := opts["hash"]; hash != "" {
+		existing, err := c.client.GetTorrents(qbittorrent.TorrentFilterOptions{Hashes: []string{hash}})
+		if err != nil {
+			return fmt.Errorf("failed to check for existing torrent: %w", err)
+		}
+		if len(existing) > 0 {
+			return ErrAlreadyExists
+		}
+	}
+
 	// Create qBittorrent specific options
 	qbtOpts := &qbittorrent.TorrentAddOptions{}
 
@@ -55,48 +142,249 @@ func (c *QBitClient) AddTorrent(torrentData []byte, name string, opts map[string
 		qbtOpts.Category = category
 	}
 
+	// Set tags if provided, comma separated
+	if tags, ok := opts["tags"]; ok {
+		qbtOpts.Tags = tags
+	}
+
 	// Set download path if provided
 	if downloadDir, ok := opts["download_dir"]; ok {
 		qbtOpts.SavePath = downloadDir
 		qbtOpts.AutoTMM = false
 	}
 
+	// Skip the hash check when the data is already in place
+	if skip, ok := opts["skip_checking"]; ok && skip == "true" {
+		qbtOpts.SkipHashCheck = true
+	}
+
+	if sequential, ok := opts["sequential"]; ok && sequential == "true" {
+		qbtOpts.SequentialDownload = true
+	}
+	if prio, ok := opts["first_last_piece_prio"]; ok && prio == "true" {
+		qbtOpts.FirstLastPiecePrio = true
+	}
+
+	// Speed limits are in bytes per second
+	if limit, err := strconv.ParseInt(opts["download_limit"], 10, 64); err == nil && limit > 0 {
+		qbtOpts.LimitDownloadSpeed = limit
+	}
+	if limit, err := strconv.ParseInt(opts["upload_limit"], 10, 64); err == nil && limit > 0 {
+		qbtOpts.LimitUploadSpeed = limit
+	}
+
 	// Prepare the options for the API call
 	options := qbtOpts.Prepare()
 
-	return c.client.AddTorrentFromMemory(torrentData, options)
+	if err := c.client.AddTorrentFromMemory(torrentData, options); err != nil {
+		// qBittorrent 5 answers a duplicate add with 409 Conflict
+		if strings.Contains(err.Error(), "status: 409") {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
 }
 
 // GetFreeSpace returns available disk space in bytes
 func (c *QBitClient) GetFreeSpace() (uint64, error) {
 	space, err := c.client.GetFreeSpaceOnDisk()
 	if err != nil {
-		log.Error().Err(err).Msg("failed to get free space")
+		c.log.Error().Err(err).Msg("failed to get free space")
 	}
 	return space, err
 }
 
-// CountStalledTorrents returns the number of stalled downloads in the given category
-func (c *QBitClient) CountStalledTorrents(category string) (int, error) {
+// CountStalledTorrents returns the number of stalled downloads in the given category. States may be
+// either raw qBittorrent states or client independent ones, defaulting to stalledDL.
+func (c *QBitClient) CountStalledTorrents(category string, states []string, metadataAfter time.Duration) (int, error) {
+	return c.CountStalledTorrentsWithTag(category, "", states, metadataAfter)
+}
+
+// CountStalledTorrentsWithTag counts like CountStalledTorrents, but only torrents with the tag. An empty
+// category counts tagged torrents in every category.
+func (c *QBitClient) CountStalledTorrentsWithTag(category, tag string, states []string, metadataAfter time.Duration) (int, error) {
 	torrents, err := c.client.GetTorrents(qbittorrent.TorrentFilterOptions{
 		Category: category,
+		Tag:      tag,
 	})
 	if err != nil {
-		log.Error().Err(err).Str("category", category).Msg("failed to get torrents")
+		c.log.Error().Err(err).Str("category", category).Str("tag", tag).Msg("failed to get torrents")
 		return 0, fmt.Errorf("failed to get torrents: %w", err)
 	}
 
 	stalledCount := 0
 	for _, t := range torrents {
-		if t.State == qbittorrent.TorrentStateStalledDl {
+		metadata := Torrent{AwaitingMetadata: qbitAwaitingMetadata(t.State), AddedOn: time.Unix(t.AddedOn, 0)}
+		switch {
+		case metadata.StuckOnMetadata(metadataAfter):
+			stalledCount++
+		case len(states) == 0:
+			if t.State == qbittorrent.TorrentStateStalledDl {
+				stalledCount++
+			}
+		case matchesState(states, string(t.State), string(qbitState(t.State))):
 			stalledCount++
 		}
 	}
 
-	log.Debug().
+	c.log.Debug().
 		Str("category", category).
+		Str("tag", tag).
+		Strs("states", states).
 		Int("stalledCount", stalledCount).
 		Msg("counted stalled torrents")
 
 	return stalledCount, nil
 }
+
+// Version returns the qBittorrent application and Web API versions
+func (c *QBitClient) Version() (string, error) {
+	appVersion, err := c.client.GetAppVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to get app version: %w", err)
+	}
+
+	apiVersion, err := c.client.GetWebAPIVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to get web api version: %w", err)
+	}
+
+	return fmt.Sprintf("%s (web api %s)", appVersion, apiVersion), nil
+}
+
+// CategoryExists reports whether the category has been created in qBittorrent
+func (c *QBitClient) CategoryExists(category string) (bool, error) {
+	categories, err := c.client.GetCategories()
+	if err != nil {
+		return false, fmt.Errorf("failed to get categories: %w", err)
+	}
+
+	_, ok := categories[category]
+	return ok, nil
+}
+
+// ListTorrents returns the torrents in the given category
+func (c *QBitClient) ListTorrents(category string) ([]Torrent, error) {
+	torrents, err := c.client.GetTorrents(qbittorrent.TorrentFilterOptions{
+		Category: category,
+	})
+	if err != nil {
+		c.log.Error().Err(err).Str("category", category).Msg("failed to get torrents")
+		return nil, fmt.Errorf("failed to get torrents: %w", err)
+	}
+
+	result := make([]Torrent, 0, len(torrents))
+	for _, t := range torrents {
+		result = append(result, Torrent{
+			Hash:     t.Hash,
+			Name:     t.Name,
+			Category: t.Category,
+			SavePath: t.SavePath,
+			Size:     t.Size,
+			Progress: t.Progress,
+			State:    qbitState(t.State),
+			Uploaded: t.Uploaded,
+			Ratio:    t.Ratio,
+			AddedOn:  time.Unix(t.AddedOn, 0),
+			Tags:     qbitTags(t.Tags),
+
+			AwaitingMetadata: qbitAwaitingMetadata(t.State),
+		})
+	}
+
+	return result, nil
+}
+
+// qbitTags splits the comma separated tags qBittorrent reports
+func qbitTags(tags string) []string {
+	var result []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// SetCategory moves the torrent with the given infohash to the category, creating it first if needed
+// since qBittorrent rejects unknown categories. Torrents in automatic management mode move their data
+// to the category's save path.
+func (c *QBitClient) SetCategory(hash, category string) error {
+	if category != "" {
+		exists, err := c.CategoryExists(category)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := c.client.CreateCategory(category, ""); err != nil {
+				return fmt.Errorf("failed to create category: %w", err)
+			}
+		}
+	}
+
+	if err := c.client.SetCategory([]string{hash}, category); err != nil {
+		return fmt.Errorf("failed to set category: %w", err)
+	}
+	return nil
+}
+
+// ReplaceTag swaps the tag from for to on the torrent with the given infohash
+func (c *QBitClient) ReplaceTag(hash, from, to string) error {
+	if err := c.client.AddTags([]string{hash}, to); err != nil {
+		return fmt.Errorf("failed to add tag: %w", err)
+	}
+	if err := c.client.RemoveTags([]string{hash}, from); err != nil {
+		return fmt.Errorf("failed to remove tag: %w", err)
+	}
+	return nil
+}
+
+// ResumeTorrent starts the torrent with the given infohash
+func (c *QBitClient) ResumeTorrent(hash string) error {
+	if err := c.client.Resume([]string{hash}); err != nil {
+		return fmt.Errorf("failed to resume torrent: %w", err)
+	}
+	return nil
+}
+
+func qbitState(state qbittorrent.TorrentState) TorrentState {
+	switch state {
+	case qbittorrent.TorrentStateUploading, qbittorrent.TorrentStateStalledUp, qbittorrent.TorrentStateForcedUp:
+		return StateSeeding
+	case qbittorrent.TorrentStateDownloading, qbittorrent.TorrentStateForcedDl,
+		qbittorrent.TorrentStateMetaDl, qbittorrent.TorrentStateAllocating:
+		return StateDownloading
+	case qbittorrent.TorrentStateStalledDl:
+		return StateStalled
+	case qbittorrent.TorrentStatePausedUp, qbittorrent.TorrentStateStoppedUp,
+		qbittorrent.TorrentStatePausedDl, qbittorrent.TorrentStateStoppedDl:
+		return StatePaused
+	case qbittorrent.TorrentStateQueuedUp, qbittorrent.TorrentStateQueuedDl:
+		return StateQueued
+	case qbittorrent.TorrentStateCheckingUp, qbittorrent.TorrentStateCheckingDl,
+		qbittorrent.TorrentStateCheckingResumeData:
+		return StateChecking
+	case qbittorrent.TorrentStateMoving:
+		return StateMoving
+	case qbittorrent.TorrentStateError:
+		return StateError
+	case qbittorrent.TorrentStateMissingFiles:
+		return StateMissing
+	}
+	return StateUnknown
+}
+
+// qbitAwaitingMetadata reports whether qBittorrent is still fetching the torrent's metadata
+func qbitAwaitingMetadata(state qbittorrent.TorrentState) bool {
+	return state == qbittorrent.TorrentStateMetaDl || state == "forcedMetaDL"
+}
+
+// ExportTorrent returns the .torrent file for the given infohash
+func (c *QBitClient) ExportTorrent(hash string) ([]byte, error) {
+	data, err := c.client.ExportTorrent(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export torrent: %w", err)
+	}
+	return data, nil
+}
@@ -3,51 +3,106 @@ package client
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	rtorrent "github.com/autobrr/go-rtorrent"
-	"github.com/rs/zerolog/log"
+	"github.com/autobrr/go-rtorrent/xmlrpc"
+	"github.com/rs/zerolog"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+	"github.com/s0up4200/ptparchiver-go/internal/httpclient"
 )
 
 // RTorrentClient implements TorrentClient interface for rTorrent
 type RTorrentClient struct {
 	client *rtorrent.Client
+	rpc    *xmlrpc.Client
+	log    zerolog.Logger
+	// freeSpacePaths are checked on this host for the free space, rTorrent can't report it itself
+	freeSpacePaths     []string
+	freeSpaceAggregate config.FreeSpaceAggregate
 }
 
 // NewRTorrentClient creates a new rTorrent client
-func NewRTorrentClient(url, basicUser, basicPass string) (*RTorrentClient, error) {
-	cfg := rtorrent.Config{
-		Addr:      url,
-		BasicUser: basicUser,
-		BasicPass: basicPass,
+func NewRTorrentClient(cfg config.RTorrConfig, ipFamily config.IPFamily, logger zerolog.Logger) (*RTorrentClient, error) {
+	if err := cfg.ValidateFreeSpace(); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := cfg.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := httpclient.New(httpclient.Options{
+		Timeouts: cfg.Timeouts,
+		IPFamily: ipFamily,
+		TLS:      tlsConfig,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	rt := rtorrent.NewClient(cfg)
+	rt := rtorrent.NewClientWithOpts(rtorrent.Config{
+		Addr:      cfg.URL,
+		BasicUser: cfg.BasicUser,
+		BasicPass: cfg.BasicPass,
+	}, rtorrent.WithCustomClient(httpClient))
 
 	// Test connection
 	if _, err := rt.Name(context.Background()); err != nil {
-		log.Error().Err(err).Str("url", url).Msg("failed to connect to rtorrent")
+		logger.Error().Err(err).Str("url", cfg.URL).Msg("failed to connect to rtorrent")
 		return nil, fmt.Errorf("failed to connect to rtorrent: %w", err)
 	}
 
-	log.Debug().Str("url", url).Msg("connected to rtorrent")
+	logger.Debug().Str("url", cfg.URL).Msg("connected to rtorrent")
+
+	var freeSpacePaths []string
+	if cfg.FreeSpaceMode == config.FreeSpaceLocal {
+		freeSpacePaths = cfg.FreeSpacePathList()
+	}
+
 	return &RTorrentClient{
-		client: rt,
+		client:             rt,
+		log:                logger,
+		freeSpacePaths:     freeSpacePaths,
+		freeSpaceAggregate: cfg.FreeSpaceAggregate,
+		// go-rtorrent doesn't expose every method, so keep a raw client around for the rest
+		rpc: xmlrpc.NewClient(xmlrpc.Config{
+			Addr:      cfg.URL,
+			BasicUser: cfg.BasicUser,
+			BasicPass: cfg.BasicPass,
+			Client:    httpClient,
+		}),
 	}, nil
 }
 
 // AddTorrent adds a torrent to rTorrent
 func (c *RTorrentClient) AddTorrent(torrentData []byte, name string, opts map[string]string) error {
-	log.Debug().
+	c.log.Debug().
 		Str("name", name).
 		Interface("options", opts).
 		Msg("adding torrent to rtorrent")
 
+	// rTorrent silently ignores loading a torrent it already has
+	if hash := opts["hash"]; hash != "" {
+		if _, err := c.rpc.Call(context.Background(), "d.hash", strings.ToUpper(hash)); err == nil {
+			return ErrAlreadyExists
+		}
+	}
+
 	// Set label/category if provided
 	var extraArgs []*rtorrent.FieldValue
 	if category, ok := opts["category"]; ok {
 		extraArgs = append(extraArgs, rtorrent.DLabel.SetValue(category))
 	}
 
+	// Set download path if provided. rTorrent can't skip the hash check, existing data is rechecked instead
+	if downloadDir, ok := opts["download_dir"]; ok {
+		extraArgs = append(extraArgs, rtorrent.DDirectory.SetValue(downloadDir))
+	}
+
 	// Add torrent from memory
 	// If paused=true is set in opts, use AddTorrentStopped instead of AddTorrent
 	if paused, ok := opts["paused"]; ok && paused == "true" {
@@ -60,20 +115,43 @@ func (c *RTorrentClient) AddTorrent(torrentData []byte, name string, opts map[st
 		}
 	}
 
+	// rTorrent has no tags, so the torrent ID goes into a named custom field once the torrent is loaded
+	if opts["tag_torrent_id"] == "true" && opts["hash"] != "" && opts["torrent_id"] != "" {
+		if _, err := c.rpc.Call(context.Background(), "d.custom.set", strings.ToUpper(opts["hash"]), "ptp_id", opts["torrent_id"]); err != nil {
+			// the torrent is added already, only the ID is missing
+			c.log.Warn().Err(err).Str("name", name).Msg("failed to store torrent ID")
+		}
+	}
+
 	return nil
 }
 
-// GetFreeSpace returns available disk space in bytes
+// GetFreeSpace returns available disk space in bytes. rTorrent has no method for this, so it is only
+// known with freeSpaceMode local, and 0 otherwise
 func (c *RTorrentClient) GetFreeSpace() (uint64, error) {
-	// Get free space for the default directory
-	// Note: rTorrent doesn't have a direct method for this, we'll need to implement it
-	// This is a placeholder that returns 0 for now
-	return 0, nil
+	if len(c.freeSpacePaths) == 0 {
+		return 0, nil
+	}
+	return aggregateFreeSpace(c.freeSpacePaths, c.freeSpaceAggregate)
 }
 
-// CountStalledTorrents returns the number of incomplete downloads in the given category
-func (c *RTorrentClient) CountStalledTorrents(category string) (int, error) {
-	// Get all torrents
+// ReportsFreeSpace reports whether GetFreeSpace returns the actual free space
+func (c *RTorrentClient) ReportsFreeSpace() bool {
+	return len(c.freeSpacePaths) > 0
+}
+
+// CountStalledTorrents returns the number of torrents in the given states in the category,
+// or the number of incomplete downloads if no states are given
+func (c *RTorrentClient) CountStalledTorrents(category string, states []string, metadataAfter time.Duration) (int, error) {
+	if len(states) > 0 {
+		torrents, err := c.ListTorrents(category)
+		if err != nil {
+			return 0, err
+		}
+		return countStates(torrents, states, metadataAfter), nil
+	}
+
+	// every incomplete torrent is counted, which includes those waiting for metadata
 	torrents, err := c.client.GetTorrents(context.Background(), rtorrent.ViewMain)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get torrents: %w", err)
@@ -97,10 +175,173 @@ func (c *RTorrentClient) CountStalledTorrents(category string) (int, error) {
 		}
 	}
 
-	log.Debug().
+	c.log.Debug().
 		Str("category", category).
 		Int("stalledCount", stalledCount).
 		Msg("counted incomplete torrents")
 
 	return stalledCount, nil
 }
+
+// Version returns the rTorrent client and libtorrent versions
+func (c *RTorrentClient) Version() (string, error) {
+	clientVersion, libraryVersion, err := c.versions()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (libtorrent %s)", clientVersion, libraryVersion), nil
+}
+
+// versions returns the rTorrent and libtorrent versions
+func (c *RTorrentClient) versions() (string, string, error) {
+	clientVersion, err := c.rpc.Call(context.Background(), "system.client_version")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get client version: %w", err)
+	}
+
+	libraryVersion, err := c.rpc.Call(context.Background(), "system.library_version")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get library version: %w", err)
+	}
+
+	return firstString(clientVersion), firstString(libraryVersion), nil
+}
+
+// CategoryExists always returns true since rTorrent labels are free-form and need no setup
+func (c *RTorrentClient) CategoryExists(category string) (bool, error) {
+	return true, nil
+}
+
+// firstString unwraps the single string value of an XMLRPC response
+func firstString(v interface{}) string {
+	if values, ok := v.([]interface{}); ok && len(values) > 0 {
+		v = values[0]
+	}
+	return fmt.Sprint(v)
+}
+
+// ListTorrents returns the torrents with the given label
+func (c *RTorrentClient) ListTorrents(category string) ([]Torrent, error) {
+	results, err := c.rpc.Call(context.Background(), "d.multicall2", "", string(rtorrent.ViewMain),
+		"d.hash=", "d.name=", "d.custom1=", "d.size_bytes=", "d.completed_bytes=", "d.state=",
+		"d.is_active=", "d.message=", "d.down.rate=", "d.up.total=", "d.ratio=", "d.load_date=",
+		"d.directory=", "d.is_multi_file=")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get torrents: %w", err)
+	}
+
+	var torrents []Torrent
+	outer, _ := results.([]interface{})
+	for _, outerResult := range outer {
+		inner, _ := outerResult.([]interface{})
+		for _, innerResult := range inner {
+			fields, ok := innerResult.([]interface{})
+			if !ok || len(fields) < 14 {
+				continue
+			}
+
+			t := Torrent{
+				// rTorrent reports upper-case hashes, the other clients use lower-case
+				Hash:     strings.ToLower(fmt.Sprint(fields[0])),
+				Name:     fmt.Sprint(fields[1]),
+				Category: fmt.Sprint(fields[2]),
+				Size:     toInt64(fields[3]),
+				Uploaded: toInt64(fields[9]),
+				Ratio:    float64(toInt64(fields[10])) / 1000,
+				AddedOn:  time.Unix(toInt64(fields[11]), 0),
+			}
+			if category != "" && t.Category != category {
+				continue
+			}
+
+			// d.directory includes the torrent's own folder for multi-file torrents
+			t.SavePath = fmt.Sprint(fields[12])
+			if toInt64(fields[13]) == 1 {
+				t.SavePath = filepath.Dir(t.SavePath)
+			}
+
+			completed := toInt64(fields[4])
+			if t.Size > 0 {
+				t.Progress = float64(completed) / float64(t.Size)
+			}
+			// the size is only known once the metadata is
+			t.AwaitingMetadata = t.Size == 0
+
+			t.Message = fmt.Sprint(fields[7])
+			t.State = rtorrentState(t, toInt64(fields[5]) == 1, toInt64(fields[6]) == 1, toInt64(fields[8]))
+			torrents = append(torrents, t)
+		}
+	}
+
+	return torrents, nil
+}
+
+// ExportTorrent reads the .torrent file from rTorrent's session directory, which only works
+// when rTorrent runs on the same machine
+func (c *RTorrentClient) ExportTorrent(hash string) ([]byte, error) {
+	result, err := c.rpc.Call(context.Background(), "d.session_file", strings.ToUpper(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session file: %w", err)
+	}
+
+	sessionFile := firstString(result)
+	if sessionFile == "" {
+		return nil, fmt.Errorf("rtorrent has no session file for %s", hash)
+	}
+
+	data, err := os.ReadFile(sessionFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file, is rtorrent running on this machine?: %w", err)
+	}
+	return data, nil
+}
+
+// SetCategory sets the label of the torrent with the given infohash
+func (c *RTorrentClient) SetCategory(hash, category string) error {
+	if _, err := c.rpc.Call(context.Background(), "d.custom1.set", strings.ToUpper(hash), category); err != nil {
+		return fmt.Errorf("failed to set label: %w", err)
+	}
+	return nil
+}
+
+// ResumeTorrent opens and starts the torrent with the given infohash
+func (c *RTorrentClient) ResumeTorrent(hash string) error {
+	for _, method := range []string{"d.open", "d.start"} {
+		if _, err := c.rpc.Call(context.Background(), method, strings.ToUpper(hash)); err != nil {
+			return fmt.Errorf("failed to resume torrent: %s: %w", method, err)
+		}
+	}
+	return nil
+}
+
+func rtorrentState(t Torrent, started, active bool, downRate int64) TorrentState {
+	// tracker announce failures also end up in d.message but don't affect the data
+	if t.Message != "" && !strings.HasPrefix(t.Message, "Tracker:") {
+		if strings.Contains(t.Message, "No such file") {
+			return StateMissing
+		}
+		return StateError
+	}
+
+	switch {
+	case !started || !active:
+		return StatePaused
+	case t.Complete():
+		return StateSeeding
+	case downRate == 0:
+		return StateStalled
+	}
+	return StateDownloading
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"strings"
+	"time"
+)
+
+// TorrentState is a client independent torrent state
+type TorrentState string
+
+const (
+	StateDownloading TorrentState = "downloading"
+	StateStalled     TorrentState = "stalled"
+	StateSeeding     TorrentState = "seeding"
+	StatePaused      TorrentState = "paused"
+	StateQueued      TorrentState = "queued"
+	StateChecking    TorrentState = "checking"
+	StateMoving      TorrentState = "moving"
+	StateError       TorrentState = "error"
+	StateMissing     TorrentState = "missing"
+	StateUnknown     TorrentState = "unknown"
+)
+
+// Torrent is a client independent view of a torrent
+type Torrent struct {
+	Hash     string
+	Name     string
+	Category string
+	// SavePath is the directory the torrent's content is stored in
+	SavePath string
+	Size     int64
+	// Progress is the completed fraction between 0 and 1
+	Progress float64
+	State    TorrentState
+	Uploaded int64
+	Ratio    float64
+	AddedOn  time.Time
+	// Message holds the error reported by the client, if any
+	Message string
+	// Tags are the torrent's tags (qBittorrent only)
+	Tags []string
+	// AwaitingMetadata is set while the client is still resolving the torrent's metadata, e.g. qBittorrent's metaDL
+	AwaitingMetadata bool
+}
+
+// Complete reports whether all data has been downloaded
+func (t Torrent) Complete() bool {
+	return t.Progress >= 1
+}
+
+// matchesState reports whether any of the given state names is in states
+func matchesState(states []string, names ...string) bool {
+	for _, name := range names {
+		for _, state := range states {
+			if strings.EqualFold(state, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countStates returns the number of torrents in any of the given client independent states, or
+// waiting for their metadata for longer than metadataAfter
+func countStates(torrents []Torrent, states []string, metadataAfter time.Duration) int {
+	count := 0
+	for _, t := range torrents {
+		if matchesState(states, string(t.State)) || t.StuckOnMetadata(metadataAfter) {
+			count++
+		}
+	}
+	return count
+}
+
+// StuckOnMetadata reports whether the torrent has been waiting for its metadata for longer than after,
+// 0 disables the check. It occupies a download slot without making progress, just like a stalled torrent.
+func (t Torrent) StuckOnMetadata(after time.Duration) bool {
+	return after > 0 && t.AwaitingMetadata && time.Since(t.AddedOn) > after
+}
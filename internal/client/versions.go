@@ -0,0 +1,82 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// The oldest versions the libraries used for each client support
+const (
+	// go-qbittorrent only implements WebAPI v2, added in qBittorrent 4.1
+	minQBitWebAPIVersion = "2.0.0"
+	// go-rtorrent lists torrents with d.multicall2, added in rTorrent 0.9
+	minRTorrentVersion = "0.9.0"
+	// go-deluge supports Deluge 1.3 and 2
+	minDelugeVersion = "1.3.0"
+)
+
+// versionChecker is implemented by clients with a minimum supported version
+type versionChecker interface {
+	supportedVersion() (version, minimum string, ok bool, err error)
+}
+
+// SupportedVersion returns the client's version and, when it's older than the library used for it
+// supports, that minimum with ok false. Versions that can't be parsed are assumed to be supported.
+func SupportedVersion(tc TorrentClient) (version, minimum string, ok bool, err error) {
+	if checker, isChecker := tc.(versionChecker); isChecker {
+		return checker.supportedVersion()
+	}
+	version, err = tc.Version()
+	return version, "", true, err
+}
+
+// atLeast reports whether version is minimum or newer, or true if it can't be parsed
+func atLeast(version, minimum string) bool {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return true
+	}
+	return !v.LessThan(semver.MustParse(minimum))
+}
+
+func (c *QBitClient) supportedVersion() (string, string, bool, error) {
+	appVersion, err := c.client.GetAppVersion()
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to get app version: %w", err)
+	}
+	apiVersion, err := c.client.GetWebAPIVersion()
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to get web api version: %w", err)
+	}
+
+	version := fmt.Sprintf("%s (web api %s)", appVersion, apiVersion)
+	if !atLeast(apiVersion, minQBitWebAPIVersion) {
+		return version, "web api " + minQBitWebAPIVersion, false, nil
+	}
+	return version, "", true, nil
+}
+
+func (c *RTorrentClient) supportedVersion() (string, string, bool, error) {
+	clientVersion, libraryVersion, err := c.versions()
+	if err != nil {
+		return "", "", false, err
+	}
+
+	version := fmt.Sprintf("%s (libtorrent %s)", clientVersion, libraryVersion)
+	if !atLeast(clientVersion, minRTorrentVersion) {
+		return version, minRTorrentVersion, false, nil
+	}
+	return version, "", true, nil
+}
+
+func (c *DelugeClient) supportedVersion() (string, string, bool, error) {
+	version, err := c.Version()
+	if err != nil {
+		return "", "", false, err
+	}
+	if !atLeast(version, minDelugeVersion) {
+		return version, minDelugeVersion, false, nil
+	}
+	return version, "", true, nil
+}
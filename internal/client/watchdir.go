@@ -1,53 +1,228 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
+	"github.com/s0up4200/ptparchiver-go/internal/config"
 )
 
 // WatchDirClient implements TorrentClient interface for watch directory based clients
 type WatchDirClient struct {
-	watchDir string
+	watchDirs []string
+	selection config.WatchDirSelect
+	perms     config.WatchDirPerms
+	log       zerolog.Logger
+
+	// mu guards next and lastSaved
+	mu        sync.Mutex
+	next      int
+	lastSaved string
 }
 
-// NewWatchDirClient creates a new watch directory client
-func NewWatchDirClient(watchDir string) (*WatchDirClient, error) {
-	// Create watch directory if it doesn't exist
-	if err := os.MkdirAll(watchDir, 0755); err != nil {
-		log.Error().Err(err).Str("watchDir", watchDir).Msg("failed to create watch directory")
-		return nil, fmt.Errorf("failed to create watch directory: %w", err)
+// NewWatchDirClient creates a new watch directory client saving to the container's watch directories
+func NewWatchDirClient(container config.Container, logger zerolog.Logger) (*WatchDirClient, error) {
+	watchDirs := container.WatchDirectories()
+	if len(watchDirs) == 0 {
+		return nil, fmt.Errorf("no watch directory configured")
+	}
+	if err := container.WatchDirSelect.Validate(); err != nil {
+		return nil, err
+	}
+	perms, err := container.WatchDirPermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &WatchDirClient{
+		watchDirs: watchDirs,
+		selection: container.WatchDirSelect,
+		perms:     perms,
+		log:       logger,
+	}
+
+	// Create watch directories if they don't exist
+	for _, watchDir := range watchDirs {
+		if _, err := os.Stat(watchDir); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(watchDir, 0755); err != nil {
+			logger.Error().Err(err).Str("watchDir", watchDir).Msg("failed to create watch directory")
+			return nil, fmt.Errorf("failed to create watch directory: %w", err)
+		}
+		if err := c.applyPerms(watchDir, perms.DirMode); err != nil {
+			logger.Error().Err(err).Str("watchDir", watchDir).Msg("failed to set watch directory permissions")
+			return nil, fmt.Errorf("failed to set watch directory permissions: %w", err)
+		}
 	}
 
-	log.Debug().Str("watchDir", watchDir).Msg("created watch directory client")
-	return &WatchDirClient{
-		watchDir: watchDir,
-	}, nil
+	logger.Debug().Strs("watchDirs", watchDirs).Msg("created watch directory client")
+	return c, nil
 }
 
-// AddTorrent saves the torrent file to the watch directory
+// applyPerms sets the configured mode, if any, and owner of a file or directory. The mode is set
+// explicitly since the umask applies when creating it.
+func (c *WatchDirClient) applyPerms(path string, mode os.FileMode) error {
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	if c.perms.UID != -1 || c.perms.GID != -1 {
+		if err := os.Chown(path, c.perms.UID, c.perms.GID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pickDir returns the directory the next torrent is saved to
+func (c *WatchDirClient) pickDir() string {
+	if len(c.watchDirs) == 1 {
+		return c.watchDirs[0]
+	}
+
+	if c.selection == config.WatchDirRoundRobin {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		dir := c.watchDirs[c.next%len(c.watchDirs)]
+		c.next++
+		return dir
+	}
+
+	best, bestFree := c.watchDirs[0], uint64(0)
+	for _, dir := range c.watchDirs {
+		free, err := localFreeSpace(dir)
+		if err != nil {
+			c.log.Warn().Err(err).Str("watchDir", dir).Msg("failed to get free space of watch directory")
+			continue
+		}
+		if free > bestFree {
+			best, bestFree = dir, free
+		}
+	}
+	return best
+}
+
+// AddTorrent saves the torrent file to the watch directory, named after the torrent or, when the name
+// can't be used as a file name, the PTP torrent ID from opts["torrent_id"]
 func (c *WatchDirClient) AddTorrent(torrentData []byte, name string, opts map[string]string) error {
-	torrentPath := filepath.Join(c.watchDir, fmt.Sprintf("%s.torrent", name))
+	fileName := SafeFileName(name, opts["torrent_id"], opts["hash"])
+	if fileName != name {
+		c.log.Debug().Str("torrent", name).Str("fileName", fileName).Msg("sanitized torrent file name")
+	}
+	torrentPath := filepath.Join(c.pickDir(), fileName+".torrent")
 
 	if err := os.WriteFile(torrentPath, torrentData, 0644); err != nil {
-		log.Error().Err(err).Str("path", torrentPath).Msg("failed to write torrent file")
+		c.log.Error().Err(err).Str("path", torrentPath).Msg("failed to write torrent file")
 		return fmt.Errorf("failed to write torrent file: %w", err)
 	}
+	if err := c.applyPerms(torrentPath, c.perms.FileMode); err != nil {
+		c.log.Error().Err(err).Str("path", torrentPath).Msg("failed to set torrent file permissions")
+		return fmt.Errorf("failed to set torrent file permissions: %w", err)
+	}
 
-	log.Info().
+	c.mu.Lock()
+	c.lastSaved = torrentPath
+	c.mu.Unlock()
+	c.log.Info().
 		Str("path", torrentPath).
 		Msg("saved torrent file to watch directory")
 
+	if sidecar, ok := opts["sidecar"]; ok && sidecar == "true" {
+		if err := c.writeSidecar(strings.TrimSuffix(torrentPath, ".torrent")+".json", opts); err != nil {
+			// the torrent is already saved, so only the extra context is lost
+			c.log.Warn().Err(err).Str("path", torrentPath).Msg("failed to write sidecar file")
+		}
+	}
+
 	return nil
 }
 
+// sidecar is the metadata written next to a .torrent file for automation picking files from the watch directory
+type sidecar struct {
+	TorrentID string   `json:"torrentId,omitempty"`
+	InfoHash  string   `json:"infoHash,omitempty"`
+	Size      int64    `json:"size"`
+	Category  string   `json:"category,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+func (c *WatchDirClient) writeSidecar(path string, opts map[string]string) error {
+	meta := sidecar{
+		TorrentID: opts["torrent_id"],
+		InfoHash:  opts["hash"],
+		Category:  opts["category"],
+	}
+	meta.Size, _ = strconv.ParseInt(opts["size"], 10, 64)
+	if tags := opts["tags"]; tags != "" {
+		meta.Tags = strings.Split(tags, ",")
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sidecar: %w", err)
+	}
+
+	// written after the .torrent so a watcher never sees the sidecar without its torrent
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	if err := c.applyPerms(path, c.perms.FileMode); err != nil {
+		return fmt.Errorf("failed to set sidecar permissions: %w", err)
+	}
+	return nil
+}
+
+// LastSaved returns the path of the last .torrent file saved by AddTorrent
+func (c *WatchDirClient) LastSaved() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSaved
+}
+
 func (c *WatchDirClient) GetFreeSpace() (uint64, error) {
 	return 0, nil
 }
 
 // CountStalledTorrents always returns 0 since watch directory can't track torrent status
-func (c *WatchDirClient) CountStalledTorrents(category string) (int, error) {
+func (c *WatchDirClient) CountStalledTorrents(category string, states []string, metadataAfter time.Duration) (int, error) {
 	return 0, nil
 }
+
+// Version returns a fixed value since a watch directory has no client to query
+func (c *WatchDirClient) Version() (string, error) {
+	return "watchdir", nil
+}
+
+// CategoryExists always returns true since watch directories have no categories
+func (c *WatchDirClient) CategoryExists(category string) (bool, error) {
+	return true, nil
+}
+
+// ListTorrents returns nothing since a watch directory can't see what the client does with its files
+func (c *WatchDirClient) ListTorrents(category string) ([]Torrent, error) {
+	return nil, nil
+}
+
+// ExportTorrent is not supported since the watch directory doesn't track torrents by hash
+func (c *WatchDirClient) ExportTorrent(hash string) ([]byte, error) {
+	return nil, fmt.Errorf("exporting torrents is not supported for watch directories")
+}
+
+// ResumeTorrent is not supported since the watch directory doesn't track torrents by hash
+func (c *WatchDirClient) ResumeTorrent(hash string) error {
+	return fmt.Errorf("resuming torrents is not supported for watch directories")
+}
+
+// SetCategory is not supported since the watch directory doesn't track torrents by hash
+func (c *WatchDirClient) SetCategory(hash, category string) error {
+	return fmt.Errorf("setting categories is not supported for watch directories")
+}
@@ -0,0 +1,37 @@
+package config
+
+import "fmt"
+
+// Account is the PTP API credentials and site a container fetches with
+type Account struct {
+	ApiUser string
+	ApiKey  string
+	BaseURL string
+}
+
+// Account returns the credentials the named container fetches with, which are the global ones for
+// every field the container doesn't override
+func (c *Config) Account(name string) Account {
+	account := Account{ApiUser: c.ApiUser, ApiKey: c.ApiKey, BaseURL: c.BaseURL}
+
+	container := c.Containers[name]
+	if container.ApiUser != "" {
+		account.ApiUser = container.ApiUser
+	}
+	if container.ApiKey != "" {
+		account.ApiKey = container.ApiKey
+	}
+	if container.BaseURL != "" {
+		account.BaseURL = container.BaseURL
+	}
+	return account
+}
+
+// ValidateAccount checks that a container overriding the API user also overrides the key and the
+// other way around, since a key only works for the user it belongs to
+func (c Container) ValidateAccount() error {
+	if (c.ApiUser == "") != (c.ApiKey == "") {
+		return fmt.Errorf("apiUser and apiKey must be overridden together")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ContainerAnnounceHost returns the host the named container's announce URLs are rewritten to, its own or
+// the global one, or an empty string if they're left as PTP sent them
+func (c *Config) ContainerAnnounceHost(name string) string {
+	if host := c.Containers[name].AnnounceHost; host != "" {
+		return host
+	}
+	return c.AnnounceHost
+}
+
+// ParseAnnounceHost splits an announce host such as tracker.lan:8080 or http://relay.lan into the
+// scheme, empty to keep the original one, and the host with an optional port
+func ParseAnnounceHost(s string) (scheme, host string, err error) {
+	if !strings.Contains(s, "://") {
+		s = "//" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid announceHost %q: %w", s, err)
+	}
+	if u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return "", "", fmt.Errorf("invalid announceHost %q, expected a host with an optional scheme and port", strings.TrimPrefix(s, "//"))
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", fmt.Errorf("invalid announceHost scheme %q, expected http or https", u.Scheme)
+	}
+	return u.Scheme, u.Host, nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+const (
+	defaultBackoffAfter       = 1
+	defaultBackoffMaxInterval = 24 * 60
+)
+
+// Backoff configures how fetching slows down for a container PTP has no torrents for
+type Backoff struct {
+	// After is the number of fetches in a row PTP had no torrents for before the interval is stretched. Default is 1
+	After int `yaml:"after,omitempty"`
+	// MaxInterval caps the stretched interval in minutes. Default is 1440 (a day)
+	MaxInterval int `yaml:"maxInterval,omitempty"`
+}
+
+// AfterOrDefault returns After, or the default if it isn't set
+func (b Backoff) AfterOrDefault() int {
+	if b.After <= 0 {
+		return defaultBackoffAfter
+	}
+	return b.After
+}
+
+// MaxIntervalOrDefault returns MaxInterval as a duration, or the default if it isn't set
+func (b Backoff) MaxIntervalOrDefault() time.Duration {
+	if b.MaxInterval <= 0 {
+		return defaultBackoffMaxInterval * time.Minute
+	}
+	return time.Duration(b.MaxInterval) * time.Minute
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// Blocklist keeps specific torrents from being added, by PTP torrent ID or by regular expressions
+// matched against the torrent name
+type Blocklist struct {
+	TorrentIDs []string `yaml:"torrentIds,omitempty"`
+	// Patterns are Go regular expressions, add (?i) to ignore case
+	Patterns []string `yaml:"patterns,omitempty"`
+}
+
+// Validate checks that every pattern compiles
+func (b Blocklist) Validate() error {
+	for _, pattern := range b.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid blocklist pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match returns the entry blocking the torrent, the name is skipped when it is empty. The patterns
+// must have been validated.
+func (b Blocklist) Match(torrentID, name string) (string, bool) {
+	if torrentID != "" && slices.Contains(b.TorrentIDs, torrentID) {
+		return "torrent ID " + torrentID, true
+	}
+	if name == "" {
+		return "", false
+	}
+	for _, pattern := range b.Patterns {
+		if regexp.MustCompile(pattern).MatchString(name) {
+			return "pattern " + pattern, true
+		}
+	}
+	return "", false
+}
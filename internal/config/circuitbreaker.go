@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+const defaultCircuitBreakerCooldown = 30
+
+// CircuitBreaker configures how long a torrent client that keeps failing to connect is left alone
+type CircuitBreaker struct {
+	// Failures is the number of failed connection attempts in a row after which the client is skipped
+	// for Cooldown. Default is 0 (disabled)
+	Failures int `yaml:"failures,omitempty"`
+	// Cooldown is the number of minutes the client is skipped for. Default is 30
+	Cooldown int `yaml:"cooldown,omitempty"`
+}
+
+// Enabled reports whether clients are skipped after failing repeatedly
+func (b CircuitBreaker) Enabled() bool {
+	return b.Failures > 0
+}
+
+// CooldownOrDefault returns Cooldown as a duration, or the default if it isn't set
+func (b CircuitBreaker) CooldownOrDefault() time.Duration {
+	if b.Cooldown <= 0 {
+		return defaultCircuitBreakerCooldown * time.Minute
+	}
+	return time.Duration(b.Cooldown) * time.Minute
+}
@@ -10,6 +10,57 @@ type Config struct {
 	Containers    map[string]Container    `yaml:"containers"`
 	FetchSleep    int                     `yaml:"fetchSleep" default:"5"`
 	Interval      int                     `yaml:"interval" default:"360"`
+	// StartupDelay is the number of minutes the run command waits before connecting to clients and fetching,
+	// so torrent clients and network mounts can come up after boot. Default is 0
+	StartupDelay int `yaml:"startupDelay,omitempty"`
+	// AuditInterval is the number of minutes between seeding audits by the run command, which check that
+	// every archived torrent still exists on its client with its data. Default is 0 (disabled)
+	AuditInterval int `yaml:"auditInterval,omitempty"`
+	// AutoResume lets the seeding audit start archived torrents found paused, except in containers
+	// that add torrents paused
+	AutoResume bool `yaml:"autoResume,omitempty"`
+	// Backoff stretches the interval of containers PTP repeatedly has no torrents for
+	Backoff Backoff `yaml:"backoff,omitempty"`
+	// MaxStalledGlobal halts all fetching while the stalled torrents of every container add up to
+	// this many or more. Default is 0 (unlimited)
+	MaxStalledGlobal int `yaml:"maxStalledGlobal,omitempty"`
+	// CircuitBreaker skips a torrent client for a while after it failed to connect several times in a row
+	CircuitBreaker CircuitBreaker `yaml:"circuitBreaker,omitempty"`
+	// MaxDownloading stops fetching while this many archive torrents are downloading across every
+	// client. Default is 0 (unlimited)
+	MaxDownloading int `yaml:"maxDownloading,omitempty"`
+	// UserAgent overrides the User-Agent sent to PTP, the ptparchiver-go version is always appended
+	UserAgent string `yaml:"userAgent,omitempty"`
+	// Timeouts for requests to PTP
+	Timeouts Timeouts `yaml:"timeouts,omitempty"`
+	// BindAddress is a local IP address or interface name that requests to PTP are sent from
+	BindAddress string `yaml:"bindAddress,omitempty"`
+	// IPFamily forces or prefers IPv4 or IPv6 for connections to PTP, rTorrent and Deluge
+	IPFamily IPFamily `yaml:"ipFamily,omitempty"`
+	// VersionPolicy is fail, warn or ignore and decides how a newer official Python script version
+	// reported by PTP is handled. Defaults to warn
+	VersionPolicy VersionPolicy `yaml:"versionPolicy,omitempty"`
+	// ControlSocket is the path of a unix socket the run command listens on for the trigger command
+	ControlSocket string `yaml:"controlSocket,omitempty"`
+	// PprofAddress is a host:port the run command serves pprof and expvar on for diagnosing leaks,
+	// e.g. 127.0.0.1:6060. Disabled when empty
+	PprofAddress string `yaml:"pprofAddress,omitempty"`
+	// DisableUpdateCheck stops ptparchiver from looking up the latest release on GitHub
+	DisableUpdateCheck bool `yaml:"disableUpdateCheck,omitempty"`
+	// Timezone is an IANA time zone such as Europe/Oslo used for log timestamps and schedules,
+	// defaults to the system time zone
+	Timezone string `yaml:"timezone,omitempty"`
+	// MaxTorrentFileSize caps the size of a downloaded .torrent file, e.g. "10M". Defaults to 10M.
+	MaxTorrentFileSize string `yaml:"maxTorrentFileSize,omitempty"`
+	// Influx pushes fetch, archive and free space metrics after every fetch cycle
+	Influx Influx `yaml:"influx,omitempty"`
+	// Statsd sends counters and timers around fetches and adds to a statsd or DogStatsD agent
+	Statsd Statsd `yaml:"statsd,omitempty"`
+	// Blocklist keeps torrents from being added to any container
+	Blocklist Blocklist `yaml:"blocklist,omitempty"`
+	// AnnounceHost replaces the host of the announce URLs in downloaded torrents before they're added,
+	// e.g. tracker.lan:8080 or http://relay.lan, for announcing through a relay
+	AnnounceHost string `yaml:"announceHost,omitempty"`
 }
 
 type QBitConfig struct {
@@ -18,12 +69,31 @@ type QBitConfig struct {
 	Password  string `yaml:"password"`
 	BasicUser string `yaml:"basicUser,omitempty"`
 	BasicPass string `yaml:"basicPass,omitempty"`
+	// MaxStalled limits the stalled torrents across all containers using this client, 0 is unlimited
+	MaxStalled int `yaml:"maxStalled,omitempty"`
+	// Timeouts, only the request timeout is supported by the qBittorrent library
+	Timeouts Timeouts `yaml:"timeouts,omitempty"`
+	// TLS sets a client certificate for instances behind a mutual TLS reverse proxy
+	TLS TLS `yaml:"tls,omitempty"`
 }
 
 type RTorrConfig struct {
-	URL       string `yaml:"url"` // SCGI or HTTP(S) URL to rTorrent's XMLRPC endpoint
-	BasicUser string `yaml:"basicUser,omitempty"`
-	BasicPass string `yaml:"basicPass,omitempty"`
+	URL       string   `yaml:"url"` // SCGI or HTTP(S) URL to rTorrent's XMLRPC endpoint
+	BasicUser string   `yaml:"basicUser,omitempty"`
+	BasicPass string   `yaml:"basicPass,omitempty"`
+	Timeouts  Timeouts `yaml:"timeouts,omitempty"`
+	// MaxStalled limits the stalled torrents across all containers using this client, 0 is unlimited
+	MaxStalled int `yaml:"maxStalled,omitempty"`
+	// TLS sets a client certificate for instances behind a mutual TLS reverse proxy
+	TLS TLS `yaml:"tls,omitempty"`
+	// FreeSpaceMode local checks the free space of FreeSpacePath on this host instead of skipping the
+	// check, for rTorrent running on the same machine as ptparchiver
+	FreeSpaceMode FreeSpaceMode `yaml:"freeSpaceMode,omitempty"`
+	FreeSpacePath string        `yaml:"freeSpacePath,omitempty"`
+	// FreeSpacePaths are more paths for clients spreading data over several mounts, combined by
+	// FreeSpaceAggregate, max (default) or sum
+	FreeSpacePaths     []string           `yaml:"freeSpacePaths,omitempty"`
+	FreeSpaceAggregate FreeSpaceAggregate `yaml:"freeSpaceAggregate,omitempty"`
 }
 
 type DelugeConfig struct {
@@ -33,6 +103,10 @@ type DelugeConfig struct {
 	Password  string `yaml:"password"`
 	BasicUser string `yaml:"basicUser"`
 	BasicPass string `yaml:"basicPass"`
+	// MaxStalled limits the stalled torrents across all containers using this client, 0 is unlimited
+	MaxStalled int `yaml:"maxStalled,omitempty"`
+	// Timeouts, the TLS timeout is unused since the request timeout applies to each read and write on the daemon connection
+	Timeouts Timeouts `yaml:"timeouts,omitempty"`
 }
 
 type Container struct {
@@ -41,13 +115,86 @@ type Container struct {
 	Size string `yaml:"size"`
 	// MaxStalled sets the maximum number of partial/stalled torrents before pausing new downloads
 	// Default is 0 (unlimited). Set a positive integer to limit stalled torrents
-	MaxStalled int      `yaml:"maxStalled"`
-	Category   string   `yaml:"category"`
-	Tags       []string `yaml:"tags,omitempty"`
-	Client     string   `yaml:"client,omitempty"`   // Name of the torrent client to use (optional)
-	WatchDir   string   `yaml:"watchDir,omitempty"` // Directory to save .torrent files to (optional)
+	MaxStalled int `yaml:"maxStalled"`
+	// StalledTag only counts torrents with this tag toward MaxStalled, for categories shared with
+	// non-archive torrents. With StalledTagOnly tagged torrents in every category are counted (qBittorrent)
+	StalledTag     string `yaml:"stalledTag,omitempty"`
+	StalledTagOnly bool   `yaml:"stalledTagOnly,omitempty"`
+	// MetadataStalledAfter counts torrents still fetching their metadata this many minutes after being added
+	// toward MaxStalled, whatever StalledStates says. Default is 0 (disabled)
+	MetadataStalledAfter int `yaml:"metadataStalledAfter,omitempty"`
+	// StalledStates lists the torrent states counted toward MaxStalled, either client independent
+	// states (stalled, downloading, queued, error, ...) or raw qBittorrent states such as stalledDL or metaDL.
+	// Defaults to stalledDL for qBittorrent, every incomplete torrent for rTorrent and stalled for Deluge
+	StalledStates []string `yaml:"stalledStates,omitempty"`
+	Category      string   `yaml:"category"`
+	Tags          []string `yaml:"tags,omitempty"`
+	Client        string   `yaml:"client,omitempty"`   // Name of the torrent client to use (optional)
+	WatchDir      string   `yaml:"watchDir,omitempty"` // Directory to save .torrent files to (optional)
+	// WatchDirs spreads torrents over several watch directories, e.g. on different disks
+	WatchDirs []string `yaml:"watchDirs,omitempty"`
+	// WatchDirSelect is free-space (default) or round-robin and picks the directory for each torrent
+	WatchDirSelect WatchDirSelect `yaml:"watchDirSelect,omitempty"`
+	// WatchDirCleanup removes .torrent files that were never picked up from the watch directory after this many days,
+	// 0 keeps them
+	WatchDirCleanup int `yaml:"watchDirCleanup,omitempty"`
+	// FileMode and DirMode are octal permissions such as 0640 for the watch directory files and directories,
+	// Chown is user:group and sets their owner
+	FileMode string `yaml:"fileMode,omitempty"`
+	DirMode  string `yaml:"dirMode,omitempty"`
+	Chown    string `yaml:"chown,omitempty"`
+	// Sidecar writes a <name>.json file with the torrent's PTP details next to each .torrent saved to a watch directory
+	Sidecar bool `yaml:"sidecar,omitempty"`
+	// AutoTags adds tags for the resolution, year and source parsed from the torrent name, e.g. 1080p, 1999
+	// and remux (qBittorrent and watch directory sidecars)
+	AutoTags bool `yaml:"autoTags,omitempty"`
+	// TagTorrentID tags each torrent with ptp-<TorrentID> so it can be traced back to PTP (qBittorrent and watch
+	// directory sidecars). rTorrent stores the ID in the ptp_id custom field instead
+	TagTorrentID bool `yaml:"tagTorrentId,omitempty"`
+	// MinTorrentSize and MaxTorrentSize skip torrents smaller or larger than this, e.g. "100G", instead of
+	// adding them. Default is 0 (unlimited)
+	MinTorrentSize string `yaml:"minTorrentSize,omitempty"`
+	MaxTorrentSize string `yaml:"maxTorrentSize,omitempty"`
+	// CountCategoryUsage counts the torrents already in the category on the client, also those added
+	// outside of ptparchiver, toward Size and stops adding once they fill it
+	CountCategoryUsage bool `yaml:"countCategoryUsage,omitempty"`
+	// Blocklist keeps torrents from being added to this container, on top of the global blocklist
+	Blocklist Blocklist `yaml:"blocklist,omitempty"`
+	// AnnounceHost overrides the global AnnounceHost for this container
+	AnnounceHost string `yaml:"announceHost,omitempty"`
+	// OversizeDir is where the .torrent files skipped by MaxTorrentSize are saved, they're discarded when unset
+	OversizeDir string `yaml:"oversizeDir,omitempty"`
 	// StartPaused determines if torrents should be added in a paused/stopped state
 	StartPaused bool `yaml:"startPaused,omitempty"`
 	// AddPaused is an alias for StartPaused for backward compatibility
 	AddPaused bool `yaml:"addPaused,omitempty"`
+	// SequentialDownload downloads pieces in order and FirstLastPiecePrio fetches the first and last
+	// pieces of each file first, for previewing content while it downloads (qBittorrent and Deluge 2)
+	SequentialDownload bool `yaml:"sequentialDownload,omitempty"`
+	FirstLastPiecePrio bool `yaml:"firstLastPiecePrio,omitempty"`
+	// DownloadLimit and UploadLimit throttle each added torrent to a rate per second, e.g. "5M" (qBittorrent and Deluge)
+	DownloadLimit string `yaml:"downloadLimit,omitempty"`
+	UploadLimit   string `yaml:"uploadLimit,omitempty"`
+	// ExtraParams are added to the archive.php query string, for server side options not known to this release.
+	// They can't override the parameters ptparchiver sets itself.
+	ExtraParams map[string]string `yaml:"extraParams,omitempty"`
+	// ApiUser, ApiKey and BaseURL override the global PTP credentials, to archive for another account
+	// or with a secondary API key
+	ApiUser string `yaml:"apiUser,omitempty"`
+	ApiKey  string `yaml:"apiKey,omitempty"`
+	BaseURL string `yaml:"baseUrl,omitempty"`
+}
+
+// ClientMaxStalled returns the stalled limit of the named qBittorrent or rTorrent client, or 0 if it has none
+func (c *Config) ClientMaxStalled(name string) int {
+	if qbit, ok := c.QBitClients[name]; ok {
+		return qbit.MaxStalled
+	}
+	if rtorr, ok := c.RTorrClients[name]; ok {
+		return rtorr.MaxStalled
+	}
+	if deluge, ok := c.DelugeClients[name]; ok {
+		return deluge.MaxStalled
+	}
+	return 0
 }
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"maps"
+	"slices"
+)
+
+// CategoryConflict is a group of containers adding torrents to the same category on the same client
+type CategoryConflict struct {
+	Client     string
+	Category   string
+	Containers []string
+}
+
+// CategoryConflicts returns the containers sharing a client and category, whose stalled counts and category
+// usage then include each other's torrents. Containers told apart by distinct stalledTags are only reported
+// when one of them counts category usage.
+func (c *Config) CategoryConflicts() []CategoryConflict {
+	type target struct{ client, category string }
+	groups := make(map[target][]string)
+	for _, name := range slices.Sorted(maps.Keys(c.Containers)) {
+		container := c.Containers[name]
+		if container.Client == "" || container.UsesWatchDir() {
+			continue
+		}
+		key := target{container.Client, container.Category}
+		groups[key] = append(groups[key], name)
+	}
+
+	var conflicts []CategoryConflict
+	for key, names := range groups {
+		if len(names) < 2 || c.distinctStalledTags(names) {
+			continue
+		}
+		conflicts = append(conflicts, CategoryConflict{Client: key.client, Category: key.category, Containers: names})
+	}
+	slices.SortFunc(conflicts, func(a, b CategoryConflict) int {
+		return slices.Compare([]string{a.Client, a.Category}, []string{b.Client, b.Category})
+	})
+	return conflicts
+}
+
+// distinctStalledTags reports whether every container counts stalled torrents by a tag of its own and
+// none counts the category's usage
+func (c *Config) distinctStalledTags(names []string) bool {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		container := c.Containers[name]
+		if container.StalledTag == "" || seen[container.StalledTag] || container.CountCategoryUsage {
+			return false
+		}
+		seen[container.StalledTag] = true
+	}
+	return true
+}
@@ -0,0 +1,303 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EncryptedPrefix marks a config value encrypted with a passphrase
+const EncryptedPrefix = "enc:v1:"
+
+// Environment variables the passphrase for encrypted config values is read from
+const (
+	PassphraseEnv     = "PTPARCHIVER_PASSPHRASE"
+	PassphraseFileEnv = "PTPARCHIVER_PASSPHRASE_FILE"
+)
+
+const (
+	saltSize         = 16
+	keySize          = 32
+	pbkdf2Iterations = 600_000
+)
+
+// ErrNoPassphrase is returned when the config has encrypted values but no passphrase is available
+var ErrNoPassphrase = errors.New("config has encrypted values but no passphrase was given, set " + PassphraseEnv + " or " + PassphraseFileEnv)
+
+// secretKeys are the YAML keys whose values are encrypted by EncryptYAML
+var secretKeys = map[string]bool{
+	"apiKey":    true,
+	"password":  true,
+	"basicPass": true,
+	"token":     true,
+}
+
+// PassphraseFromEnv returns the passphrase from PTPARCHIVER_PASSPHRASE, or from the key file named by
+// PTPARCHIVER_PASSPHRASE_FILE. It returns an empty string if neither is set.
+func PassphraseFromEnv() (string, error) {
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	path := os.Getenv(PassphraseFileEnv)
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Encrypted reports whether any secret in the config is encrypted
+func (c *Config) Encrypted() bool {
+	encrypted := false
+	c.eachSecret(func(v *string) error {
+		encrypted = encrypted || strings.HasPrefix(*v, EncryptedPrefix)
+		return nil
+	})
+	return encrypted
+}
+
+// Decrypt replaces every encrypted secret in the config with its plain text
+func (c *Config) Decrypt(passphrase string) error {
+	keys := newKeyCache(passphrase)
+	return c.eachSecret(func(v *string) error {
+		if !strings.HasPrefix(*v, EncryptedPrefix) {
+			return nil
+		}
+		plain, err := keys.decrypt(*v)
+		if err != nil {
+			return err
+		}
+		*v = plain
+		return nil
+	})
+}
+
+// eachSecret calls fn with a pointer to every credential in the config
+func (c *Config) eachSecret(fn func(v *string) error) error {
+	if err := fn(&c.ApiKey); err != nil {
+		return fmt.Errorf("apiKey: %w", err)
+	}
+	for name, qbit := range c.QBitClients {
+		if err := eachString(fn, &qbit.Password, &qbit.BasicPass); err != nil {
+			return fmt.Errorf("qbittorrent %s: %w", name, err)
+		}
+		c.QBitClients[name] = qbit
+	}
+	for name, rtorr := range c.RTorrClients {
+		if err := eachString(fn, &rtorr.BasicPass); err != nil {
+			return fmt.Errorf("rtorrent %s: %w", name, err)
+		}
+		c.RTorrClients[name] = rtorr
+	}
+	for name, deluge := range c.DelugeClients {
+		if err := eachString(fn, &deluge.Password, &deluge.BasicPass); err != nil {
+			return fmt.Errorf("deluge %s: %w", name, err)
+		}
+		c.DelugeClients[name] = deluge
+	}
+	if err := eachString(fn, &c.Influx.Token, &c.Influx.Password); err != nil {
+		return fmt.Errorf("influx: %w", err)
+	}
+	for name, container := range c.Containers {
+		if err := fn(&container.ApiKey); err != nil {
+			return fmt.Errorf("container %s apiKey: %w", name, err)
+		}
+		c.Containers[name] = container
+	}
+	return nil
+}
+
+func eachString(fn func(v *string) error, values ...*string) error {
+	for _, v := range values {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncryptYAML encrypts the plain text credentials in a YAML config, leaving comments and layout intact.
+// It returns the new document and the number of values that were encrypted.
+func EncryptYAML(data []byte, passphrase string) ([]byte, int, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, 0, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return rewriteSecrets(data, func(v string) (string, bool, error) {
+		if v == "" || strings.HasPrefix(v, EncryptedPrefix) {
+			return v, false, nil
+		}
+		enc, err := encrypt(v, key, salt)
+		return enc, true, err
+	})
+}
+
+// DecryptYAML replaces the encrypted credentials in a YAML config with their plain text.
+// It returns the new document and the number of values that were decrypted.
+func DecryptYAML(data []byte, passphrase string) ([]byte, int, error) {
+	keys := newKeyCache(passphrase)
+	return rewriteSecrets(data, func(v string) (string, bool, error) {
+		if !strings.HasPrefix(v, EncryptedPrefix) {
+			return v, false, nil
+		}
+		plain, err := keys.decrypt(v)
+		return plain, true, err
+	})
+}
+
+// RedactYAML blanks the plain text credentials in a YAML config, keeping encrypted values and secret
+// manager references since they don't reveal anything. It returns the new document and the number of
+// values that were blanked.
+func RedactYAML(data []byte) ([]byte, int, error) {
+	return rewriteSecrets(data, func(v string) (string, bool, error) {
+		if v == "" || strings.HasPrefix(v, EncryptedPrefix) || strings.HasPrefix(v, VaultPrefix) || strings.HasPrefix(v, OnePasswordPrefix) {
+			return v, false, nil
+		}
+		return "", true, nil
+	})
+}
+
+// rewriteSecrets applies fn to the value of every secret key in the document
+func rewriteSecrets(data []byte, fn func(v string) (string, bool, error)) ([]byte, int, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	changed := 0
+	var walk func(n *yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key, value := n.Content[i], n.Content[i+1]
+				if secretKeys[key.Value] && value.Kind == yaml.ScalarNode {
+					v, ok, err := fn(value.Value)
+					if err != nil {
+						return fmt.Errorf("line %d: %w", value.Line, err)
+					}
+					if ok {
+						// let the encoder pick quoting, so decrypted values that look like numbers stay strings
+						value.Value = v
+						value.Tag = "!!str"
+						value.Style = 0
+						changed++
+					}
+				}
+			}
+		}
+		for _, child := range n.Content {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(&doc); err != nil {
+		return nil, 0, err
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return out.Bytes(), changed, nil
+}
+
+func encrypt(plain string, key, salt []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := append(append(append([]byte{}, salt...), nonce...), gcm.Seal(nil, nonce, []byte(plain), nil)...)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// keyCache derives each key once, since every value encrypted in one run shares its salt
+type keyCache struct {
+	passphrase string
+	keys       map[string][]byte
+}
+
+func newKeyCache(passphrase string) *keyCache {
+	return &keyCache{passphrase: passphrase, keys: make(map[string][]byte)}
+}
+
+func (k *keyCache) decrypt(value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	if len(data) < saltSize {
+		return "", fmt.Errorf("invalid encrypted value: too short")
+	}
+
+	salt := data[:saltSize]
+	key, ok := k.keys[string(salt)]
+	if !ok {
+		if key, err = deriveKey(k.passphrase, salt); err != nil {
+			return "", err
+		}
+		k.keys[string(salt)] = key
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted value: too short")
+	}
+
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value, wrong passphrase?")
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives the AES key from the passphrase with PBKDF2-HMAC-SHA256
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
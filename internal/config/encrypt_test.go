@@ -0,0 +1,160 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const plainYAML = `# PTP credentials
+apiUser: user
+apiKey: secretkey
+qbittorrent:
+  qbit:
+    url: http://localhost:8080
+    username: admin
+    password: "12345"
+containers:
+  hetzner:
+    size: 5T
+    client: qbit
+    apiKey: ""
+`
+
+func TestEncryptYAMLRoundTrip(t *testing.T) {
+	encrypted, n, err := EncryptYAML([]byte(plainYAML), "hunter22")
+	if err != nil {
+		t.Fatalf("EncryptYAML() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("EncryptYAML() encrypted %d values, want 2", n)
+	}
+	for _, secret := range []string{"secretkey", "12345"} {
+		if strings.Contains(string(encrypted), secret) {
+			t.Errorf("EncryptYAML() left %q in plain text", secret)
+		}
+	}
+	if !strings.Contains(string(encrypted), "# PTP credentials") {
+		t.Error("EncryptYAML() dropped the comments")
+	}
+
+	// values that are already encrypted are left alone
+	again, n, err := EncryptYAML(encrypted, "other")
+	if err != nil || n != 0 || string(again) != string(encrypted) {
+		t.Errorf("EncryptYAML() on an encrypted config = %d values, %v, want unchanged", n, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(encrypted, &cfg); err != nil {
+		t.Fatalf("failed to parse encrypted config: %v", err)
+	}
+	if !cfg.Encrypted() {
+		t.Fatal("Encrypted() = false, want true")
+	}
+	if err := cfg.Decrypt("hunter22"); err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if cfg.ApiKey != "secretkey" || cfg.QBitClients["qbit"].Password != "12345" {
+		t.Errorf("Decrypt() = apiKey %q, password %q", cfg.ApiKey, cfg.QBitClients["qbit"].Password)
+	}
+
+	decrypted, n, err := DecryptYAML(encrypted, "hunter22")
+	if err != nil {
+		t.Fatalf("DecryptYAML() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("DecryptYAML() decrypted %d values, want 2", n)
+	}
+	// the password must stay a string rather than turning into a number
+	if !strings.Contains(string(decrypted), `password: "12345"`) || !strings.Contains(string(decrypted), "apiKey: secretkey") {
+		t.Errorf("DecryptYAML() = %s", decrypted)
+	}
+
+	if _, _, err := DecryptYAML(encrypted, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("DecryptYAML() with the wrong passphrase error = %v", err)
+	}
+}
+
+func TestDecryptValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{
+			// encrypted by an earlier release, so a change to the key derivation can't go unnoticed
+			name:  "existing value",
+			value: "enc:v1:pITVQDJCDA43XdQ2U5/iFG5BkXOWdrXRRPFCwj0nd5vxEJARGydHGehuR9SIOEoVzSemSB4=",
+			want:  "secretkey",
+		},
+		{
+			name:    "not base64",
+			value:   "enc:v1:!!!",
+			wantErr: "invalid encrypted value",
+		},
+		{
+			name:    "shorter than the salt",
+			value:   "enc:v1:AAAA",
+			wantErr: "invalid encrypted value: too short",
+		},
+		{
+			name:    "tampered",
+			value:   "enc:v1:pITVQDJCDA43XdQ2U5/iFG5BkXOWdrXRRPFCwj0nd5vxEJARGydHGehuR9SIPEoVzSemSB4=",
+			wantErr: "wrong passphrase",
+		},
+	}
+
+	keys := newKeyCache("hunter22")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keys.decrypt(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decrypt() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decrypt() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("decrypt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactYAML(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		n     int
+	}{
+		{name: "plain text", value: "secret", want: `""`, n: 1},
+		{name: "number", value: "12345", want: `""`, n: 1},
+		{name: "empty", value: `""`, want: `""`},
+		{name: "encrypted", value: "enc:v1:abc", want: "enc:v1:abc"},
+		{name: "vault reference", value: "vault:secret/ptp#apiKey", want: "vault:secret/ptp#apiKey"},
+		{name: "1password reference", value: "op://vault/ptp/password", want: "op://vault/ptp/password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := "apiUser: user\nqbittorrent:\n  qbit:\n    password: " + tt.value + "\n"
+			got, n, err := RedactYAML([]byte(doc))
+			if err != nil {
+				t.Fatalf("RedactYAML() error = %v", err)
+			}
+			if n != tt.n {
+				t.Errorf("RedactYAML() redacted %d values, want %d", n, tt.n)
+			}
+			want := "apiUser: user\nqbittorrent:\n  qbit:\n    password: " + tt.want + "\n"
+			if string(got) != want {
+				t.Errorf("RedactYAML() = %q, want %q", got, want)
+			}
+		})
+	}
+}
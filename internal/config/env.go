@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ConfiglessEnv enables building the config from environment variables instead of a file
+const ConfiglessEnv = "PTPARCHIVER_CONFIGLESS"
+
+// Configless reports whether the config should be read from the environment
+func Configless() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(ConfiglessEnv))
+	return enabled
+}
+
+// FromEnv assembles a config with a single client and container from PTPARCHIVER_* environment
+// variables, e.g. for Docker deployments without a config file:
+//
+//	PTPARCHIVER_API_USER, PTPARCHIVER_API_KEY, PTPARCHIVER_BASE_URL
+//	PTPARCHIVER_INTERVAL, PTPARCHIVER_FETCH_SLEEP, PTPARCHIVER_USER_AGENT, PTPARCHIVER_TIMEZONE
+//	PTPARCHIVER_CLIENT_TYPE (qbittorrent, rtorrent or deluge), PTPARCHIVER_CLIENT_NAME
+//	PTPARCHIVER_CLIENT_URL (qbittorrent, rtorrent), PTPARCHIVER_CLIENT_HOST, PTPARCHIVER_CLIENT_PORT (deluge)
+//	PTPARCHIVER_CLIENT_USERNAME, PTPARCHIVER_CLIENT_PASSWORD
+//	PTPARCHIVER_CLIENT_BASIC_USER, PTPARCHIVER_CLIENT_BASIC_PASS
+//	PTPARCHIVER_CONTAINER_NAME, PTPARCHIVER_CONTAINER_SIZE, PTPARCHIVER_CONTAINER_MAX_STALLED
+//	PTPARCHIVER_CONTAINER_CATEGORY, PTPARCHIVER_CONTAINER_TAGS (comma separated)
+//	PTPARCHIVER_CONTAINER_STALLED_STATES (comma separated)
+//	PTPARCHIVER_CONTAINER_START_PAUSED, PTPARCHIVER_CONTAINER_WATCH_DIR
+func FromEnv() (*Config, error) {
+	cfg := &Config{
+		ApiUser:       os.Getenv("PTPARCHIVER_API_USER"),
+		ApiKey:        os.Getenv("PTPARCHIVER_API_KEY"),
+		BaseURL:       strings.TrimSuffix(envOr("PTPARCHIVER_BASE_URL", "https://passthepopcorn.me"), "/"),
+		UserAgent:     os.Getenv("PTPARCHIVER_USER_AGENT"),
+		Timezone:      os.Getenv("PTPARCHIVER_TIMEZONE"),
+		QBitClients:   map[string]QBitConfig{},
+		RTorrClients:  map[string]RTorrConfig{},
+		DelugeClients: map[string]DelugeConfig{},
+		Containers:    map[string]Container{},
+	}
+
+	if cfg.ApiUser == "" || cfg.ApiKey == "" {
+		return nil, fmt.Errorf("PTPARCHIVER_API_USER and PTPARCHIVER_API_KEY are required")
+	}
+
+	var err error
+	if cfg.Interval, err = envInt("PTPARCHIVER_INTERVAL", 360); err != nil {
+		return nil, err
+	}
+	if cfg.FetchSleep, err = envInt("PTPARCHIVER_FETCH_SLEEP", 5); err != nil {
+		return nil, err
+	}
+
+	container := Container{
+		Size:     os.Getenv("PTPARCHIVER_CONTAINER_SIZE"),
+		Category: os.Getenv("PTPARCHIVER_CONTAINER_CATEGORY"),
+		WatchDir: os.Getenv("PTPARCHIVER_CONTAINER_WATCH_DIR"),
+	}
+	if container.Size == "" {
+		return nil, fmt.Errorf("PTPARCHIVER_CONTAINER_SIZE is required")
+	}
+	if container.MaxStalled, err = envInt("PTPARCHIVER_CONTAINER_MAX_STALLED", 0); err != nil {
+		return nil, err
+	}
+	container.Tags = envList("PTPARCHIVER_CONTAINER_TAGS")
+	container.StalledStates = envList("PTPARCHIVER_CONTAINER_STALLED_STATES")
+	if v := os.Getenv("PTPARCHIVER_CONTAINER_START_PAUSED"); v != "" {
+		if container.StartPaused, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("PTPARCHIVER_CONTAINER_START_PAUSED: invalid boolean %q", v)
+		}
+	}
+
+	if container.WatchDir == "" {
+		clientName := envOr("PTPARCHIVER_CLIENT_NAME", "default")
+		if err := clientFromEnv(cfg, clientName); err != nil {
+			return nil, err
+		}
+		container.Client = clientName
+	}
+
+	cfg.Containers[envOr("PTPARCHIVER_CONTAINER_NAME", "default")] = container
+	return cfg, nil
+}
+
+func clientFromEnv(cfg *Config, name string) error {
+	clientType := strings.ToLower(os.Getenv("PTPARCHIVER_CLIENT_TYPE"))
+	url := os.Getenv("PTPARCHIVER_CLIENT_URL")
+	username := os.Getenv("PTPARCHIVER_CLIENT_USERNAME")
+	password := os.Getenv("PTPARCHIVER_CLIENT_PASSWORD")
+	basicUser := os.Getenv("PTPARCHIVER_CLIENT_BASIC_USER")
+	basicPass := os.Getenv("PTPARCHIVER_CLIENT_BASIC_PASS")
+
+	switch clientType {
+	case "qbittorrent", "qbit":
+		if url == "" {
+			return fmt.Errorf("PTPARCHIVER_CLIENT_URL is required for qbittorrent")
+		}
+		cfg.QBitClients[name] = QBitConfig{
+			URL:       url,
+			Username:  username,
+			Password:  password,
+			BasicUser: basicUser,
+			BasicPass: basicPass,
+		}
+	case "rtorrent":
+		if url == "" {
+			return fmt.Errorf("PTPARCHIVER_CLIENT_URL is required for rtorrent")
+		}
+		cfg.RTorrClients[name] = RTorrConfig{
+			URL:       url,
+			BasicUser: basicUser,
+			BasicPass: basicPass,
+		}
+	case "deluge":
+		port, err := envInt("PTPARCHIVER_CLIENT_PORT", 58846)
+		if err != nil {
+			return err
+		}
+		cfg.DelugeClients[name] = DelugeConfig{
+			Host:      envOr("PTPARCHIVER_CLIENT_HOST", "localhost"),
+			Port:      port,
+			Username:  username,
+			Password:  password,
+			BasicUser: basicUser,
+			BasicPass: basicPass,
+		}
+	case "":
+		return fmt.Errorf("PTPARCHIVER_CLIENT_TYPE or PTPARCHIVER_CONTAINER_WATCH_DIR is required")
+	default:
+		return fmt.Errorf("unsupported PTPARCHIVER_CLIENT_TYPE %q", clientType)
+	}
+
+	return nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid number %q", key, v)
+	}
+	return n, nil
+}
+
+// envList returns the comma separated values of the environment variable, skipping empty entries
+func envList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,52 @@
+package config
+
+import "fmt"
+
+// FreeSpaceMode decides how the free space of an rTorrent client is found, since rTorrent can't report it
+type FreeSpaceMode string
+
+const (
+	// FreeSpaceNone skips the free space check, the default
+	FreeSpaceNone FreeSpaceMode = "none"
+	// FreeSpaceLocal checks the filesystems holding the free space paths on this host, for rTorrent running on the same machine
+	FreeSpaceLocal FreeSpaceMode = "local"
+)
+
+// FreeSpaceAggregate decides how the free space of several paths is combined
+type FreeSpaceAggregate string
+
+const (
+	// FreeSpaceMax uses the path with the most free space, the default
+	FreeSpaceMax FreeSpaceAggregate = "max"
+	// FreeSpaceSum adds up the free space of every filesystem, for clients that spread a torrent's files over them
+	FreeSpaceSum FreeSpaceAggregate = "sum"
+)
+
+// FreeSpacePathList returns freeSpacePath followed by freeSpacePaths
+func (c RTorrConfig) FreeSpacePathList() []string {
+	var paths []string
+	if c.FreeSpacePath != "" {
+		paths = append(paths, c.FreeSpacePath)
+	}
+	return append(paths, c.FreeSpacePaths...)
+}
+
+// ValidateFreeSpace checks the free space mode and aggregate, and that local mode has a path
+func (c RTorrConfig) ValidateFreeSpace() error {
+	switch c.FreeSpaceAggregate {
+	case "", FreeSpaceMax, FreeSpaceSum:
+	default:
+		return fmt.Errorf("invalid freeSpaceAggregate %q, must be max or sum", string(c.FreeSpaceAggregate))
+	}
+
+	switch c.FreeSpaceMode {
+	case "", FreeSpaceNone:
+		return nil
+	case FreeSpaceLocal:
+		if len(c.FreeSpacePathList()) == 0 {
+			return fmt.Errorf("freeSpaceMode local requires freeSpacePath or freeSpacePaths")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid freeSpaceMode %q, must be none or local", string(c.FreeSpaceMode))
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Influx configures pushing metrics in InfluxDB line protocol after every fetch cycle, to InfluxDB 1.x or 2.x
+// or anything else accepting line protocol writes such as VictoriaMetrics
+type Influx struct {
+	// URL is the base URL of the server, e.g. http://localhost:8086. Metrics are only written when it is set
+	URL string `yaml:"url,omitempty"`
+	// Org, Bucket and Token write through the InfluxDB 2.x API
+	Org    string `yaml:"org,omitempty"`
+	Bucket string `yaml:"bucket,omitempty"`
+	Token  string `yaml:"token,omitempty"`
+	// Database, Username and Password write through the 1.x API, which VictoriaMetrics also serves
+	Database string `yaml:"database,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// Enabled reports whether metrics should be written
+func (i Influx) Enabled() bool {
+	return i.URL != ""
+}
+
+// WriteURL returns the endpoint line protocol is posted to, the 2.x API when a bucket is set and 1.x otherwise
+func (i Influx) WriteURL() (string, error) {
+	base, err := url.Parse(strings.TrimRight(i.URL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid influx url: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return "", fmt.Errorf("invalid influx url %q: scheme must be http or https", i.URL)
+	}
+
+	q := url.Values{"precision": {"s"}}
+	if i.Bucket != "" {
+		base.Path += "/api/v2/write"
+		q.Set("bucket", i.Bucket)
+		if i.Org != "" {
+			q.Set("org", i.Org)
+		}
+	} else {
+		base.Path += "/write"
+		if i.Database != "" {
+			q.Set("db", i.Database)
+		}
+	}
+	base.RawQuery = q.Encode()
+	return base.String(), nil
+}
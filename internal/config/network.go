@@ -0,0 +1,24 @@
+package config
+
+import "fmt"
+
+// IPFamily selects which IP versions are used for outgoing connections
+type IPFamily string
+
+const (
+	// IPFamilyAny leaves the choice to the operating system
+	IPFamilyAny        IPFamily = ""
+	IPFamilyIPv4       IPFamily = "ipv4"
+	IPFamilyIPv6       IPFamily = "ipv6"
+	IPFamilyPreferIPv4 IPFamily = "prefer-ipv4"
+	IPFamilyPreferIPv6 IPFamily = "prefer-ipv6"
+)
+
+// Validate returns an error for unknown IP families
+func (f IPFamily) Validate() error {
+	switch f {
+	case IPFamilyAny, IPFamilyIPv4, IPFamilyIPv6, IPFamilyPreferIPv4, IPFamilyPreferIPv6:
+		return nil
+	}
+	return fmt.Errorf("invalid ipFamily %q, must be one of ipv4, ipv6, prefer-ipv4 or prefer-ipv6", string(f))
+}
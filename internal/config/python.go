@@ -0,0 +1,232 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// iniSection holds the lower-cased key/value pairs of a single INI section
+type iniSection map[string]string
+
+// ImportPython converts a config.ini from the official Python archiver into a Config.
+//
+// The Python script reads a configparser style file where credentials live in the
+// [PTP] (or [main]) section, torrent clients are sections with a Type key and every
+// section with a Size key is a container, e.g.
+//
+//	[PTP]
+//	ApiUser = xxx
+//	ApiKey = xxx
+//
+//	[qbit1]
+//	Type = qbittorrent
+//	Url = http://localhost:8080
+//
+//	[hetzner]
+//	Size = 5T
+//	MaxStalled = 5
+//	Client = qbit1
+func ImportPython(r io.Reader) (*Config, error) {
+	sections, order, err := parseINI(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		BaseURL:       "https://passthepopcorn.me",
+		QBitClients:   map[string]QBitConfig{},
+		RTorrClients:  map[string]RTorrConfig{},
+		DelugeClients: map[string]DelugeConfig{},
+		Containers:    map[string]Container{},
+		FetchSleep:    5,
+		Interval:      360,
+	}
+
+	for _, name := range order {
+		section := sections[name]
+
+		switch strings.ToLower(name) {
+		case "ptp", "main", "default":
+			cfg.ApiUser = section.get("apiuser", cfg.ApiUser)
+			cfg.ApiKey = section.get("apikey", cfg.ApiKey)
+			cfg.BaseURL = strings.TrimSuffix(section.get("baseurl", cfg.BaseURL), "/")
+			if cfg.FetchSleep, err = section.getInt("fetchsleep", cfg.FetchSleep); err != nil {
+				return nil, fmt.Errorf("section %s: %w", name, err)
+			}
+			if cfg.Interval, err = section.getInt("interval", cfg.Interval); err != nil {
+				return nil, fmt.Errorf("section %s: %w", name, err)
+			}
+			continue
+		}
+
+		if clientType, ok := section["type"]; ok {
+			if err := importPythonClient(cfg, name, strings.ToLower(clientType), section); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if _, ok := section["size"]; ok {
+			container, err := importPythonContainer(section)
+			if err != nil {
+				return nil, fmt.Errorf("container %s: %w", name, err)
+			}
+			cfg.Containers[name] = container
+			continue
+		}
+
+		return nil, fmt.Errorf("section %s is neither a client nor a container", name)
+	}
+
+	return cfg, nil
+}
+
+func importPythonClient(cfg *Config, name, clientType string, section iniSection) error {
+	switch clientType {
+	case "qbittorrent", "qbit":
+		cfg.QBitClients[name] = QBitConfig{
+			URL:       section.get("url", ""),
+			Username:  section.get("username", ""),
+			Password:  section.get("password", ""),
+			BasicUser: section.get("basicuser", ""),
+			BasicPass: section.get("basicpass", ""),
+		}
+	case "rtorrent", "rutorrent":
+		cfg.RTorrClients[name] = RTorrConfig{
+			URL:       section.get("url", ""),
+			BasicUser: section.get("basicuser", ""),
+			BasicPass: section.get("basicpass", ""),
+		}
+	case "deluge":
+		port, err := section.getInt("port", 58846)
+		if err != nil {
+			return fmt.Errorf("client %s: %w", name, err)
+		}
+		cfg.DelugeClients[name] = DelugeConfig{
+			Host:      section.get("host", "localhost"),
+			Port:      port,
+			Username:  section.get("username", ""),
+			Password:  section.get("password", ""),
+			BasicUser: section.get("basicuser", ""),
+			BasicPass: section.get("basicpass", ""),
+		}
+	default:
+		return fmt.Errorf("client %s has unsupported type %q", name, clientType)
+	}
+
+	return nil
+}
+
+func importPythonContainer(section iniSection) (Container, error) {
+	maxStalled, err := section.getInt("maxstalled", 0)
+	if err != nil {
+		return Container{}, err
+	}
+
+	startPaused, err := section.getBool("startpaused", false)
+	if err != nil {
+		return Container{}, err
+	}
+
+	container := Container{
+		Size:        section.get("size", ""),
+		MaxStalled:  maxStalled,
+		Category:    section.get("category", ""),
+		Client:      section.get("client", ""),
+		WatchDir:    section.get("watchdirectory", section.get("watchdir", "")),
+		StartPaused: startPaused,
+	}
+
+	if tags := section.get("tags", ""); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				container.Tags = append(container.Tags, tag)
+			}
+		}
+	}
+
+	return container, nil
+}
+
+// parseINI reads a configparser compatible file, returning sections keyed by their
+// original name along with the order they appeared in
+func parseINI(r io.Reader) (map[string]iniSection, []string, error) {
+	sections := make(map[string]iniSection)
+	var order []string
+	var current iniSection
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if _, exists := sections[name]; !exists {
+				sections[name] = iniSection{}
+				order = append(order, name)
+			}
+			current = sections[name]
+			continue
+		}
+
+		if current == nil {
+			return nil, nil, fmt.Errorf("line %d: key outside of a section", lineNum)
+		}
+
+		idx := strings.IndexAny(line, "=:")
+		if idx < 0 {
+			return nil, nil, fmt.Errorf("line %d: expected key = value", lineNum)
+		}
+
+		key := strings.ToLower(strings.TrimSpace(line[:idx]))
+		value := strings.Trim(strings.TrimSpace(line[idx+1:]), `"'`)
+		current[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return sections, order, nil
+}
+
+func (s iniSection) get(key, fallback string) string {
+	if v, ok := s[key]; ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+func (s iniSection) getInt(key string, fallback int) (int, error) {
+	v, ok := s[key]
+	if !ok || v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer for %s: %q", key, v)
+	}
+	return n, nil
+}
+
+func (s iniSection) getBool(key string, fallback bool) (bool, error) {
+	v, ok := s[key]
+	if !ok || v == "" {
+		return fallback, nil
+	}
+	switch strings.ToLower(v) {
+	case "1", "yes", "true", "on":
+		return true, nil
+	case "0", "no", "false", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean for %s: %q", key, v)
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportPython(t *testing.T) {
+	tests := []struct {
+		name    string
+		ini     string
+		want    *Config
+		wantErr string
+	}{
+		{
+			name: "credentials, clients and containers",
+			ini: `
+# exported from the python archiver
+[PTP]
+ApiUser = user
+ApiKey = "key"
+BaseURL = https://ptp.example/
+FetchSleep = 10
+
+[qbit1]
+Type = qBittorrent
+Url = http://localhost:8080
+Username = admin
+Password = secret
+
+[rt]
+Type = rtorrent
+Url = http://localhost/RPC2
+
+[deluge]
+Type = deluge
+Host = seedbox
+Username = dl
+
+[hetzner]
+Size = 5T
+MaxStalled = 5
+Client = qbit1
+Category = ptp-archive
+Tags = archive, ptp ,
+StartPaused = yes
+
+[watch]
+Size: 100G
+WatchDirectory = /srv/watch
+`,
+			want: &Config{
+				ApiUser:    "user",
+				ApiKey:     "key",
+				BaseURL:    "https://ptp.example",
+				FetchSleep: 10,
+				Interval:   360,
+				QBitClients: map[string]QBitConfig{
+					"qbit1": {URL: "http://localhost:8080", Username: "admin", Password: "secret"},
+				},
+				RTorrClients: map[string]RTorrConfig{
+					"rt": {URL: "http://localhost/RPC2"},
+				},
+				DelugeClients: map[string]DelugeConfig{
+					"deluge": {Host: "seedbox", Port: 58846, Username: "dl"},
+				},
+				Containers: map[string]Container{
+					"hetzner": {
+						Size:        "5T",
+						MaxStalled:  5,
+						Client:      "qbit1",
+						Category:    "ptp-archive",
+						Tags:        []string{"archive", "ptp"},
+						StartPaused: true,
+					},
+					"watch": {Size: "100G", WatchDir: "/srv/watch"},
+				},
+			},
+		},
+		{
+			name: "defaults",
+			ini:  "[main]\napiuser = user\n",
+			want: &Config{
+				ApiUser:       "user",
+				BaseURL:       "https://passthepopcorn.me",
+				FetchSleep:    5,
+				Interval:      360,
+				QBitClients:   map[string]QBitConfig{},
+				RTorrClients:  map[string]RTorrConfig{},
+				DelugeClients: map[string]DelugeConfig{},
+				Containers:    map[string]Container{},
+			},
+		},
+		{
+			name:    "key outside of a section",
+			ini:     "apiuser = user\n",
+			wantErr: "line 1: key outside of a section",
+		},
+		{
+			name:    "line without a value",
+			ini:     "[PTP]\napiuser\n",
+			wantErr: "line 2: expected key = value",
+		},
+		{
+			name:    "unsupported client",
+			ini:     "[tr]\ntype = transmission\n",
+			wantErr: `client tr has unsupported type "transmission"`,
+		},
+		{
+			name:    "section that is neither",
+			ini:     "[misc]\nfoo = bar\n",
+			wantErr: "section misc is neither a client nor a container",
+		},
+		{
+			name:    "invalid integer",
+			ini:     "[c]\nsize = 1T\nmaxstalled = many\n",
+			wantErr: `container c: invalid integer for maxstalled: "many"`,
+		},
+		{
+			name:    "invalid boolean",
+			ini:     "[c]\nsize = 1T\nstartpaused = maybe\n",
+			wantErr: `container c: invalid boolean for startpaused: "maybe"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ImportPython(strings.NewReader(tt.ini))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ImportPython() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ImportPython() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ImportPython() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Prefixes of credentials that reference a secret manager instead of holding the value
+const (
+	VaultPrefix       = "vault:"
+	OnePasswordPrefix = "op://"
+)
+
+const secretTimeout = 30 * time.Second
+
+// ResolveSecrets replaces secret manager references in the credentials with the values they point to.
+// vault:<path>#<key> is read from HashiCorp Vault using VAULT_ADDR and VAULT_TOKEN (and VAULT_NAMESPACE),
+// op://<vault>/<item>/<field> from 1Password Connect using OP_CONNECT_HOST and OP_CONNECT_TOKEN.
+func (c *Config) ResolveSecrets(ctx context.Context) error {
+	r := &secretResolver{http: &http.Client{Timeout: secretTimeout}}
+	resolve := func(v *string) error {
+		var err error
+		switch {
+		case strings.HasPrefix(*v, VaultPrefix):
+			*v, err = r.vault(ctx, strings.TrimPrefix(*v, VaultPrefix))
+		case strings.HasPrefix(*v, OnePasswordPrefix):
+			*v, err = r.onePassword(ctx, strings.TrimPrefix(*v, OnePasswordPrefix))
+		}
+		return err
+	}
+
+	if err := resolve(&c.ApiUser); err != nil {
+		return fmt.Errorf("apiUser: %w", err)
+	}
+	for name, container := range c.Containers {
+		if err := resolve(&container.ApiUser); err != nil {
+			return fmt.Errorf("container %s apiUser: %w", name, err)
+		}
+		c.Containers[name] = container
+	}
+	// unlike passwords the user names aren't encrypted, but may be kept in a secret manager alongside them
+	for name, qbit := range c.QBitClients {
+		if err := eachString(resolve, &qbit.Username, &qbit.BasicUser); err != nil {
+			return fmt.Errorf("qbittorrent %s: %w", name, err)
+		}
+		c.QBitClients[name] = qbit
+	}
+	for name, rtorr := range c.RTorrClients {
+		if err := resolve(&rtorr.BasicUser); err != nil {
+			return fmt.Errorf("rtorrent %s basicUser: %w", name, err)
+		}
+		c.RTorrClients[name] = rtorr
+	}
+	for name, deluge := range c.DelugeClients {
+		if err := eachString(resolve, &deluge.Username, &deluge.BasicUser); err != nil {
+			return fmt.Errorf("deluge %s: %w", name, err)
+		}
+		c.DelugeClients[name] = deluge
+	}
+	return c.eachSecret(resolve)
+}
+
+type secretResolver struct {
+	http *http.Client
+}
+
+// vault reads a key from a KV secret, trying the KV version 2 API before version 1
+func (r *secretResolver) vault(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("invalid vault reference %q, must be vault:<path>#<key>", ref)
+	}
+
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set to resolve vault references")
+	}
+
+	headers := map[string]string{"X-Vault-Token": token}
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		headers["X-Vault-Namespace"] = ns
+	}
+
+	mount, rest, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	var v2 struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	status, err := r.getJSON(ctx, strings.TrimSuffix(addr, "/")+"/v1/"+mount+"/data/"+rest, headers, &v2)
+	if err != nil && status != http.StatusNotFound {
+		return "", fmt.Errorf("failed to read vault secret %s: %w", path, err)
+	}
+	if err == nil {
+		return secretKey(v2.Data.Data, key, path)
+	}
+
+	var v1 struct {
+		Data map[string]any `json:"data"`
+	}
+	if _, err := r.getJSON(ctx, strings.TrimSuffix(addr, "/")+"/v1/"+strings.Trim(path, "/"), headers, &v1); err != nil {
+		return "", fmt.Errorf("failed to read vault secret %s: %w", path, err)
+	}
+	return secretKey(v1.Data, key, path)
+}
+
+func secretKey(data map[string]any, key, path string) (string, error) {
+	v, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// onePassword reads a field of an item through 1Password Connect, looking up vault and item by name
+func (r *secretResolver) onePassword(ctx context.Context, ref string) (string, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("invalid 1Password reference %q, must be op://<vault>/<item>/<field>", OnePasswordPrefix+ref)
+	}
+	vaultName, itemName, fieldName := parts[0], parts[1], parts[2]
+
+	host, token := os.Getenv("OP_CONNECT_HOST"), os.Getenv("OP_CONNECT_TOKEN")
+	if host == "" || token == "" {
+		return "", fmt.Errorf("OP_CONNECT_HOST and OP_CONNECT_TOKEN must be set to resolve 1Password references")
+	}
+	base := strings.TrimSuffix(host, "/") + "/v1"
+	headers := map[string]string{"Authorization": "Bearer " + token}
+
+	var vaults []struct {
+		ID string `json:"id"`
+	}
+	filter := url.QueryEscape(fmt.Sprintf("name eq %q", vaultName))
+	if _, err := r.getJSON(ctx, base+"/vaults?filter="+filter, headers, &vaults); err != nil {
+		return "", fmt.Errorf("failed to look up 1Password vault %s: %w", vaultName, err)
+	}
+	if len(vaults) == 0 {
+		return "", fmt.Errorf("1Password vault %s not found", vaultName)
+	}
+
+	var items []struct {
+		ID string `json:"id"`
+	}
+	filter = url.QueryEscape(fmt.Sprintf("title eq %q", itemName))
+	if _, err := r.getJSON(ctx, base+"/vaults/"+vaults[0].ID+"/items?filter="+filter, headers, &items); err != nil {
+		return "", fmt.Errorf("failed to look up 1Password item %s: %w", itemName, err)
+	}
+	if len(items) == 0 {
+		return "", fmt.Errorf("1Password item %s not found in vault %s", itemName, vaultName)
+	}
+
+	var item struct {
+		Fields []struct {
+			ID    string `json:"id"`
+			Label string `json:"label"`
+			Value string `json:"value"`
+		} `json:"fields"`
+	}
+	if _, err := r.getJSON(ctx, base+"/vaults/"+vaults[0].ID+"/items/"+items[0].ID, headers, &item); err != nil {
+		return "", fmt.Errorf("failed to read 1Password item %s: %w", itemName, err)
+	}
+
+	for _, field := range item.Fields {
+		if field.Label == fieldName || field.ID == fieldName {
+			return field.Value, nil
+		}
+	}
+	return "", fmt.Errorf("1Password item %s has no field %s", itemName, fieldName)
+}
+
+// getJSON decodes the JSON response of a GET request, returning the status code along with any error
+func (r *secretResolver) getJSON(ctx context.Context, u string, headers map[string]string, v any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	for k, value := range headers {
+		req.Header.Set(k, value)
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/ptp" || r.Header.Get("X-Vault-Token") != "token" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"user":"admin","pass":"hunter22"}}}`))
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	const user, pass = "vault:secret/ptp#user", "vault:secret/ptp#pass"
+	cfg := &Config{
+		ApiUser:      user,
+		ApiKey:       pass,
+		QBitClients:  map[string]QBitConfig{"qbit": {Username: user, Password: pass, BasicUser: user, BasicPass: pass}},
+		RTorrClients: map[string]RTorrConfig{"rt": {BasicUser: user, BasicPass: pass}},
+		DelugeClients: map[string]DelugeConfig{
+			"deluge": {Username: user, Password: pass, BasicUser: user, BasicPass: pass},
+		},
+		Containers: map[string]Container{"hetzner": {ApiUser: user, ApiKey: pass}},
+	}
+	if err := cfg.ResolveSecrets(context.Background()); err != nil {
+		t.Fatalf("ResolveSecrets() error = %v", err)
+	}
+
+	qbit, rtorr, deluge, container := cfg.QBitClients["qbit"], cfg.RTorrClients["rt"], cfg.DelugeClients["deluge"], cfg.Containers["hetzner"]
+	for field, got := range map[string]string{
+		"apiUser":               cfg.ApiUser,
+		"qbittorrent username":  qbit.Username,
+		"qbittorrent basicUser": qbit.BasicUser,
+		"rtorrent basicUser":    rtorr.BasicUser,
+		"deluge username":       deluge.Username,
+		"deluge basicUser":      deluge.BasicUser,
+		"container apiUser":     container.ApiUser,
+	} {
+		if got != "admin" {
+			t.Errorf("%s = %q, want %q", field, got, "admin")
+		}
+	}
+	for field, got := range map[string]string{
+		"apiKey":                cfg.ApiKey,
+		"qbittorrent password":  qbit.Password,
+		"qbittorrent basicPass": qbit.BasicPass,
+		"rtorrent basicPass":    rtorr.BasicPass,
+		"deluge password":       deluge.Password,
+		"deluge basicPass":      deluge.BasicPass,
+		"container apiKey":      container.ApiKey,
+	} {
+		if got != "hunter22" {
+			t.Errorf("%s = %q, want %q", field, got, "hunter22")
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/docker/go-units"
+)
+
+// ParseSize converts a container size such as "5T" or "500G" to bytes, using binary units
+func ParseSize(size string) (int64, error) {
+	bytes, err := units.RAMInBytes(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", size, err)
+	}
+	return bytes, nil
+}
+
+// SpeedLimits returns the container's download and upload limits in bytes per second, 0 is unlimited
+func (c Container) SpeedLimits() (download, upload int64, err error) {
+	if c.DownloadLimit != "" {
+		if download, err = ParseSize(c.DownloadLimit); err != nil {
+			return 0, 0, fmt.Errorf("downloadLimit: %w", err)
+		}
+	}
+	if c.UploadLimit != "" {
+		if upload, err = ParseSize(c.UploadLimit); err != nil {
+			return 0, 0, fmt.Errorf("uploadLimit: %w", err)
+		}
+	}
+	return download, upload, nil
+}
+
+// TorrentSizeLimits returns the smallest and largest torrent the container adds in bytes, 0 is unlimited
+func (c Container) TorrentSizeLimits() (min, max int64, err error) {
+	if c.MinTorrentSize != "" {
+		if min, err = ParseSize(c.MinTorrentSize); err != nil {
+			return 0, 0, fmt.Errorf("minTorrentSize: %w", err)
+		}
+	}
+	if c.MaxTorrentSize != "" {
+		if max, err = ParseSize(c.MaxTorrentSize); err != nil {
+			return 0, 0, fmt.Errorf("maxTorrentSize: %w", err)
+		}
+	}
+	if min > 0 && max > 0 && min > max {
+		return 0, 0, fmt.Errorf("minTorrentSize %s is larger than maxTorrentSize %s", c.MinTorrentSize, c.MaxTorrentSize)
+	}
+	return min, max, nil
+}
+
+// DefaultMaxTorrentFileSize is the largest .torrent file accepted when maxTorrentFileSize isn't set
+const DefaultMaxTorrentFileSize = 10 * units.MiB
+
+// MaxTorrentFileBytes returns the configured maximum .torrent file size in bytes
+func (c *Config) MaxTorrentFileBytes() (int64, error) {
+	if c.MaxTorrentFileSize == "" {
+		return DefaultMaxTorrentFileSize, nil
+	}
+
+	size, err := ParseSize(c.MaxTorrentFileSize)
+	if err != nil {
+		return 0, fmt.Errorf("maxTorrentFileSize: %w", err)
+	}
+	return size, nil
+}
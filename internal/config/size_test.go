@@ -0,0 +1,80 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{size: "0", want: 0},
+		{size: "512", want: 512},
+		{size: "1K", want: 1 << 10},
+		{size: "1kb", want: 1 << 10},
+		{size: "100M", want: 100 << 20},
+		{size: "1.5G", want: 3 << 29},
+		{size: "5T", want: 5 << 40},
+		{size: "5 TiB", want: 5 << 40},
+		{size: "", wantErr: true},
+		{size: "big", wantErr: true},
+		{size: "-1G", wantErr: true},
+		{size: "5X", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			got, err := ParseSize(tt.size)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseSize(%q) = %d, want an error", tt.size, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSize(%q) error = %v", tt.size, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpeedLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		container    Container
+		wantDownload int64
+		wantUpload   int64
+		wantErr      string
+	}{
+		{name: "unlimited"},
+		{name: "both", container: Container{DownloadLimit: "10M", UploadLimit: "512K"}, wantDownload: 10 << 20, wantUpload: 512 << 10},
+		{name: "download only", container: Container{DownloadLimit: "1G"}, wantDownload: 1 << 30},
+		{name: "upload only", container: Container{UploadLimit: "2M"}, wantUpload: 2 << 20},
+		{name: "invalid download", container: Container{DownloadLimit: "fast"}, wantErr: "downloadLimit: "},
+		{name: "invalid upload", container: Container{DownloadLimit: "1M", UploadLimit: "-1M"}, wantErr: "uploadLimit: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			download, upload, err := tt.container.SpeedLimits()
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("SpeedLimits() error = %v, want prefix %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SpeedLimits() error = %v", err)
+			}
+			if download != tt.wantDownload || upload != tt.wantUpload {
+				t.Errorf("SpeedLimits() = %d, %d, want %d, %d", download, upload, tt.wantDownload, tt.wantUpload)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Statsd configures sending counters and timers of fetches and adds to a statsd or DogStatsD agent over UDP
+type Statsd struct {
+	// Address is the host:port of the agent, e.g. 127.0.0.1:8125. Metrics are only sent when it is set
+	Address string `yaml:"address,omitempty"`
+	// Prefix is prepended to every metric name, defaults to ptparchiver
+	Prefix string `yaml:"prefix,omitempty"`
+	// DogStatsD sends container, client and result as DogStatsD tags instead of adding them to the metric name
+	DogStatsD bool `yaml:"dogstatsd,omitempty"`
+	// Tags such as env:prod are added to every metric, only with DogStatsD
+	Tags []string `yaml:"tags,omitempty"`
+}
+
+// Enabled reports whether metrics should be sent
+func (s Statsd) Enabled() bool {
+	return s.Address != ""
+}
+
+// Validate checks that the address is a host and port
+func (s Statsd) Validate() error {
+	if _, _, err := net.SplitHostPort(s.Address); err != nil {
+		return fmt.Errorf("invalid statsd address %q: %w", s.Address, err)
+	}
+	return nil
+}
+
+// PrefixOrDefault returns the metric name prefix without a trailing dot
+func (s Statsd) PrefixOrDefault() string {
+	if s.Prefix == "" {
+		return "ptparchiver"
+	}
+	return strings.TrimSuffix(s.Prefix, ".")
+}
@@ -0,0 +1,41 @@
+package config
+
+import "time"
+
+// Timeouts configures how long to wait on a remote, in seconds. Zero values use the defaults.
+type Timeouts struct {
+	// Connect limits how long establishing the TCP connection may take
+	Connect int `yaml:"connect,omitempty"`
+	// TLS limits how long the TLS handshake may take
+	TLS int `yaml:"tls,omitempty"`
+	// Request limits the whole request, including reading the response body
+	Request int `yaml:"request,omitempty"`
+}
+
+const (
+	DefaultConnectTimeout = 30 * time.Second
+	DefaultTLSTimeout     = 10 * time.Second
+	DefaultRequestTimeout = 60 * time.Second
+)
+
+// ConnectTimeout returns the configured connect timeout or the default
+func (t Timeouts) ConnectTimeout() time.Duration {
+	return seconds(t.Connect, DefaultConnectTimeout)
+}
+
+// TLSTimeout returns the configured TLS handshake timeout or the default
+func (t Timeouts) TLSTimeout() time.Duration {
+	return seconds(t.TLS, DefaultTLSTimeout)
+}
+
+// RequestTimeout returns the configured request timeout or the default
+func (t Timeouts) RequestTimeout() time.Duration {
+	return seconds(t.Request, DefaultRequestTimeout)
+}
+
+func seconds(n int, def time.Duration) time.Duration {
+	if n <= 0 {
+		return def
+	}
+	return time.Duration(n) * time.Second
+}
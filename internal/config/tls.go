@@ -0,0 +1,57 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLS configures the certificates used for HTTPS connections to a torrent client, such as a client
+// certificate for a reverse proxy that requires mutual TLS
+type TLS struct {
+	// CertFile and KeyFile are the PEM encoded client certificate and its private key
+	CertFile string `yaml:"certFile,omitempty"`
+	KeyFile  string `yaml:"keyFile,omitempty"`
+	// CAFile verifies the server against this PEM encoded CA instead of the system roots
+	CAFile string `yaml:"caFile,omitempty"`
+}
+
+// Enabled reports whether any certificate is configured
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.CAFile != ""
+}
+
+// Config loads the certificates, returning nil if none are configured
+func (t TLS) Config() (*tls.Config, error) {
+	if !t.Enabled() {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, fmt.Errorf("tls certFile and keyFile must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
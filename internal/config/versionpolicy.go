@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// VersionPolicy decides what happens when PTP reports a newer official Python script version
+type VersionPolicy string
+
+const (
+	// VersionPolicyWarn logs a warning and keeps fetching, the default
+	VersionPolicyWarn VersionPolicy = "warn"
+	// VersionPolicyFail stops fetching until ptparchiver-go is updated
+	VersionPolicyFail VersionPolicy = "fail"
+	// VersionPolicyIgnore keeps fetching without logging anything
+	VersionPolicyIgnore VersionPolicy = "ignore"
+)
+
+// Validate returns an error for unknown version policies
+func (p VersionPolicy) Validate() error {
+	switch p {
+	case "", VersionPolicyWarn, VersionPolicyFail, VersionPolicyIgnore:
+		return nil
+	}
+	return fmt.Errorf("invalid versionPolicy %q, must be one of fail, warn or ignore", string(p))
+}
+
+// OrDefault returns the policy, or VersionPolicyWarn if none is set
+func (p VersionPolicy) OrDefault() VersionPolicy {
+	if p == "" {
+		return VersionPolicyWarn
+	}
+	return p
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// WatchDirSelect decides which of a container's watch directories a torrent is saved to
+type WatchDirSelect string
+
+const (
+	// WatchDirMostFree picks the directory on the filesystem with the most free space
+	WatchDirMostFree   WatchDirSelect = "free-space"
+	WatchDirRoundRobin WatchDirSelect = "round-robin"
+)
+
+// Validate returns an error for unknown selection modes
+func (s WatchDirSelect) Validate() error {
+	switch s {
+	case "", WatchDirMostFree, WatchDirRoundRobin:
+		return nil
+	}
+	return fmt.Errorf("invalid watchDirSelect %q, must be free-space or round-robin", string(s))
+}
+
+// WatchDirectories returns watchDir followed by watchDirs, or nil if the container adds to a client
+func (c Container) WatchDirectories() []string {
+	var dirs []string
+	if c.WatchDir != "" {
+		dirs = append(dirs, c.WatchDir)
+	}
+	return append(dirs, c.WatchDirs...)
+}
+
+// UsesWatchDir reports whether torrents are saved to watch directories instead of added to a client
+func (c Container) UsesWatchDir() bool {
+	return c.WatchDir != "" || len(c.WatchDirs) > 0
+}
+
+// WatchDirPerms are the permissions and owner given to watch directories and the files saved to them
+type WatchDirPerms struct {
+	// FileMode and DirMode are 0 when unset
+	FileMode os.FileMode
+	DirMode  os.FileMode
+	// UID and GID are -1 when unset
+	UID int
+	GID int
+}
+
+// WatchDirPermissions parses the container's fileMode, dirMode and chown settings
+func (c Container) WatchDirPermissions() (WatchDirPerms, error) {
+	perms := WatchDirPerms{UID: -1, GID: -1}
+
+	var err error
+	if perms.FileMode, err = parseMode(c.FileMode); err != nil {
+		return perms, fmt.Errorf("fileMode: %w", err)
+	}
+	if perms.DirMode, err = parseMode(c.DirMode); err != nil {
+		return perms, fmt.Errorf("dirMode: %w", err)
+	}
+	if perms.UID, perms.GID, err = parseOwner(c.Chown); err != nil {
+		return perms, fmt.Errorf("chown: %w", err)
+	}
+	return perms, nil
+}
+
+// parseMode parses an octal permission such as 0640
+func parseMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || n > 0o777 {
+		return 0, fmt.Errorf("invalid mode %q, must be octal permissions such as 0640", mode)
+	}
+	return os.FileMode(n), nil
+}
+
+// parseOwner parses user:group, where either may be a name or numeric ID and either may be left out
+func parseOwner(owner string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if owner == "" {
+		return uid, gid, nil
+	}
+
+	userName, groupName, _ := strings.Cut(owner, ":")
+	if userName != "" {
+		if uid, err = strconv.Atoi(userName); err != nil {
+			u, lookupErr := user.Lookup(userName)
+			if lookupErr != nil {
+				return -1, -1, fmt.Errorf("unknown user %q: %w", userName, lookupErr)
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return -1, -1, fmt.Errorf("user %q has no numeric ID", userName)
+			}
+		}
+	}
+	if groupName != "" {
+		if gid, err = strconv.Atoi(groupName); err != nil {
+			g, lookupErr := user.LookupGroup(groupName)
+			if lookupErr != nil {
+				return -1, -1, fmt.Errorf("unknown group %q: %w", groupName, lookupErr)
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return -1, -1, fmt.Errorf("group %q has no numeric ID", groupName)
+			}
+		}
+	}
+	return uid, gid, nil
+}
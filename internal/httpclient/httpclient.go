@@ -0,0 +1,207 @@
+// Package httpclient builds the HTTP clients used to talk to PTP and the torrent clients
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/s0up4200/ptparchiver-go/internal/config"
+)
+
+// Options configures the HTTP client returned by New
+type Options struct {
+	Timeouts config.Timeouts
+	// BindAddress is a local IP address or network interface name outgoing connections are made from
+	BindAddress string
+	// IPFamily forces or prefers IPv4 or IPv6 connections
+	IPFamily config.IPFamily
+	// TLS holds client certificates and CAs for HTTPS connections, nil uses the defaults
+	TLS *tls.Config
+}
+
+// New returns an HTTP client that honours the given options
+func New(opts Options) (*http.Client, error) {
+	if err := opts.IPFamily.Validate(); err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   opts.Timeouts.ConnectTimeout(),
+		KeepAlive: 30 * time.Second,
+	}
+
+	dial := dialContext(dialer, opts.IPFamily)
+
+	if opts.BindAddress != "" {
+		// fail early on a bad bind address, but resolve it again for every connection since the
+		// client is long-lived and a VPN may come back with a new address
+		if _, err := localIP(opts.BindAddress, opts.IPFamily); err != nil {
+			return nil, err
+		}
+
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			ip, err := localIP(opts.BindAddress, opts.IPFamily)
+			if err != nil {
+				return nil, err
+			}
+
+			bound := *dialer
+			bound.LocalAddr = &net.TCPAddr{IP: ip}
+			return dialContext(&bound, opts.IPFamily)(ctx, network, addr)
+		}
+	}
+
+	// the default transport pools connections, keeps them alive and negotiates HTTP/2
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dial
+	transport.TLSHandshakeTimeout = opts.Timeouts.TLSTimeout()
+	if opts.TLS != nil {
+		transport.TLSClientConfig = opts.TLS
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeouts.RequestTimeout(),
+	}, nil
+}
+
+// dialContext wraps the dialer so connections follow the IP family setting
+func dialContext(dialer *net.Dialer, family config.IPFamily) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch family {
+	case config.IPFamilyIPv4:
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp4", addr)
+		}
+	case config.IPFamilyIPv6:
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp6", addr)
+		}
+	case config.IPFamilyPreferIPv4, config.IPFamilyPreferIPv6:
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+
+			ips, err := lookup(ctx, host, family)
+			if err != nil {
+				return nil, err
+			}
+
+			// try each address in turn so a broken route for the preferred family falls back to the other
+			var errs []error
+			for _, ip := range ips {
+				conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+				if err == nil {
+					return conn, nil
+				}
+				errs = append(errs, err)
+			}
+			return nil, errors.Join(errs...)
+		}
+	}
+	return dialer.DialContext
+}
+
+// LookupHost resolves host to a single IP address following the IP family setting. Used for
+// clients that do their own dialing and can't be given a custom dialer.
+func LookupHost(ctx context.Context, host string, family config.IPFamily) (string, error) {
+	if family == config.IPFamilyAny {
+		return host, nil
+	}
+	if err := family.Validate(); err != nil {
+		return "", err
+	}
+
+	ips, err := lookup(ctx, host, family)
+	if err != nil {
+		return "", err
+	}
+	return ips[0].String(), nil
+}
+
+// lookup resolves host and orders the addresses by the IP family setting, dropping addresses
+// of the other family if it is forced
+func lookup(ctx context.Context, host string, family config.IPFamily) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	wantIPv4 := family == config.IPFamilyIPv4 || family == config.IPFamilyPreferIPv4
+	if family == config.IPFamilyIPv4 || family == config.IPFamilyIPv6 {
+		ips = slices.DeleteFunc(ips, func(ip net.IP) bool {
+			return (ip.To4() != nil) != wantIPv4
+		})
+	} else {
+		slices.SortStableFunc(ips, func(a, b net.IP) int {
+			return rank(b, wantIPv4) - rank(a, wantIPv4)
+		})
+	}
+
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no %s address found for %s", family, host)
+	}
+	return ips, nil
+}
+
+func rank(ip net.IP, wantIPv4 bool) int {
+	if (ip.To4() != nil) == wantIPv4 {
+		return 1
+	}
+	return 0
+}
+
+// localIP resolves a bind address to an IP, looking it up as an interface name if it isn't an IP
+func localIP(bindAddress string, family config.IPFamily) (net.IP, error) {
+	if ip := net.ParseIP(bindAddress); ip != nil {
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(bindAddress)
+	if err != nil {
+		return nil, fmt.Errorf("bind address %q is neither an IP address nor an interface: %w", bindAddress, err)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses of interface %s: %w", bindAddress, err)
+	}
+
+	// prefer IPv4 unless told otherwise since that is what most trackers and VPN split tunnels are set up for
+	wantIPv4 := family != config.IPFamilyIPv6 && family != config.IPFamilyPreferIPv6
+	forced := family == config.IPFamilyIPv4 || family == config.IPFamilyIPv6
+
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if (ipNet.IP.To4() != nil) == wantIPv4 {
+			return ipNet.IP, nil
+		}
+		if fallback == nil && !forced {
+			fallback = ipNet.IP
+		}
+	}
+
+	if fallback == nil {
+		return nil, fmt.Errorf("interface %s has no usable address", bindAddress)
+	}
+	return fallback, nil
+}
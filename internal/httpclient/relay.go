@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Relay is a reverse proxy on a loopback port that forwards requests to a target using an HTTP client, for
+// libraries that don't accept a custom HTTP client. Only requests whose path starts with a random token are
+// forwarded, so other local users and processes can't use it to reach the target with the client's
+// certificate.
+type Relay struct {
+	// URL is used in place of the target and includes the token
+	URL    string
+	server *http.Server
+}
+
+// NewRelay starts a relay forwarding to target using client. It runs until Close is called.
+func NewRelay(target string, client *http.Client) (*Relay, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", target, err)
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate relay token: %w", err)
+	}
+	prefix := "/" + hex.EncodeToString(token)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on loopback: %w", err)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(targetURL)
+		},
+		Transport: client.Transport,
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			if len(path) < len(prefix) || subtle.ConstantTimeCompare([]byte(path[:len(prefix)]), []byte(prefix)) != 1 {
+				http.NotFound(w, r)
+				return
+			}
+			rest := path[len(prefix):]
+			if rest != "" && rest[0] != '/' {
+				http.NotFound(w, r)
+				return
+			}
+			r.URL.Path = rest
+			r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+			proxy.ServeHTTP(w, r)
+		}),
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Str("target", targetURL.Redacted()).Msg("relay stopped")
+		}
+	}()
+
+	return &Relay{
+		URL:    "http://" + listener.Addr().String() + prefix,
+		server: server,
+	}, nil
+}
+
+// Close stops the relay and its listener
+func (r *Relay) Close() error {
+	return r.server.Close()
+}
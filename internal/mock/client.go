@@ -0,0 +1,223 @@
+package mock
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s0up4200/ptparchiver-go/internal/client"
+	"github.com/zeebo/bencode"
+)
+
+// DefaultDiskSize is the size of the disk behind every fake client
+const DefaultDiskSize = 4 << 40
+
+// Client is an in-memory torrent client. Added torrents are complete and seeding right away, or paused
+// when added paused.
+type Client struct {
+	mu       sync.Mutex
+	torrents map[string]client.Torrent
+	files    map[string][]byte
+	// DiskSize is the size of the disk torrents are stored on, free space is what they leave of it
+	DiskSize uint64
+}
+
+// NewClient returns an empty fake client with a disk of DefaultDiskSize
+func NewClient() *Client {
+	return &Client{
+		torrents: make(map[string]client.Torrent),
+		files:    make(map[string][]byte),
+		DiskSize: DefaultDiskSize,
+	}
+}
+
+// Clients hands out one fake client per name, so every connection to a client sees the same torrents
+type Clients struct {
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// NewClients returns an empty set of fake clients
+func NewClients() *Clients {
+	return &Clients{clients: make(map[string]*Client)}
+}
+
+// Get returns the fake client with the given name, creating it on first use
+func (c *Clients) Get(name string) client.TorrentClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fc, ok := c.clients[name]
+	if !ok {
+		fc = NewClient()
+		c.clients[name] = fc
+	}
+	return fc
+}
+
+// AddTorrent implements the TorrentClient interface
+func (c *Client) AddTorrent(torrentData []byte, name string, opts map[string]string) error {
+	var raw struct {
+		Info bencode.RawMessage `bencode:"info"`
+	}
+	if err := bencode.DecodeBytes(torrentData, &raw); err != nil {
+		return fmt.Errorf("failed to decode torrent: %w", err)
+	}
+
+	var info struct {
+		Length int64 `bencode:"length"`
+		Files  []struct {
+			Length int64 `bencode:"length"`
+		} `bencode:"files"`
+	}
+	if err := bencode.DecodeBytes(raw.Info, &info); err != nil {
+		return fmt.Errorf("failed to decode torrent info: %w", err)
+	}
+
+	size := info.Length
+	for _, f := range info.Files {
+		size += f.Length
+	}
+
+	sum := sha1.Sum(raw.Info)
+	hash := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.torrents[hash]; ok {
+		return client.ErrAlreadyExists
+	}
+	if uint64(size) > c.freeSpace() {
+		return fmt.Errorf("not enough free space for %s", name)
+	}
+
+	state := client.StateSeeding
+	if opts["paused"] == "true" {
+		state = client.StatePaused
+	}
+
+	c.torrents[hash] = client.Torrent{
+		Hash:     hash,
+		Name:     name,
+		Category: opts["category"],
+		SavePath: opts["download_dir"],
+		Tags:     tags(opts["tags"]),
+		Size:     size,
+		Progress: 1,
+		State:    state,
+		AddedOn:  time.Now(),
+	}
+	c.files[hash] = torrentData
+	return nil
+}
+
+func (c *Client) freeSpace() uint64 {
+	var used uint64
+	for _, t := range c.torrents {
+		used += uint64(t.Size)
+	}
+	if used > c.DiskSize {
+		return 0
+	}
+	return c.DiskSize - used
+}
+
+// GetFreeSpace implements the TorrentClient interface
+func (c *Client) GetFreeSpace() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.freeSpace(), nil
+}
+
+// CountStalledTorrents implements the TorrentClient interface
+func (c *Client) CountStalledTorrents(category string, states []string, metadataAfter time.Duration) (int, error) {
+	if len(states) == 0 {
+		states = []string{string(client.StateStalled)}
+	}
+
+	torrents, _ := c.ListTorrents(category)
+	count := 0
+	for _, t := range torrents {
+		if slices.ContainsFunc(states, func(s string) bool { return strings.EqualFold(s, string(t.State)) }) || t.StuckOnMetadata(metadataAfter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Version implements the TorrentClient interface
+func (c *Client) Version() (string, error) {
+	return "mock", nil
+}
+
+// CategoryExists implements the TorrentClient interface. Every category exists on a fake client.
+func (c *Client) CategoryExists(category string) (bool, error) {
+	return true, nil
+}
+
+// ListTorrents implements the TorrentClient interface
+func (c *Client) ListTorrents(category string) ([]client.Torrent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var torrents []client.Torrent
+	for _, t := range c.torrents {
+		if category == "" || t.Category == category {
+			torrents = append(torrents, t)
+		}
+	}
+	return torrents, nil
+}
+
+// ExportTorrent implements the TorrentClient interface
+func (c *Client) ExportTorrent(hash string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, ok := c.files[strings.ToLower(hash)]
+	if !ok {
+		return nil, fmt.Errorf("torrent %s not found", hash)
+	}
+	return data, nil
+}
+
+// ResumeTorrent implements the TorrentClient interface
+func (c *Client) ResumeTorrent(hash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.torrents[strings.ToLower(hash)]
+	if !ok {
+		return fmt.Errorf("torrent %s not found", hash)
+	}
+	t.State = client.StateSeeding
+	c.torrents[t.Hash] = t
+	return nil
+}
+
+// SetCategory implements the TorrentClient interface
+func (c *Client) SetCategory(hash, category string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.torrents[strings.ToLower(hash)]
+	if !ok {
+		return fmt.Errorf("torrent %s not found", hash)
+	}
+	t.Category = category
+	c.torrents[t.Hash] = t
+	return nil
+}
+
+// tags splits the comma separated tags passed to AddTorrent
+func tags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
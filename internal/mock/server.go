@@ -0,0 +1,159 @@
+// Package mock provides a fake PTP API and an in-memory torrent client, so configs can be exercised
+// end-to-end without credentials or real torrent clients
+package mock
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/zeebo/bencode"
+)
+
+// Server is a fake PTP serving archive.php and torrents.php. Every fetch assigns a new torrent.
+type Server struct {
+	srv *httptest.Server
+
+	mu          sync.Mutex
+	nextID      int
+	containers  map[string]int
+	noTorrents  bool
+	fetchCounts map[string]int
+}
+
+// NewServer starts a fake PTP listening on a local port
+func NewServer() *Server {
+	s := &Server{
+		nextID:      1,
+		containers:  make(map[string]int),
+		fetchCounts: make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/archive.php", s.handleArchive)
+	mux.HandleFunc("/torrents.php", s.handleTorrents)
+	s.srv = httptest.NewServer(s.requireCredentials(mux))
+	return s
+}
+
+// URL returns the base URL to use as baseUrl in the config
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// SetNoTorrents makes fetches answer that there is nothing to assign, like PTP does once the archive is exhausted
+func (s *Server) SetNoTorrents(none bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noTorrents = none
+}
+
+// Fetches returns how many torrents were assigned to the container
+func (s *Server) Fetches(container string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetchCounts[container]
+}
+
+func (s *Server) requireCredentials(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("ApiUser") == "" || r.Header.Get("ApiKey") == "" {
+			http.Error(w, "missing API credentials", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("action") != "fetch" || q.Get("ContainerName") == "" {
+		writeJSON(w, map[string]any{"Status": "Error", "Error": "invalid request"})
+		return
+	}
+
+	name := q.Get("ContainerName")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	containerID, ok := s.containers[name]
+	if !ok {
+		containerID = len(s.containers) + 1
+		s.containers[name] = containerID
+	}
+
+	if s.noTorrents {
+		writeJSON(w, map[string]any{"Status": "Error", "Error": "No torrents available", "ContainerID": containerID})
+		return
+	}
+
+	id := s.nextID
+	s.nextID++
+	s.fetchCounts[name]++
+
+	writeJSON(w, map[string]any{"Status": "Ok", "ContainerID": containerID, "TorrentID": strconv.Itoa(id)})
+}
+
+func (s *Server) handleTorrents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("action") != "download" {
+		// searches are used to check the credentials
+		writeJSON(w, map[string]any{"Movies": []any{}})
+		return
+	}
+
+	id, err := strconv.Atoi(q.Get("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := Torrent(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-bittorrent")
+	w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// mockPieceLength is the piece length of generated torrents
+const mockPieceLength = 16 << 20
+
+// Torrent returns the .torrent file the fake PTP serves for the torrent ID. Sizes vary between 512 MiB
+// and 5 GiB so free space and container size checks have something to work with.
+func Torrent(id int) ([]byte, error) {
+	length := int64(id%10+1) * 512 << 20
+	pieces := make([]byte, 0, (length/mockPieceLength+1)*sha1.Size)
+	for i := int64(0); i*mockPieceLength < length; i++ {
+		sum := sha1.Sum([]byte(fmt.Sprintf("%d:%d", id, i)))
+		pieces = append(pieces, sum[:]...)
+	}
+
+	return bencode.EncodeBytes(map[string]any{
+		"announce": "https://please.passthepopcorn.me/mock/announce",
+		"info": map[string]any{
+			"name":         fmt.Sprintf("Mock.Movie.%d.1080p.BluRay.x264-MOCK", id),
+			"length":       length,
+			"piece length": mockPieceLength,
+			"pieces":       string(pieces),
+			"private":      1,
+		},
+	})
+}
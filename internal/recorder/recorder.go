@@ -0,0 +1,245 @@
+// Package recorder records PTP API responses to disk and replays them, so tracker-side edge cases
+// can be reproduced without contacting PTP
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Interaction is a recorded request and the response PTP gave to it
+type Interaction struct {
+	Method string `json:"method"`
+	// URL is the request URL without the scheme and host
+	URL        string    `json:"url"`
+	Status     int       `json:"status"`
+	RecordedAt time.Time `json:"recordedAt"`
+	// ContentType is the Content-Type header of the response
+	ContentType string `json:"contentType,omitempty"`
+	// Body is the response body if it is text, BodyBase64 holds it otherwise
+	Body       string `json:"body,omitempty"`
+	BodyBase64 []byte `json:"bodyBase64,omitempty"`
+}
+
+// sensitive matches secrets PTP puts in its responses: the passkey in announce URLs and the keys in JSON
+var sensitive = []*regexp.Regexp{
+	regexp.MustCompile(`/([0-9a-zA-Z]{32})/announce`),
+	regexp.MustCompile(`"(?:AuthKey|PassKey|AntiCsrfToken)"\s*:\s*"([^"]*)"`),
+}
+
+// minSecretLength keeps a short placeholder credential from redacting every occurrence of a letter
+const minSecretLength = 8
+
+// redact overwrites the secrets in the body with x, keeping their length so a bencoded .torrent file stays valid
+func redact(body []byte, secrets ...string) []byte {
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			body = bytes.ReplaceAll(body, []byte(secret), bytes.Repeat([]byte("x"), len(secret)))
+		}
+	}
+
+	for _, re := range sensitive {
+		for _, m := range re.FindAllSubmatchIndex(body, -1) {
+			for i := m[2]; i < m[3]; i++ {
+				body[i] = 'x'
+			}
+		}
+	}
+	return body
+}
+
+// recorder saves every interaction to a numbered file in dir
+type recorder struct {
+	dir  string
+	next http.RoundTripper
+
+	mu sync.Mutex
+	n  int
+}
+
+// Record returns a transport that sends requests through next and saves each response, with the API
+// credentials and passkeys redacted, to a numbered JSON file in dir
+func Record(dir string, next http.RoundTripper) http.RoundTripper {
+	return &recorder{dir: dir, next: next}
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	// the caller gets the response as received
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Method:      req.Method,
+		URL:         sanitizeURL(req.URL),
+		Status:      resp.StatusCode,
+		RecordedAt:  time.Now(),
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+
+	redacted := redact(bytes.Clone(body), req.Header.Get("ApiUser"), req.Header.Get("ApiKey"))
+	if utf8.Valid(redacted) {
+		interaction.Body = string(redacted)
+	} else {
+		interaction.BodyBase64 = redacted
+	}
+
+	if err := r.save(interaction); err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+	return resp, nil
+}
+
+func (r *recorder) save(interaction Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return err
+	}
+
+	// continue after the interactions of an earlier run in the same directory
+	if r.n == 0 {
+		existing, _ := filepath.Glob(filepath.Join(r.dir, "*.json"))
+		r.n = len(existing)
+	}
+	r.n++
+
+	// keep the URLs and bodies readable for bug reports
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(interaction); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, fmt.Sprintf("%04d.json", r.n)), buf.Bytes(), 0o600)
+}
+
+// sanitizeURL returns the path and query of the URL, which is all that's needed to match it on replay
+func sanitizeURL(u *url.URL) string {
+	q := u.Query()
+	q.Del("ApiUser")
+	q.Del("ApiKey")
+	return (&url.URL{Path: u.Path, RawQuery: q.Encode()}).String()
+}
+
+// replayer answers requests with recorded interactions
+type replayer struct {
+	dir string
+
+	once         sync.Once
+	err          error
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// Replay returns a transport that answers requests with the interactions recorded in dir instead of
+// contacting PTP. Each request gets the first unused interaction with the same method, path and action,
+// in the order they were recorded.
+func Replay(dir string) http.RoundTripper {
+	return &replayer{dir: dir}
+}
+
+func (r *replayer) load() error {
+	files, err := filepath.Glob(filepath.Join(r.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no recorded interactions in %s", r.dir)
+	}
+
+	// numbered file names sort in the order they were recorded
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(data, &interaction); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		r.interactions = append(r.interactions, &interaction)
+	}
+	r.used = make([]bool, len(r.interactions))
+	return nil
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.once.Do(func() { r.err = r.load() })
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to load recorded interactions: %w", r.err)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || !matches(interaction, req) {
+			continue
+		}
+		r.used[i] = true
+
+		body := interaction.BodyBase64
+		if body == nil {
+			body = []byte(interaction.Body)
+		}
+
+		header := make(http.Header)
+		if interaction.ContentType != "" {
+			header.Set("Content-Type", interaction.ContentType)
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode:    interaction.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded response left for %s %s", req.Method, sanitizeURL(req.URL))
+}
+
+// matches reports whether the interaction was recorded for the same kind of request
+func matches(interaction *Interaction, req *http.Request) bool {
+	if interaction.Method != req.Method {
+		return false
+	}
+
+	recorded, err := url.Parse(interaction.URL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(recorded.Path, req.URL.Path) &&
+		recorded.Query().Get("action") == req.URL.Query().Get("action")
+}
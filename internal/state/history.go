@@ -0,0 +1,149 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryFileName is the name of the history file stored next to the config file
+const HistoryFileName = "history.json"
+
+// HistoryEntry records a torrent, identified by its lower-case hex infohash, that was added to a client as part of a container
+type HistoryEntry struct {
+	Hash      string    `json:"hash"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Container string    `json:"container,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	TorrentID string    `json:"torrentId,omitempty"`
+	AddedAt   time.Time `json:"addedAt"`
+	// WatchFile is the .torrent file saved for watch directory containers
+	WatchFile string `json:"watchFile,omitempty"`
+	// PickedUpAt is when the watch file was first seen gone, i.e. consumed by the downstream client
+	PickedUpAt *time.Time `json:"pickedUpAt,omitempty"`
+	// CleanedUpAt is when a watch file that was never picked up was removed
+	CleanedUpAt *time.Time `json:"cleanedUpAt,omitempty"`
+}
+
+// History is the persisted list of every torrent the archiver is responsible for, keyed by infohash
+type History struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]HistoryEntry
+}
+
+// HistoryPathFor returns the history file path that belongs to the given config file
+func HistoryPathFor(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), HistoryFileName)
+}
+
+// LoadHistory reads the history file at path, returning an empty history if it does not exist yet
+func LoadHistory(path string) (*History, error) {
+	h := &History{
+		path:    path,
+		entries: make(map[string]HistoryEntry),
+	}
+
+	if err := h.reload(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// reload re-reads the history file so entries written by other processes aren't lost. Callers must hold mu.
+func (h *History) reload() error {
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse history file: %w", err)
+	}
+
+	h.entries = make(map[string]HistoryEntry, len(entries))
+	for _, e := range entries {
+		h.entries[e.Hash] = e
+	}
+
+	return nil
+}
+
+func (h *History) save() error {
+	data, err := json.MarshalIndent(h.sorted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	return nil
+}
+
+// sorted returns the entries ordered by when they were added. Callers must hold mu.
+func (h *History) sorted() []HistoryEntry {
+	entries := make([]HistoryEntry, 0, len(h.entries))
+	for _, e := range h.entries {
+		entries = append(entries, e)
+	}
+
+	slices.SortFunc(entries, func(a, b HistoryEntry) int {
+		if c := a.AddedAt.Compare(b.AddedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Hash, b.Hash)
+	})
+	return entries
+}
+
+// Add stores the entries, replacing any existing entries with the same hash, and saves the history
+func (h *History) Add(entries ...HistoryEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.reload(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		e.Hash = strings.ToLower(e.Hash)
+		h.entries[e.Hash] = e
+	}
+
+	return h.save()
+}
+
+// Get returns the entry for the given infohash
+func (h *History) Get(hash string) (HistoryEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e, ok := h.entries[strings.ToLower(hash)]
+	return e, ok
+}
+
+// Entries returns all entries ordered by when they were added
+func (h *History) Entries() []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.sorted()
+}
@@ -0,0 +1,89 @@
+package state
+
+import "time"
+
+// SpaceSample is the free space a torrent client reported at a point in time
+type SpaceSample struct {
+	Time time.Time `json:"time"`
+	Free uint64    `json:"free"`
+}
+
+const (
+	// spaceSampleInterval is the minimum time between two recorded samples of the same client
+	spaceSampleInterval = time.Hour
+	// maxSpaceSamples keeps about a month of hourly samples per client
+	maxSpaceSamples = 24 * 31
+	// spaceTrendWindow is how far back samples are used to estimate the fill rate
+	spaceTrendWindow = 7 * 24 * time.Hour
+	// minSpaceTrendSpan avoids extrapolating from a few samples taken close together
+	minSpaceTrendSpan = 12 * time.Hour
+)
+
+// RecordFreeSpace stores a free space sample for the client, at most one per hour, and saves the state
+func (s *State) RecordFreeSpace(client string, free uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	samples := s.FreeSpace[client]
+	now := time.Now()
+	if n := len(samples); n > 0 && now.Sub(samples[n-1].Time) < spaceSampleInterval {
+		return nil
+	}
+
+	samples = append(samples, SpaceSample{Time: now, Free: free})
+	if len(samples) > maxSpaceSamples {
+		samples = samples[len(samples)-maxSpaceSamples:]
+	}
+
+	if s.FreeSpace == nil {
+		s.FreeSpace = make(map[string][]SpaceSample)
+	}
+	s.FreeSpace[client] = samples
+
+	return s.save()
+}
+
+// TimeUntilFull estimates when the client runs out of space from the change in free space over the last
+// week. It returns false if there aren't enough samples or free space isn't shrinking.
+func (s *State) TimeUntilFull(client string) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := s.FreeSpace[client]
+	if len(samples) < 2 {
+		return 0, false
+	}
+
+	last := samples[len(samples)-1]
+	first := last
+	for _, sample := range samples {
+		if last.Time.Sub(sample.Time) <= spaceTrendWindow {
+			first = sample
+			break
+		}
+	}
+
+	span := last.Time.Sub(first.Time)
+	if span < minSpaceTrendSpan || last.Free >= first.Free {
+		return 0, false
+	}
+
+	bytesPerSecond := float64(first.Free-last.Free) / span.Seconds()
+	return time.Duration(float64(last.Free) / bytesPerSecond * float64(time.Second)), true
+}
+
+// LatestFreeSpace returns the most recent free space sample of the client
+func (s *State) LatestFreeSpace(client string) (SpaceSample, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := s.FreeSpace[client]
+	if len(samples) == 0 {
+		return SpaceSample{}, false
+	}
+	return samples[len(samples)-1], true
+}
@@ -0,0 +1,462 @@
+// Package state persists runtime information between invocations
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the state file stored next to the config file
+const FileName = "state.json"
+
+// ContainerState records the outcome of the most recent fetch for a container
+type ContainerState struct {
+	LastFetch  time.Time `json:"lastFetch"`
+	LastResult string    `json:"lastResult"`
+	LastError  string    `json:"lastError,omitempty"`
+	// Paused containers are skipped when fetching until they are resumed
+	Paused bool `json:"paused,omitempty"`
+	// Results counts every fetch attempt by its result
+	Results map[string]int `json:"results,omitempty"`
+	// Daily counts fetch attempts by result for each of the last days, keyed by date
+	Daily map[string]map[string]int `json:"daily,omitempty"`
+	// BackoffUntil is when fetching resumes after PTP had no torrents for the container
+	BackoffUntil time.Time `json:"backoffUntil,omitempty"`
+	// NextRun is when the run command's schedule for the container is due next, and NextFetch when it
+	// actually fetches for it, which is later while the container backs off
+	NextRun   time.Time `json:"nextRun,omitempty"`
+	NextFetch time.Time `json:"nextFetch,omitempty"`
+	// NoTorrentsStreak counts consecutive fetches PTP had no torrents for
+	NoTorrentsStreak int `json:"noTorrentsStreak,omitempty"`
+	// Remote is what PTP last reported about the container
+	Remote *RemoteContainer `json:"remote,omitempty"`
+	// Blocked holds the names of torrents skipped by the blocklist by their torrent ID, so the name
+	// patterns can be checked without downloading them again when PTP assigns them once more
+	Blocked map[string]string `json:"blocked,omitempty"`
+}
+
+// RemoteContainer is the server side identity PTP associates with a container name
+type RemoteContainer struct {
+	// ID is the ContainerID returned by archive.php
+	ID       string    `json:"id"`
+	Status   string    `json:"status,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// State is the persisted runtime state shared by the fetch and run commands
+type State struct {
+	Containers map[string]*ContainerState `json:"containers"`
+	// LastAudit is when the seeding audit last ran and AuditProblems how many torrents failed it
+	LastAudit     time.Time `json:"lastAudit,omitempty"`
+	AuditProblems int       `json:"auditProblems,omitempty"`
+	// FreeSpace holds the free space samples of each torrent client
+	FreeSpace map[string][]SpaceSample `json:"freeSpace,omitempty"`
+	// ScriptVersion is the official Python script version PTP last reported and ScriptVersionSince
+	// when it first reported it
+	ScriptVersion      string    `json:"scriptVersion,omitempty"`
+	ScriptVersionSince time.Time `json:"scriptVersionSince,omitempty"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// PathFor returns the state file path that belongs to the given config file
+func PathFor(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), FileName)
+}
+
+// Load reads the state file at path, returning an empty state if it does not exist yet
+func Load(path string) (*State, error) {
+	s := &State{
+		Containers: make(map[string]*ContainerState),
+		path:       path,
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// reload re-reads the state file so changes made by other processes, such as the pause
+// command while the run command is active, are picked up. Callers must hold mu.
+func (s *State) reload() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var loaded State
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	s.Containers = loaded.Containers
+	if s.Containers == nil {
+		s.Containers = make(map[string]*ContainerState)
+	}
+	s.FreeSpace = loaded.FreeSpace
+	s.LastAudit = loaded.LastAudit
+	s.AuditProblems = loaded.AuditProblems
+	s.ScriptVersion = loaded.ScriptVersion
+	s.ScriptVersionSince = loaded.ScriptVersionSince
+
+	return nil
+}
+
+// Save writes the state back to disk
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.save()
+}
+
+func (s *State) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	// write to a temp file first so a crash never leaves a truncated state file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// Container returns a copy of the recorded state for the named container
+func (s *State) Container(name string) (ContainerState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs, ok := s.Containers[name]
+	if !ok {
+		return ContainerState{}, false
+	}
+
+	c := *cs
+	c.Results = maps.Clone(cs.Results)
+	c.Daily = make(map[string]map[string]int, len(cs.Daily))
+	for day, results := range cs.Daily {
+		c.Daily[day] = maps.Clone(results)
+	}
+	if cs.Remote != nil {
+		remote := *cs.Remote
+		c.Remote = &remote
+	}
+	return c, true
+}
+
+// ContainerNames returns the names of all containers with recorded state, including ones
+// that have since been removed from the config
+func (s *State) ContainerNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(s.Containers))
+}
+
+// RecordFetch stores the result of a fetch attempt for a container and saves the state
+func (s *State) RecordFetch(name, result string, fetchErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	cs := s.container(name)
+	cs.LastFetch = time.Now()
+	cs.LastResult = result
+	cs.LastError = ""
+	if fetchErr != nil {
+		cs.LastError = fetchErr.Error()
+	}
+	if cs.Results == nil {
+		cs.Results = make(map[string]int)
+	}
+	cs.Results[result]++
+
+	day := cs.LastFetch.Format(time.DateOnly)
+	if cs.Daily == nil {
+		cs.Daily = make(map[string]map[string]int)
+	}
+	if cs.Daily[day] == nil {
+		cs.Daily[day] = make(map[string]int)
+	}
+	cs.Daily[day][result]++
+
+	// dates sort chronologically as strings
+	oldest := cs.LastFetch.AddDate(0, 0, -dailyResultDays).Format(time.DateOnly)
+	maps.DeleteFunc(cs.Daily, func(d string, _ map[string]int) bool { return d < oldest })
+
+	return s.save()
+}
+
+// dailyResultDays is how many days of per-day fetch results are kept
+const dailyResultDays = 35
+
+// ResultsSince adds up the daily fetch results from the day of t onwards
+func (cs ContainerState) ResultsSince(t time.Time) map[string]int {
+	since := t.Format(time.DateOnly)
+	results := make(map[string]int)
+	for day, counts := range cs.Daily {
+		if day < since {
+			continue
+		}
+		for result, n := range counts {
+			results[result] += n
+		}
+	}
+	return results
+}
+
+// RecordRemote stores the ContainerID and status PTP returned for a container and saves the state.
+// It returns the previously recorded ID, which is empty if none was known.
+func (s *State) RecordRemote(name, id, status string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return "", err
+	}
+
+	cs := s.container(name)
+	previous := ""
+	if cs.Remote != nil {
+		previous = cs.Remote.ID
+	}
+	cs.Remote = &RemoteContainer{ID: id, Status: status, LastSeen: time.Now()}
+
+	return previous, s.save()
+}
+
+// SetPaused pauses or resumes fetching for a container and saves the state
+func (s *State) SetPaused(name string, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	s.container(name).Paused = paused
+	return s.save()
+}
+
+// Backoff records that PTP had no torrents for the container and saves the state. Once that happened after
+// times in a row the container's interval is stretched, doubling with every further time up to maxInterval,
+// and the time fetching resumes is returned. It's zero while the container isn't backing off.
+func (s *State) Backoff(name string, after int, interval, maxInterval time.Duration) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return time.Time{}, err
+	}
+
+	cs := s.container(name)
+	cs.NoTorrentsStreak++
+	cs.BackoffUntil = time.Time{}
+
+	if extra := cs.NoTorrentsStreak - after; extra >= 0 && interval > 0 && maxInterval > interval {
+		stretched := maxInterval
+		if extra < 16 && interval<<(extra+1) < maxInterval {
+			stretched = interval << (extra + 1)
+		}
+		// ends a tenth of an interval early so the scheduled fetch it lines up with isn't skipped
+		cs.BackoffUntil = time.Now().Add(stretched - interval/10)
+	}
+
+	return cs.BackoffUntil, s.save()
+}
+
+// ClearBackoff resets the backoff of a container, only saving the state if one was recorded
+func (s *State) ClearBackoff(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	cs, ok := s.Containers[name]
+	if !ok || (cs.NoTorrentsStreak == 0 && cs.BackoffUntil.IsZero()) {
+		return nil
+	}
+
+	cs.NoTorrentsStreak = 0
+	cs.BackoffUntil = time.Time{}
+	return s.save()
+}
+
+// BackoffUntil returns when fetching for the container resumes, which is zero if it isn't backing off
+func (s *State) BackoffUntil(name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// keep using the last known state if the file can't be read
+	_ = s.reload()
+
+	cs, ok := s.Containers[name]
+	if !ok {
+		return time.Time{}
+	}
+	return cs.BackoffUntil
+}
+
+// RecordBlocked stores the name of a torrent skipped by the blocklist for the container and saves the state
+func (s *State) RecordBlocked(name, torrentID, torrentName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	cs := s.container(name)
+	if cs.Blocked == nil {
+		cs.Blocked = make(map[string]string)
+	}
+	cs.Blocked[torrentID] = torrentName
+	return s.save()
+}
+
+// BlockedName returns the name of a torrent skipped by the blocklist before in the container
+func (s *State) BlockedName(name, torrentID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// keep using the last known state if the file can't be read
+	_ = s.reload()
+
+	cs, ok := s.Containers[name]
+	if !ok {
+		return "", false
+	}
+	torrentName, ok := cs.Blocked[torrentID]
+	return torrentName, ok
+}
+
+// IsPaused reports whether fetching has been paused for the container
+func (s *State) IsPaused(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// keep using the last known state if the file can't be read
+	_ = s.reload()
+
+	cs, ok := s.Containers[name]
+	return ok && cs.Paused
+}
+
+// container returns the state for the named container, creating it if needed. Callers must hold mu.
+func (s *State) container(name string) *ContainerState {
+	cs, ok := s.Containers[name]
+	if !ok {
+		cs = &ContainerState{}
+		s.Containers[name] = cs
+	}
+	return cs
+}
+
+// SetNextFetch stores the next scheduled run of the run command for the container and when it fetches
+// for the container next, which is later while the container backs off, and saves the state
+func (s *State) SetNextFetch(name string, nextRun, nextFetch time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	cs := s.container(name)
+	cs.NextRun = nextRun
+	cs.NextFetch = nextFetch
+	return s.save()
+}
+
+// ClearSchedule removes the scheduled runs of every container, for when the run command stops or starts
+// over, and saves the state
+func (s *State) ClearSchedule() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	for _, cs := range s.Containers {
+		cs.NextRun = time.Time{}
+		cs.NextFetch = time.Time{}
+	}
+	return s.save()
+}
+
+// NextRun returns the earliest scheduled run of the run command across the containers, which is zero
+// while it isn't active
+func (s *State) NextRun() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// keep using the last known state if the file can't be read
+	_ = s.reload()
+
+	var next time.Time
+	for _, cs := range s.Containers {
+		if !cs.NextRun.IsZero() && (next.IsZero() || cs.NextRun.Before(next)) {
+			next = cs.NextRun
+		}
+	}
+	return next
+}
+
+// RecordAudit stores the time and number of problems of a seeding audit and saves the state
+func (s *State) RecordAudit(problems int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	s.LastAudit = time.Now()
+	s.AuditProblems = problems
+	return s.save()
+}
+
+// RecordScriptVersion stores the official Python script version PTP reported and returns the one
+// recorded before. The state is only saved when the version changed.
+func (s *State) RecordScriptVersion(version string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return "", err
+	}
+
+	previous := s.ScriptVersion
+	if previous == version {
+		return previous, nil
+	}
+	s.ScriptVersion = version
+	s.ScriptVersionSince = time.Now()
+	return previous, s.save()
+}
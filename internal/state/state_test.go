@@ -0,0 +1,103 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	const (
+		after       = 2
+		interval    = time.Hour
+		maxInterval = 6 * time.Hour
+	)
+
+	st, err := Load(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+
+	// the interval doubles once PTP had nothing after times in a row, and each backoff ends a tenth of an
+	// interval early so the scheduled fetch it lines up with isn't skipped
+	want := []time.Duration{0, 2 * time.Hour, 4 * time.Hour, 6 * time.Hour, 6 * time.Hour}
+	for i, stretched := range want {
+		start := time.Now()
+		until, err := st.Backoff("hetzner", after, interval, maxInterval)
+		if err != nil {
+			t.Fatalf("Backoff() error = %v", err)
+		}
+
+		if stretched == 0 {
+			if !until.IsZero() {
+				t.Errorf("Backoff() #%d = %s, want no backoff", i+1, until)
+			}
+			continue
+		}
+		if earliest, latest := start.Add(stretched-interval/10), time.Now().Add(stretched-interval/10); until.Before(earliest) || until.After(latest) {
+			t.Errorf("Backoff() #%d = %s, want %s from now", i+1, until, stretched-interval/10)
+		}
+		if got := st.BackoffUntil("hetzner"); !got.Equal(until) {
+			t.Errorf("BackoffUntil() = %s, want %s", got, until)
+		}
+	}
+
+	if err := st.ClearBackoff("hetzner"); err != nil {
+		t.Fatalf("ClearBackoff() error = %v", err)
+	}
+	if got := st.BackoffUntil("hetzner"); !got.IsZero() {
+		t.Errorf("BackoffUntil() after ClearBackoff() = %s, want zero", got)
+	}
+	if until, _ := st.Backoff("hetzner", after, interval, maxInterval); !until.IsZero() {
+		t.Errorf("Backoff() after ClearBackoff() = %s, want the streak to start over", until)
+	}
+}
+
+func TestBackoffWithoutStretch(t *testing.T) {
+	st, err := Load(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+
+	// a maximum that isn't above the interval leaves nothing to stretch
+	for range 3 {
+		if until, err := st.Backoff("hetzner", 1, time.Hour, time.Hour); err != nil || !until.IsZero() {
+			t.Fatalf("Backoff() = %s, %v, want no backoff", until, err)
+		}
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	st, err := Load(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	if got := st.NextRun(); !got.IsZero() {
+		t.Errorf("NextRun() = %s, want zero before anything is scheduled", got)
+	}
+
+	// every container schedules its own runs, and the earliest is the one due first
+	now := time.Now().Round(0)
+	if err := st.SetNextFetch("hetzner", now.Add(time.Hour), now.Add(3*time.Hour)); err != nil {
+		t.Fatalf("SetNextFetch() error = %v", err)
+	}
+	if err := st.SetNextFetch("ovh", now.Add(time.Minute), now.Add(time.Minute)); err != nil {
+		t.Fatalf("SetNextFetch() error = %v", err)
+	}
+	if err := st.SetNextFetch("hetzner", now.Add(2*time.Hour), now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("SetNextFetch() error = %v", err)
+	}
+	if got := st.NextRun(); !got.Equal(now.Add(time.Minute)) {
+		t.Errorf("NextRun() = %s, want %s", got, now.Add(time.Minute))
+	}
+
+	if err := st.ClearSchedule(); err != nil {
+		t.Fatalf("ClearSchedule() error = %v", err)
+	}
+	if got := st.NextRun(); !got.IsZero() {
+		t.Errorf("NextRun() after ClearSchedule() = %s, want zero", got)
+	}
+	if cs, _ := st.Container("hetzner"); !cs.NextFetch.IsZero() {
+		t.Errorf("NextFetch after ClearSchedule() = %s, want zero", cs.NextFetch)
+	}
+}
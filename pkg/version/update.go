@@ -0,0 +1,283 @@
+package version
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	downloadTimeout = 5 * time.Minute
+	binaryName      = "ptparchiver"
+	// maxDownloadSize bounds release downloads and the binary extracted from them, far above their actual size
+	maxDownloadSize = 200 << 20
+)
+
+// Asset is a file attached to a GitHub release
+type Asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// archiveName returns the release archive for the current platform, following the
+// name_template in .goreleaser.yml
+func archiveName(project, tag string) string {
+	arch := runtime.GOARCH
+	if arch == "amd64" {
+		arch = "x86_64"
+	}
+
+	ext := "tar.gz"
+	if runtime.GOOS == "windows" {
+		ext = "zip"
+	}
+
+	return fmt.Sprintf("%s_%s_%s_%s.%s", project, strings.TrimPrefix(tag, "v"), runtime.GOOS, arch, ext)
+}
+
+// checksumsName returns the checksums file published alongside the archives
+func checksumsName(project, tag string) string {
+	return fmt.Sprintf("%s_%s_checksums.txt", project, strings.TrimPrefix(tag, "v"))
+}
+
+// SelfUpdate replaces the running binary with the latest release for the current OS/arch.
+// It returns the installed tag, or an empty string if already up to date and force is false.
+func SelfUpdate(org, repo string, force bool) (string, error) {
+	release, err := GetLatestRelease(org, repo)
+	if err != nil {
+		return "", err
+	}
+
+	if !force {
+		if Version == "dev" {
+			return "", fmt.Errorf("refusing to replace a development build, use --force to override")
+		}
+
+		newer, err := isNewer(release.TagName)
+		if err != nil {
+			return "", err
+		}
+		if !newer {
+			return "", nil
+		}
+	}
+
+	archive := archiveName(repo, release.TagName)
+	archiveAsset, ok := release.asset(archive)
+	if !ok {
+		return "", fmt.Errorf("release %s has no asset %s", release.TagName, archive)
+	}
+
+	checksums := checksumsName(repo, release.TagName)
+	checksumsAsset, ok := release.asset(checksums)
+	if !ok {
+		return "", fmt.Errorf("release %s has no asset %s", release.TagName, checksums)
+	}
+
+	log.Info().
+		Str("release", release.TagName).
+		Str("asset", archive).
+		Msg("downloading release")
+
+	archiveData, err := download(archiveAsset.BrowserDownloadURL, repo)
+	if err != nil {
+		return "", err
+	}
+
+	checksumsData, err := download(checksumsAsset.BrowserDownloadURL, repo)
+	if err != nil {
+		return "", err
+	}
+
+	if err := verifyChecksum(archive, archiveData, checksumsData); err != nil {
+		return "", err
+	}
+
+	binary, err := extractBinary(archiveData)
+	if err != nil {
+		return "", err
+	}
+
+	if err := replaceExecutable(binary); err != nil {
+		return "", err
+	}
+
+	return release.TagName, nil
+}
+
+func (r *Release) asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+func download(url, repo string) ([]byte, error) {
+	client := &http.Client{Timeout: downloadTimeout}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", repo, Version))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: %s", url, resp.Status)
+	}
+
+	data, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", url, err)
+	}
+
+	return data, nil
+}
+
+// readLimited reads r to the end, failing rather than reading on once it exceeds maxDownloadSize
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownloadSize {
+		return nil, fmt.Errorf("larger than %d MiB", maxDownloadSize>>20)
+	}
+	return data, nil
+}
+
+// verifyChecksum compares the sha256 of data against the entry for name in a goreleaser checksums file
+func verifyChecksum(name string, data, checksums []byte) error {
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[1] != name {
+			continue
+		}
+
+		if !strings.EqualFold(fields[0], actual) {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, fields[0], actual)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no checksum found for %s", name)
+}
+
+// extractBinary pulls the ptparchiver executable out of a release archive
+func extractBinary(archive []byte) ([]byte, error) {
+	if runtime.GOOS == "windows" {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, fmt.Errorf("opening archive: %w", err)
+		}
+
+		for _, f := range zr.File {
+			if filepath.Base(f.Name) != binaryName+".exe" {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("extracting %s: %w", f.Name, err)
+			}
+			defer rc.Close()
+			binary, err := readLimited(rc)
+			if err != nil {
+				return nil, fmt.Errorf("extracting %s: %w", f.Name, err)
+			}
+			return binary, nil
+		}
+
+		return nil, fmt.Errorf("%s.exe not found in archive", binaryName)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("opening archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+
+		if hdr.Typeflag == tar.TypeReg && filepath.Base(hdr.Name) == binaryName {
+			binary, err := readLimited(tr)
+			if err != nil {
+				return nil, fmt.Errorf("extracting %s: %w", hdr.Name, err)
+			}
+			return binary, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s not found in archive", binaryName)
+}
+
+// replaceExecutable swaps the running binary for the new one. The old binary is moved
+// aside first since Windows won't let a running executable be overwritten.
+func replaceExecutable(binary []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating executable: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("locating executable: %w", err)
+	}
+
+	info, err := os.Stat(exe)
+	if err != nil {
+		return fmt.Errorf("locating executable: %w", err)
+	}
+
+	newPath := exe + ".new"
+	oldPath := exe + ".old"
+
+	if err := os.WriteFile(newPath, binary, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("writing new binary: %w", err)
+	}
+
+	if err := os.Rename(exe, oldPath); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("moving old binary: %w", err)
+	}
+
+	if err := os.Rename(newPath, exe); err != nil {
+		// put the old binary back so we don't leave the user without one
+		os.Rename(oldPath, exe)
+		return fmt.Errorf("installing new binary: %w", err)
+	}
+
+	// best effort, this fails on Windows while the old binary is still running
+	os.Remove(oldPath)
+
+	return nil
+}
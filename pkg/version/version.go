@@ -94,35 +94,37 @@ func getBuiltBy() string {
 	return "unknown"
 }
 
-// CheckForUpdates checks GitHub for the latest release version and logs the results
-func CheckForUpdates(org, repo string) error {
-	if org == "" || repo == "" {
-		return fmt.Errorf("organization and repository names are required")
-	}
+// Release describes a GitHub release
+type Release struct {
+	TagName     string    `json:"tag_name"`
+	PublishedAt time.Time `json:"published_at"`
+	HTMLURL     string    `json:"html_url"`
+	Assets      []Asset   `json:"assets"`
+}
 
-	// Show current version using structured logging
-	logEvent := log.Info()
+// Info combines the build information with the latest available release
+type Info struct {
+	Version         string    `json:"version"`
+	Commit          string    `json:"commit"`
+	Date            string    `json:"buildDate"`
+	BuiltBy         string    `json:"builtBy"`
+	Latest          string    `json:"latest"`
+	PublishedAt     time.Time `json:"publishedAt"`
+	UpdateURL       string    `json:"updateUrl"`
+	UpdateAvailable bool      `json:"updateAvailable"`
+}
 
-	if Version != "" {
-		logEvent.Str("version", Version)
-	}
-	if Commit != "" && Commit != "none" {
-		logEvent.Str("commit", Commit)
-	}
-	if Date != "" && Date != "unknown" {
-		logEvent.Str("buildDate", Date)
-	}
-	if BuiltBy != "" && BuiltBy != "unknown" {
-		logEvent.Str("builtBy", BuiltBy)
+// GetLatestRelease fetches the latest release of the repository from GitHub
+func GetLatestRelease(org, repo string) (*Release, error) {
+	if org == "" || repo == "" {
+		return nil, fmt.Errorf("organization and repository names are required")
 	}
 
-	logEvent.Msg(fmt.Sprintf("%s version info", repo))
-
 	client := &http.Client{Timeout: defaultTimeout}
 	url := fmt.Sprintf(apiURLFormat, org, repo)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
@@ -130,58 +132,130 @@ func CheckForUpdates(org, repo string) error {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("checking updates: %w", err)
+		return nil, fmt.Errorf("checking updates: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("GitHub API request failed: %s", resp.Status)
+		return nil, fmt.Errorf("GitHub API request failed: %s", resp.Status)
 	}
 
-	var release struct {
-		TagName     string    `json:"tag_name"`
-		PublishedAt time.Time `json:"published_at"`
-		HTMLURL     string    `json:"html_url"`
-	}
+	var release Release
 	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return fmt.Errorf("failed to parse GitHub response: %w", err)
+		return nil, fmt.Errorf("failed to parse GitHub response: %w", err)
+	}
+
+	return &release, nil
+}
+
+// Current returns the build information without looking up the latest release
+func Current() *Info {
+	return &Info{
+		Version: Version,
+		Commit:  Commit,
+		Date:    Date,
+		BuiltBy: BuiltBy,
+	}
+}
+
+// Check returns the build information along with whether a newer release is available
+func Check(org, repo string) (*Info, error) {
+	release, err := GetLatestRelease(org, repo)
+	if err != nil {
+		return nil, err
 	}
 
+	info := Current()
+	info.Latest = release.TagName
+	info.PublishedAt = release.PublishedAt
+	info.UpdateURL = release.HTMLURL
+
 	// Skip version comparison for dev versions
 	if Version == "dev" {
-		log.Info().
-			Str("current", Version).
-			Str("latest", release.TagName).
-			Time("publishedAt", release.PublishedAt).
-			Str("updateUrl", release.HTMLURL).
-			Msg("development version - skipping update check")
-		return nil
+		return info, nil
 	}
 
-	latestVersion := strings.TrimPrefix(release.TagName, "v")
+	info.UpdateAvailable, err = isNewer(release.TagName)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// isNewer reports whether the given release tag is newer than the running version
+func isNewer(tag string) (bool, error) {
+	latestVersion := strings.TrimPrefix(tag, "v")
 	currentVersion := strings.TrimPrefix(Version, "v")
 
 	// Parse versions using semver
 	currentVer, err := semver.NewVersion(currentVersion)
 	if err != nil {
-		return fmt.Errorf("invalid current version format: %w", err)
+		return false, fmt.Errorf("invalid current version format: %w", err)
 	}
 
 	latestVer, err := semver.NewVersion(latestVersion)
 	if err != nil {
-		return fmt.Errorf("invalid latest version format: %w", err)
+		return false, fmt.Errorf("invalid latest version format: %w", err)
+	}
+
+	return currentVer.LessThan(latestVer), nil
+}
+
+// LogVersion logs the build information of the running binary
+func LogVersion(repo string) {
+	logEvent := log.Info()
+
+	if Version != "" {
+		logEvent.Str("version", Version)
+	}
+	if Commit != "" && Commit != "none" {
+		logEvent.Str("commit", Commit)
+	}
+	if Date != "" && Date != "unknown" {
+		logEvent.Str("buildDate", Date)
+	}
+	if BuiltBy != "" && BuiltBy != "unknown" {
+		logEvent.Str("builtBy", BuiltBy)
+	}
+
+	logEvent.Msg(fmt.Sprintf("%s version info", repo))
+}
+
+// CheckForUpdates checks GitHub for the latest release version and logs the results
+func CheckForUpdates(org, repo string) error {
+	if org == "" || repo == "" {
+		return fmt.Errorf("organization and repository names are required")
+	}
+
+	LogVersion(repo)
+
+	info, err := Check(org, repo)
+	if err != nil {
+		return err
+	}
+
+	// Skip version comparison for dev versions
+	if Version == "dev" {
+		log.Info().
+			Str("current", Version).
+			Str("latest", info.Latest).
+			Time("publishedAt", info.PublishedAt).
+			Str("updateUrl", info.UpdateURL).
+			Msg("development version - skipping update check")
+		return nil
 	}
 
-	if currentVer.LessThan(latestVer) {
+	if info.UpdateAvailable {
 		log.Info().
 			Str("current", Version).
-			Str("latest", release.TagName).
-			Time("publishedAt", release.PublishedAt).
-			Str("updateUrl", release.HTMLURL).
+			Str("latest", info.Latest).
+			Time("publishedAt", info.PublishedAt).
+			Str("updateUrl", info.UpdateURL).
 			Msg("update available")
 	} else {
 		log.Info().
-			Time("publishedAt", release.PublishedAt).
+			Time("publishedAt", info.PublishedAt).
 			Msg("you are running the latest version")
 	}
 